@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/RadioCheckerApp/crawlers/crawler"
+	"github.com/RadioCheckerApp/crawlers/fetcher"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// timeFormats are the layouts accepted for time flags. Times without zone are interpreted in
+// the time zone of the radio stations.
+var timeFormats = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range timeFormats {
+		if t, err := time.ParseInLocation(layout, value, fetcher.Vienna); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time `%s` (expected e.g. `2018-10-11 14:00`)", value)
+}
+
+func runBackfill(args []string) error {
+	fs, opts := newFlagSet("backfill", "(-from <time> [-to <time>] <station> | -gaps <file>)")
+	opts.addMetricsPushFlag(fs)
+	opts.addTracingFlag(fs)
+	fromFlag := fs.String("from", "", "start of the backfill range")
+	toFlag := fs.String("to", "", "end of the backfill range (default: now)")
+	gapsFile := fs.String("gaps", "", "backfill the gaps listed in this file, as written by "+
+		"`crawlers gaps` (`-` reads from stdin)")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	var gaps []crawler.Gap
+	var err error
+	switch {
+	case *gapsFile != "" && *fromFlag == "" && fs.NArg() == 0:
+		gaps, err = readGaps(*gapsFile)
+	case *gapsFile == "" && *fromFlag != "" && fs.NArg() == 1:
+		var from, to time.Time
+		from, to, err = parseRange(*fromFlag, *toFlag)
+		gaps = []crawler.Gap{{StationId: fs.Arg(0), From: from, To: to}}
+	default:
+		fs.Usage()
+		return errors.New("either a station and -from or -gaps are required")
+	}
+	if err != nil {
+		return err
+	}
+
+	config, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+	stopTracing, err := opts.startTracing()
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+	homeBase, err := opts.homeBase(config.Sink)
+	if err != nil {
+		return err
+	}
+	defer closeHomeBase(homeBase)
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	defer opts.pushMetrics("crawlers-backfill")
+
+	encoder := json.NewEncoder(os.Stdout)
+	failed := 0
+	for _, gap := range gaps {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		station, ok := config.Station(gap.StationId)
+		if !ok {
+			return fmt.Errorf("station `%s` is not configured", gap.StationId)
+		}
+
+		backfillCrawler, err := station.NewBackfillCrawler(homeBase, gap.From, gap.To)
+		if err != nil {
+			return err
+		}
+		if outbox := opts.outbox(); outbox != nil {
+			backfillCrawler = backfillCrawler.WithOutbox(outbox)
+		}
+		slog.Info("Backfilling station.", "station", station.ID,
+			"from", gap.From.Format(time.RFC3339), "to", gap.To.Format(time.RFC3339))
+		report := backfillCrawler.CrawlContext(ctx)
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+		if report.Failed() {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d backfill run(s) failed", failed, len(gaps))
+	}
+	return nil
+}
+
+// parseRange parses the values of the -from and -to flags. `to` defaults to the current time.
+func parseRange(fromFlag, toFlag string) (from, to time.Time, err error) {
+	if from, err = parseTime(fromFlag); err != nil {
+		return
+	}
+	to = time.Now()
+	if toFlag != "" {
+		to, err = parseTime(toFlag)
+	}
+	return
+}
+
+func readGaps(path string) ([]crawler.Gap, error) {
+	var reader io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	var gaps []crawler.Gap
+	if err := json.NewDecoder(reader).Decode(&gaps); err != nil {
+		return nil, errors.New("unable to decode gaps: " + err.Error())
+	}
+	return gaps, nil
+}
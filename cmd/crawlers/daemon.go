@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/RadioCheckerApp/crawlers/crawler"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+func runDaemon(args []string) error {
+	fs, opts := newFlagSet("daemon", "[station ...]")
+	jitter := fs.Duration("jitter", 0, "maximum random delay added to every scheduled run")
+	runOnStart := fs.Bool("run-on-start", false, "crawl every station once on startup")
+	runTimeout := fs.Duration("run-timeout", 30*time.Minute,
+		"maximum duration of a single crawl, including persisting its TrackRecords")
+	metricsAddr := fs.String("metrics-addr", os.Getenv("CRAWLER_METRICS_ADDR"),
+		"address serving Prometheus metrics on /metrics, e.g. :9090 (default: none)")
+	opts.addTracingFlag(fs)
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+	stopTracing, err := opts.startTracing()
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+
+	config, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+	stations, err := selectStations(config, fs.Args(), fs.NArg() == 0)
+	if err != nil {
+		return err
+	}
+	homeBase, err := opts.homeBase(config.Sink)
+	if err != nil {
+		return err
+	}
+	defer closeHomeBase(homeBase)
+
+	notifiers, err := config.OpenNotifiers(context.Background())
+	if err != nil {
+		return err
+	}
+
+	outbox := opts.outbox()
+	scheduler := crawler.Scheduler{Jitter: *jitter, RunOnStart: *runOnStart}
+	scheduled := 0
+	for _, station := range stations {
+		if station.Schedule == "" {
+			slog.Warn("Station has no schedule and will not be crawled.", "station", station.ID)
+			continue
+		}
+		schedule, err := crawler.ParseSchedule(station.Schedule)
+		if err != nil {
+			return err
+		}
+		scheduler.Add(station.ID, schedule, crawlFunc(station, homeBase, outbox, notifiers,
+			*runTimeout))
+		scheduled++
+	}
+	if scheduled == 0 {
+		return errors.New("no stations to schedule")
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	if *metricsAddr != "" {
+		go serveMetrics(ctx, *metricsAddr)
+	}
+
+	slog.Info("Daemon started.", "stations", scheduled)
+	scheduler.Run(ctx)
+	slog.Info("Daemon stopped.")
+	return nil
+}
+
+// crawlFunc returns a job that crawls the station using a fresh crawler, since fetchers keep
+// track of their position and cannot be reused across runs. Runs that exceed the station's
+// thresholds are reported to `notifiers`. On shutdown, i.e. once `ctx` is done, the run stops
+// fetching but persists the TrackRecords it has already fetched. Runs that take longer than
+// `timeout` are cancelled, so that a stuck run neither blocks the next runs of the station nor
+// the shutdown. A panicking run results in a failed report.
+func crawlFunc(station crawler.StationConfig, homeBase crawler.HomeBase, outbox crawler.Outbox,
+	notifiers []crawler.Notifier, timeout time.Duration) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		stationCrawler, err := station.NewCrawler(runCtx, homeBase)
+		if err != nil {
+			slog.Error("Unable to create crawler.", "station", station.ID, "err", err)
+			return
+		}
+		if outbox != nil {
+			stationCrawler = stationCrawler.WithOutbox(outbox)
+		}
+		slog.Info("Crawling station.", "station", station.ID)
+		report := crawler.RunIsolated(station.ID, func() crawler.CrawlReport {
+			return stationCrawler.WithStop(ctx.Done()).CrawlContext(runCtx)
+		})
+		slog.Info("Crawl finished.", "station", station.ID, "run", report.RunId,
+			"duration", report.Duration(), "persisted", report.RecordsPersisted,
+			"fetched", report.RecordsFetched, "failed", report.RecordsFailed, "err", report.Err)
+		err = crawler.NotifyThresholdExceeded(context.WithoutCancel(ctx), notifiers, report)
+		if err != nil {
+			slog.Error("Unable to send alert.", "station", station.ID, "err", err)
+		}
+	}
+}
+
+// serveMetrics serves the Prometheus metrics on `addr` until `ctx` is done.
+func serveMetrics(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", crawler.MetricsHandler())
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	slog.Info("Serving metrics.", "addr", addr, "path", "/metrics")
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Error("Unable to serve metrics.", "err", err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/RadioCheckerApp/crawlers/crawler"
+	"log/slog"
+	"os"
+)
+
+func runGaps(args []string) error {
+	fs, opts := newFlagSet("gaps", "-from <time> [-to <time>] [station ...]")
+	fromFlag := fs.String("from", "", "start of the analyzed window")
+	toFlag := fs.String("to", "", "end of the analyzed window (default: now)")
+	threshold := fs.Duration("threshold", 0,
+		"report time spans without tracks longer than this (default: max_gap of the station)")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+	if *fromFlag == "" {
+		fs.Usage()
+		return errors.New("-from is required")
+	}
+
+	from, to, err := parseRange(*fromFlag, *toFlag)
+	if err != nil {
+		return err
+	}
+
+	config, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+	stations, err := selectStations(config, fs.Args(), fs.NArg() == 0)
+	if err != nil {
+		return err
+	}
+	homeBase, err := opts.homeBase(config.Sink)
+	if err != nil {
+		return err
+	}
+	defer closeHomeBase(homeBase)
+	history, ok := homeBase.(crawler.TrackHistory)
+	if !ok {
+		return errors.New("the homebase is unable to list TrackRecords")
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	gaps := []crawler.Gap{}
+	for _, station := range stations {
+		stationThreshold := *threshold
+		if stationThreshold <= 0 {
+			stationThreshold = station.GapThreshold()
+		}
+		stationGaps, err := crawler.FindGaps(ctx, history, station.ID, from, to, stationThreshold)
+		if err != nil {
+			return err
+		}
+		slog.Info("Found gaps.", "station", station.ID, "gaps", len(stationGaps),
+			"threshold", stationThreshold)
+		gaps = append(gaps, stationGaps...)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(gaps)
+}
@@ -0,0 +1,443 @@
+// Command crawlers runs the configured station crawlers outside of AWS Lambda.
+//
+// Usage:
+//
+//	crawlers <command> [flags] [arguments]
+//
+// The commands are:
+//
+//	backfill         persist the tracks a station aired within a time range
+//	crawl            crawl the given stations (or all stations with -all) and print a JSON
+//	                 report per station
+//	daemon           crawl the stations according to their schedules until terminated
+//	gaps             list the time spans without tracks in the homebase as JSON
+//	list-stations    print the configured stations
+//	replay           persist the TrackRecords stored in the outbox
+//	validate-config  check the station configuration and the options of its fetchers for errors
+//	watchdog         alert if stations have no recent TrackRecords
+//
+// Unless overridden by flags, the RadioChecker API is configured using the environment
+// variables RC_API_HOST (or RC_API_URL), RC_API_KEY and RC_API_AUTHORIZATION, the station
+// configuration is read from CRAWLER_CONFIG. RC_API_CA, RC_API_CLIENT_CERT and RC_API_CLIENT_KEY
+// configure private certificate authorities and mutual TLS. RC_API_AUTH selects short-lived
+// credentials instead: `oauth2` (RC_API_OAUTH_TOKEN_URL, RC_API_OAUTH_CLIENT_ID,
+// RC_API_OAUTH_CLIENT_SECRET, RC_API_OAUTH_SCOPES) or `hmac` request signing (RC_API_HMAC_KEY_ID,
+// RC_API_HMAC_SECRET). RC_API_MAX_ATTEMPTS limits the attempts per request. Secrets may be given
+// as references, e.g. `file:///run/secrets/rc-token` or `env://NAME`, which are resolved again
+// after a minute and once the API rejected them. The Lambda functions read the same variables
+// (see crawler.APIConfigFromEnv).
+//
+// The `sink` section of the configuration may store the TrackRecords in a JSONL file, a SQLite
+// or a PostgreSQL database instead of the RadioChecker API.
+//
+// TrackRecords that cannot be persisted are stored in the outbox file CRAWLER_OUTBOX, if set,
+// and can be persisted later using the replay command.
+//
+// Logs are written to stderr as logfmt, or as JSON with CRAWLER_LOG_FORMAT=json (-log-format).
+// CRAWLER_LOG_LEVEL (-log-level) sets the minimum level: debug, info (default), warn or error.
+//
+// The daemon serves Prometheus metrics on CRAWLER_METRICS_ADDR, if set. The crawl and backfill
+// commands push them to the Pushgateway METRICS_PUSHGATEWAY_URL, if set.
+//
+// The watchdog command alerts through the `notifiers` of the configuration (webhook, slack or
+// email) once the latest TrackRecord of a station is older than its `max_age` (default 2h), and
+// again once the station recovered. The crawl and daemon commands use the same notifiers to
+// alert if a run fails since more than `max_failure_rate` percent (default 50) of the fetched
+// items could not be parsed, or more than `max_skip_rate` percent did not result in a
+// TrackRecord.
+//
+// The crawl, backfill and daemon commands export OpenTelemetry traces of every crawler run to
+// the OTLP/HTTP collector CRAWLER_TRACE_ENDPOINT (-trace-endpoint), e.g. http://localhost:4318.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/RadioCheckerApp/crawlers/crawler"
+	_ "github.com/RadioCheckerApp/crawlers/crawler/drivers"
+	"github.com/RadioCheckerApp/crawlers/logging"
+	"github.com/RadioCheckerApp/crawlers/tracing"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+const defaultConfigPath = "stations.yml"
+
+type command struct {
+	description string
+	run         func(args []string) error
+}
+
+var commands = map[string]command{
+	"backfill":        {"persist the tracks a station aired within a time range", runBackfill},
+	"crawl":           {"crawl the given stations (or all stations with -all)", runCrawl},
+	"daemon":          {"crawl the stations according to their schedules until terminated", runDaemon},
+	"gaps":            {"list the time spans without tracks in the homebase as JSON", runGaps},
+	"list-stations":   {"print the configured stations", runListStations},
+	"replay":          {"persist the TrackRecords stored in the outbox", runReplay},
+	"validate-config": {"check the station configuration and fetcher options", runValidateConfig},
+	"watchdog":        {"alert if stations have no recent TrackRecords", runWatchdog},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+// run runs the command named by the first argument and returns the exit code: 2 if no command
+// was run or its usage was requested, 1 if the command failed.
+func run(args []string, stderr io.Writer) int {
+	if len(args) < 1 {
+		usage(stderr)
+		return 2
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		if args[0] != "help" && args[0] != "-h" && args[0] != "--help" {
+			fmt.Fprintf(stderr, "crawlers: unknown command `%s`\n\n", args[0])
+		}
+		usage(stderr)
+		return 2
+	}
+
+	if err := cmd.run(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return 2
+		}
+		fmt.Fprintf(stderr, "crawlers %s: %s\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: crawlers <command> [flags] [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", name, commands[name].description)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nRun `crawlers <command> -h` for the flags of a command.")
+}
+
+// options holds the flags shared by all commands.
+type options struct {
+	configPath     string
+	api            crawler.APIConfig
+	apiOAuthScopes string
+	outboxPath     string
+	metricsPushURL string
+	traceEndpoint  string
+	logFormat      string
+	logLevel       string
+
+	// envErr is an invalid environment variable, which is reported once the flags are parsed.
+	envErr error
+}
+
+func newFlagSet(name, arguments string) (*flag.FlagSet, *options) {
+	opts := &options{}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: crawlers %s [flags] %s\n\nFlags:\n", name, arguments)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.configPath, "config", envOrDefault("CRAWLER_CONFIG", defaultConfigPath),
+		"path of the station configuration file (YAML or JSON)")
+	env, err := crawler.APIConfigFromEnv()
+	opts.envErr = err
+	fs.StringVar(&opts.api.Host, "api-host", env.Host, "host of the RadioChecker API")
+	fs.StringVar(&opts.api.URL, "api-url", env.URL,
+		"base URL of the RadioChecker API, e.g. http://localhost:8080 (overrides -api-host)")
+	fs.StringVar(&opts.api.Auth.APIKey, "api-key", env.Auth.APIKey,
+		"API key used for read requests")
+	fs.StringVar(&opts.api.Auth.Token, "api-authorization", env.Auth.Token,
+		"bearer token used for write requests")
+	fs.IntVar(&opts.api.MaxAttempts, "api-max-attempts", env.MaxAttempts,
+		"maximum number of attempts per request to the RadioChecker API")
+	fs.DurationVar(&opts.api.Client.Timeout, "api-timeout", 5*time.Second,
+		"timeout of a single request to the RadioChecker API")
+	fs.StringVar(&opts.api.Client.CAFile, "api-ca", env.Client.CAFile,
+		"PEM file of additional certificate authorities trusted for the RadioChecker API")
+	fs.StringVar(&opts.api.Client.CertFile, "api-client-cert", env.Client.CertFile,
+		"PEM file of the client certificate presented to the RadioChecker API")
+	fs.StringVar(&opts.api.Client.KeyFile, "api-client-key", env.Client.KeyFile,
+		"PEM file of the client certificate's private key")
+	fs.StringVar(&opts.api.Client.ProxyURL, "api-proxy", "",
+		"proxy URL for requests to the RadioChecker API (default: HTTPS_PROXY)")
+	fs.StringVar(&opts.api.Auth.Type, "api-auth", env.Auth.Type,
+		"authentication for the RadioChecker API: default (API key for reads, bearer token for "+
+			"writes), api-key, bearer, oauth2 or hmac")
+	fs.StringVar(&opts.api.Auth.TokenURL, "api-oauth-token-url", env.Auth.TokenURL,
+		"token endpoint of the OAuth2 client credentials grant")
+	fs.StringVar(&opts.api.Auth.ClientID, "api-oauth-client-id", env.Auth.ClientID,
+		"OAuth2 client id")
+	fs.StringVar(&opts.api.Auth.ClientSecret, "api-oauth-client-secret", env.Auth.ClientSecret,
+		"OAuth2 client secret")
+	fs.StringVar(&opts.apiOAuthScopes, "api-oauth-scopes", strings.Join(env.Auth.Scopes, " "),
+		"space separated OAuth2 scopes")
+	fs.StringVar(&opts.api.Auth.KeyID, "api-hmac-key-id", env.Auth.KeyID,
+		"key id of the HMAC request signature")
+	fs.StringVar(&opts.api.Auth.Secret, "api-hmac-secret", env.Auth.Secret,
+		"shared secret of the HMAC request signature")
+	fs.StringVar(&opts.outboxPath, "outbox", os.Getenv("CRAWLER_OUTBOX"),
+		"file that stores TrackRecords which could not be persisted (default: none)")
+	fs.StringVar(&opts.logFormat, "log-format", envOrDefault("CRAWLER_LOG_FORMAT", "text"),
+		"log format: text (logfmt) or json")
+	fs.StringVar(&opts.logLevel, "log-level", envOrDefault("CRAWLER_LOG_LEVEL", "info"),
+		"minimum level of logged messages: debug, info, warn or error")
+	return fs, opts
+}
+
+// parse parses the flags and configures the default logger, which is used by all crawlers.
+func (opts *options) parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if opts.envErr != nil {
+		return opts.envErr
+	}
+	logger, err := logging.New(os.Stderr, opts.logFormat, opts.logLevel)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// addMetricsPushFlag adds the -metrics-push-url flag to commands that end after their run.
+func (opts *options) addMetricsPushFlag(fs *flag.FlagSet) {
+	fs.StringVar(&opts.metricsPushURL, "metrics-push-url", os.Getenv("METRICS_PUSHGATEWAY_URL"),
+		"Prometheus Pushgateway that receives the metrics after the run (default: none)")
+}
+
+// addTracingFlag adds the -trace-endpoint flag to commands that crawl stations.
+func (opts *options) addTracingFlag(fs *flag.FlagSet) {
+	fs.StringVar(&opts.traceEndpoint, "trace-endpoint", os.Getenv("CRAWLER_TRACE_ENDPOINT"),
+		"OTLP/HTTP collector receiving the traces, e.g. http://localhost:4318 (default: none)")
+}
+
+// startTracing exports the spans to the collector configured by -trace-endpoint, if any. The
+// returned function exports the remaining spans and must be called before exiting.
+func (opts *options) startTracing() (func(), error) {
+	if opts.traceEndpoint == "" {
+		return func() {}, nil
+	}
+	provider, err := tracing.Setup(context.Background(), opts.traceEndpoint, "")
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure tracing: %s", err)
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			slog.Error("Unable to export traces.", "err", err)
+		}
+	}, nil
+}
+
+func (opts *options) loadConfig() (crawler.Config, error) {
+	return crawler.LoadConfig(opts.configPath)
+}
+
+// homeBase opens the configured sink. If the sink uses the RadioChecker API, the API is
+// configured using the flags.
+func (opts *options) homeBase(sink crawler.SinkConfig) (crawler.HomeBase, error) {
+	api := opts.api
+	api.Auth.Scopes = strings.Fields(opts.apiOAuthScopes)
+	return crawler.OpenHomeBase(sink, api)
+}
+
+// outbox returns the outbox configured by -outbox, or nil if there is none.
+func (opts *options) outbox() crawler.Outbox {
+	if opts.outboxPath == "" {
+		return nil
+	}
+	return crawler.NewFileOutbox(opts.outboxPath)
+}
+
+// pushMetrics pushes the metrics to the Pushgateway configured by -metrics-push-url, if any.
+func (opts *options) pushMetrics(job string) {
+	if opts.metricsPushURL == "" {
+		return
+	}
+	if err := crawler.PushMetrics(context.Background(), opts.metricsPushURL, job,
+		""); err != nil {
+		slog.Error("Unable to push metrics.", "err", err)
+	}
+}
+
+// closeHomeBase closes sinks that hold resources, e.g. database connections.
+func closeHomeBase(homeBase crawler.HomeBase) {
+	crawler.CloseHomeBase(context.Background(), homeBase)
+}
+
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// selectStations returns the configured stations named in `ids`, or every station if `all` is
+// set.
+func selectStations(config crawler.Config, ids []string, all bool) ([]crawler.StationConfig,
+	error) {
+	if all {
+		if len(ids) > 0 {
+			return nil, errors.New("-all cannot be combined with station ids")
+		}
+		return config.Stations, nil
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no station given (use -all to select every station)")
+	}
+
+	var stations []crawler.StationConfig
+	for _, id := range ids {
+		station, ok := config.Station(id)
+		if !ok {
+			return nil, fmt.Errorf("station `%s` is not configured", id)
+		}
+		stations = append(stations, station)
+	}
+	return stations, nil
+}
+
+func runCrawl(args []string) error {
+	fs, opts := newFlagSet("crawl", "[station ...]")
+	opts.addMetricsPushFlag(fs)
+	opts.addTracingFlag(fs)
+	all := fs.Bool("all", false, "crawl every configured station")
+	workers := fs.Int("workers", 4, "number of stations crawled concurrently")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+	stopTracing, err := opts.startTracing()
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+
+	config, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+	stations, err := selectStations(config, fs.Args(), *all)
+	if err != nil {
+		fs.Usage()
+		return err
+	}
+	homeBase, err := opts.homeBase(config.Sink)
+	if err != nil {
+		return err
+	}
+	defer closeHomeBase(homeBase)
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	notifiers, err := config.OpenNotifiers(ctx)
+	if err != nil {
+		return err
+	}
+	orchestrator := crawler.Orchestrator{Workers: *workers, Outbox: opts.outbox(),
+		Notifiers: notifiers}
+	report := orchestrator.RunStations(ctx, stations, homeBase)
+	opts.pushMetrics("crawlers-crawl")
+
+	// reports are written as one JSON object per line
+	encoder := json.NewEncoder(os.Stdout)
+	for _, stationReport := range report.Reports {
+		if err := encoder.Encode(stationReport); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Crawled stations.", "stations", len(report.Reports),
+		"duration", report.End.Sub(report.Start), "persisted", report.RecordsPersisted)
+	if report.StationsFailed > 0 {
+		return fmt.Errorf("%d of %d station(s) failed", report.StationsFailed, len(stations))
+	}
+	return nil
+}
+
+// signalContext returns a context that is cancelled on SIGINT or SIGTERM.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			slog.Info("Received signal, shutting down.", "signal", sig.String())
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, cancel
+}
+
+func runListStations(args []string) error {
+	fs, opts := newFlagSet("list-stations", "")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	config, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFETCHER\tSCHEDULE")
+	for _, station := range config.Stations {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", station.ID, station.Fetcher, station.Schedule)
+	}
+	return w.Flush()
+}
+
+func runValidateConfig(args []string) error {
+	fs, opts := newFlagSet("validate-config", "")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	config, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	// the fetchers are created as well, so that invalid options and secrets that cannot be
+	// resolved are reported before the stations are deployed
+	var errs []error
+	for _, station := range config.Stations {
+		if _, err := station.NewFetcher(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d station(s) configured, configuration is valid.\n",
+		opts.configPath, len(config.Stations))
+	return nil
+}
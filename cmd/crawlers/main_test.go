@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, config string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	valid := writeConfig(t, "valid.yml", `
+stations:
+  - id: kronehit
+    fetcher: kronehit
+    schedule: rate(1 hour)
+  - id: hitradio-oe3
+    fetcher: hitradio-oe3
+    options:
+      consumer_key: key
+      consumer_key_secret: file://`+writeConfig(t, "secret", "secret")+`
+      oauth_access_token: token
+      oauth_access_token_secret: secret
+`)
+	invalidOptions := writeConfig(t, "options.yml", `
+stations:
+  - id: kronehit
+    fetcher: kronehit
+  - id: hitradio-oe3
+    fetcher: hitradio-oe3
+    options:
+      airtime_tolerance: 45
+`)
+	missingSecret := writeConfig(t, "secret.yml", `
+stations:
+  - id: hitradio-oe3
+    fetcher: hitradio-oe3
+    options:
+      consumer_key: key
+      consumer_key_secret: file:///nonexistent/secret
+      oauth_access_token: token
+      oauth_access_token_secret: secret
+`)
+
+	var tests = []struct {
+		args             []string
+		expectedCode     int
+		expectedOutput   string
+		unexpectedOutput string
+	}{
+		{nil, 2, "Usage: crawlers <command>", "unknown command"},
+		{[]string{"help"}, 2, "validate-config", "unknown command"},
+		{[]string{"--help"}, 2, "Usage: crawlers <command>", "unknown command"},
+		{[]string{"bogus"}, 2, "unknown command `bogus`", ""},
+		{[]string{"validate-config", "-config", valid}, 0, "", "crawlers"},
+		{[]string{"validate-config", "-config", invalidOptions}, 1,
+			"crawlers validate-config: station `hitradio-oe3`", "station `kronehit`"},
+		{[]string{"validate-config", "-config", missingSecret}, 1,
+			"option `consumer_key_secret`", ""},
+		{[]string{"validate-config", "-config", filepath.Join(t.TempDir(), "none.yml")}, 1,
+			"crawlers validate-config:", ""},
+		{[]string{"validate-config", "-h"}, 2, "", "crawlers validate-config:"},
+		{[]string{"validate-config", "-unknown-flag"}, 1,
+			"crawlers validate-config: flag provided but not defined", ""},
+		{[]string{"list-stations", "-config", valid}, 0, "", "crawlers"},
+		{[]string{"crawl", "-config", valid, "unknown"}, 1,
+			"crawlers crawl: station `unknown` is not configured", ""},
+		{[]string{"crawl", "-config", valid, "-all", "kronehit"}, 1,
+			"-all cannot be combined with station ids", ""},
+	}
+
+	// the commands print their results, usage and logs to the standard streams
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	originalStdout, originalStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = devNull, devNull
+	defer func() { os.Stdout, os.Stderr = originalStdout, originalStderr }()
+
+	for _, test := range tests {
+		var stderr bytes.Buffer
+		code := run(test.args, &stderr)
+		output := stderr.String()
+		if code != test.expectedCode || !strings.Contains(output, test.expectedOutput) ||
+			(test.unexpectedOutput != "" && strings.Contains(output, test.unexpectedOutput)) {
+			t.Errorf("run(%v): got (%d, `%s`), expected (%d, `%s`) without `%s`", test.args,
+				code, output, test.expectedCode, test.expectedOutput, test.unexpectedOutput)
+		}
+	}
+}
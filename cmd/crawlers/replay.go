@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/RadioCheckerApp/crawlers/crawler"
+	"log/slog"
+	"os"
+)
+
+func runReplay(args []string) error {
+	fs, opts := newFlagSet("replay", "")
+	maxAttempts := fs.Int("max-attempts", crawler.DefaultMaxReplayAttempts,
+		"number of failed attempts after which a TrackRecord becomes a dead letter")
+	list := fs.Bool("list", false, "print the entries of the outbox instead of replaying them")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	outbox := opts.outbox()
+	if outbox == nil {
+		fs.Usage()
+		return errors.New("outbox is not set (use -outbox or CRAWLER_OUTBOX)")
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	if *list {
+		entries, err := outbox.Entries()
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	config, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+	homeBase, err := opts.homeBase(config.Sink)
+	if err != nil {
+		return err
+	}
+	defer closeHomeBase(homeBase)
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	report, err := crawler.ReplayOutbox(ctx, outbox, homeBase, *maxAttempts)
+	if err != nil {
+		return err
+	}
+	slog.Info("Outbox replayed.", "persisted", report.Persisted, "failed", report.Failed,
+		"remaining", report.Remaining, "deadLetters", report.DeadLetters)
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d TrackRecord(s) could not be persisted", report.Failed)
+	}
+	return nil
+}
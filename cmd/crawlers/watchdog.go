@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/RadioCheckerApp/crawlers/crawler"
+	"log/slog"
+	"os"
+	"time"
+)
+
+func runWatchdog(args []string) error {
+	fs, opts := newFlagSet("watchdog", "[station ...]")
+	interval := fs.Duration("interval", 5*time.Minute, "time between two checks")
+	once := fs.Bool("once", false, "check the stations once, print the alerts as JSON and exit")
+	repeat := fs.Duration("repeat", 0,
+		"repeat the alert of a station that is still stale after this time (default: never)")
+	statePath := fs.String("state", os.Getenv("CRAWLER_WATCHDOG_STATE"),
+		"file storing the stale stations, so that alerts are not repeated across runs")
+	metricsAddr := fs.String("metrics-addr", os.Getenv("CRAWLER_METRICS_ADDR"),
+		"address serving Prometheus metrics on /metrics, e.g. :9090 (default: none)")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	config, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+	stations, err := selectStations(config, fs.Args(), fs.NArg() == 0)
+	if err != nil {
+		return err
+	}
+	homeBase, err := opts.homeBase(config.Sink)
+	if err != nil {
+		return err
+	}
+	defer closeHomeBase(homeBase)
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	notifiers, err := config.OpenNotifiers(ctx)
+	if err != nil {
+		return err
+	}
+	if len(notifiers) == 0 {
+		slog.Warn("No notifiers configured, alerts are only logged.")
+	}
+	watchdog := &crawler.Watchdog{
+		HomeBase:       homeBase,
+		Stations:       stations,
+		Notifiers:      notifiers,
+		RepeatInterval: *repeat,
+		StatePath:      *statePath,
+	}
+
+	if *once {
+		alerts, err := watchdog.Check(ctx)
+		encoder := json.NewEncoder(os.Stdout)
+		for _, alert := range alerts {
+			if err := encoder.Encode(alert); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("check failed: %s", err)
+		}
+		return nil
+	}
+
+	if *metricsAddr != "" {
+		go serveMetrics(ctx, *metricsAddr)
+	}
+	slog.Info("Watchdog started.", "stations", len(stations), "interval", *interval)
+	watchdog.Run(ctx, *interval)
+	slog.Info("Watchdog stopped.")
+	return nil
+}
@@ -0,0 +1,49 @@
+// Command fake-homebase serves an in-memory stand-in for the track endpoints of the RadioChecker
+// API, see package fakehomebase. Stored tracks are lost when the command exits.
+//
+// Usage:
+//
+//	fake-homebase [-addr :8080] [-api-key key] [-authorization token] [-failure-rate 0.1]
+//	              [-disable-batch] [-tls-cert cert.pem -tls-key key.pem]
+package main
+
+import (
+	"flag"
+	"github.com/RadioCheckerApp/crawlers/fakehomebase"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	apiKey := flag.String("api-key", os.Getenv("RC_API_KEY"),
+		"API key expected for read requests (default: not checked)")
+	authorization := flag.String("authorization", os.Getenv("RC_API_AUTHORIZATION"),
+		"bearer token expected for write requests (default: not checked)")
+	failureRate := flag.Float64("failure-rate", 0,
+		"fraction of requests answered with 503 Service Unavailable")
+	disableBatch := flag.Bool("disable-batch", false, "reject batch requests")
+	tlsCert := flag.String("tls-cert", "", "certificate file, enables HTTPS")
+	tlsKey := flag.String("tls-key", "", "private key file of the certificate")
+	flag.Parse()
+
+	server := fakehomebase.New(*apiKey, *authorization)
+	server.FailureRate = *failureRate
+	server.DisableBatch = *disableBatch
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slog.Info("Request received.", "method", r.Method, "url", r.URL.String())
+		server.ServeHTTP(w, r)
+	})
+
+	var err error
+	if *tlsCert != "" {
+		slog.Info("Fake homebase listening.", "url", "https://"+*addr)
+		err = http.ListenAndServeTLS(*addr, *tlsCert, *tlsKey, handler)
+	} else {
+		slog.Info("Fake homebase listening.", "url", "http://"+*addr)
+		err = http.ListenAndServe(*addr, handler)
+	}
+	slog.Error("Fake homebase stopped.", "err", err)
+	os.Exit(1)
+}
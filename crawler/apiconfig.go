@@ -0,0 +1,123 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/RadioCheckerApp/crawlers/logging"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// APIConfig describes how the RadioChecker API is reached: its address, the credentials, the
+// HTTP client and the retries. It is configured separately from the station configuration,
+// since it holds credentials.
+type APIConfig struct {
+	// Host is the host of the API, URL may be used instead to set its base URL, e.g.
+	// `http://localhost:8080` (see HomeBaseConnector).
+	Host string
+	URL  string
+	// Auth selects the credentials, whose secrets are resolved again after DefaultSecretTTL and
+	// once the API rejected them (see ResolvingAuthenticator).
+	Auth AuthConfig
+	// Client configures the timeout, TLS and the proxy of the requests.
+	Client HTTPClientOptions
+	// MaxAttempts is the maximum number of attempts per request (default: DefaultRetryPolicy).
+	MaxAttempts int
+}
+
+// APIConfigFromEnv reads the APIConfig from the environment variables RC_API_HOST, RC_API_URL,
+// RC_API_AUTH, RC_API_KEY, RC_API_AUTHORIZATION, RC_API_OAUTH_TOKEN_URL, RC_API_OAUTH_CLIENT_ID,
+// RC_API_OAUTH_CLIENT_SECRET, RC_API_OAUTH_SCOPES (space separated), RC_API_HMAC_KEY_ID,
+// RC_API_HMAC_SECRET, RC_API_CA, RC_API_CLIENT_CERT, RC_API_CLIENT_KEY and RC_API_MAX_ATTEMPTS.
+// The proxy is read from HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+func APIConfigFromEnv() (APIConfig, error) {
+	config := APIConfig{
+		Host: os.Getenv("RC_API_HOST"),
+		URL:  os.Getenv("RC_API_URL"),
+		Auth: AuthConfig{
+			Type:         os.Getenv("RC_API_AUTH"),
+			APIKey:       os.Getenv("RC_API_KEY"),
+			Token:        os.Getenv("RC_API_AUTHORIZATION"),
+			TokenURL:     os.Getenv("RC_API_OAUTH_TOKEN_URL"),
+			ClientID:     os.Getenv("RC_API_OAUTH_CLIENT_ID"),
+			ClientSecret: os.Getenv("RC_API_OAUTH_CLIENT_SECRET"),
+			Scopes:       strings.Fields(os.Getenv("RC_API_OAUTH_SCOPES")),
+			KeyID:        os.Getenv("RC_API_HMAC_KEY_ID"),
+			Secret:       os.Getenv("RC_API_HMAC_SECRET"),
+		},
+		Client: HTTPClientOptions{
+			CAFile:   os.Getenv("RC_API_CA"),
+			CertFile: os.Getenv("RC_API_CLIENT_CERT"),
+			KeyFile:  os.Getenv("RC_API_CLIENT_KEY"),
+		},
+		MaxAttempts: DefaultRetryPolicy.MaxAttempts,
+	}
+	if config.Auth.Type == "" {
+		config.Auth.Type = AuthDefault
+	}
+	if maxAttempts := os.Getenv("RC_API_MAX_ATTEMPTS"); maxAttempts != "" {
+		var err error
+		config.MaxAttempts, err = strconv.Atoi(maxAttempts)
+		if err != nil || config.MaxAttempts < 1 {
+			return config, fmt.Errorf("invalid RC_API_MAX_ATTEMPTS `%s`", maxAttempts)
+		}
+	}
+	return config, nil
+}
+
+// Connector creates a HomeBaseConnector for the API. Its HTTP client and authenticator should
+// be reused for all requests.
+func (config APIConfig) Connector() (HomeBaseConnector, error) {
+	if config.Host == "" && config.URL == "" {
+		return HomeBaseConnector{}, errors.New("RadioChecker API host is not set " +
+			"(RC_API_HOST or RC_API_URL)")
+	}
+	client, err := NewHTTPClient(config.Client)
+	if err != nil {
+		return HomeBaseConnector{}, fmt.Errorf("unable to configure RadioChecker API client: %s",
+			err)
+	}
+	authenticator, err := NewResolvingAuthenticator(config.Auth)
+	if err != nil {
+		return HomeBaseConnector{}, err
+	}
+	authenticator.HTTPClient = client
+
+	retryPolicy := DefaultRetryPolicy
+	if config.MaxAttempts > 0 {
+		retryPolicy.MaxAttempts = config.MaxAttempts
+	}
+	return HomeBaseConnector{
+		APIHost:       config.Host,
+		BaseURL:       config.URL,
+		Authenticator: authenticator,
+		HTTPClient:    client,
+		RetryPolicy:   &retryPolicy,
+	}, nil
+}
+
+// OpenHomeBase opens the sink, which uses the API described by `api` if it is an `api` sink or
+// has an `api` secondary.
+func OpenHomeBase(sink SinkConfig, api APIConfig) (HomeBase, error) {
+	if !sink.UsesAPI() {
+		return sink.Open(nil)
+	}
+	connector, err := api.Connector()
+	if err != nil {
+		return nil, err
+	}
+	return sink.Open(connector)
+}
+
+// CloseHomeBase closes sinks that hold resources, e.g. database connections. Errors are logged
+// using the logger of `ctx`.
+func CloseHomeBase(ctx context.Context, homeBase HomeBase) {
+	if closer, ok := homeBase.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logging.FromContext(ctx).Warn("Unable to close sink.", "err", err)
+		}
+	}
+}
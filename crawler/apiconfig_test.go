@@ -0,0 +1,84 @@
+package crawler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestAPIConfigFromEnv(t *testing.T) {
+	t.Setenv("RC_API_HOST", "api.example.com")
+	t.Setenv("RC_API_URL", "")
+	t.Setenv("RC_API_AUTH", "")
+	t.Setenv("RC_API_KEY", "key")
+	t.Setenv("RC_API_AUTHORIZATION", "env://RC_TEST_TOKEN")
+	t.Setenv("RC_API_OAUTH_SCOPES", "tracks:read tracks:write")
+	t.Setenv("RC_API_CA", "/etc/ssl/radiochecker.pem")
+	t.Setenv("RC_API_MAX_ATTEMPTS", "")
+
+	config, err := APIConfigFromEnv()
+	if err != nil {
+		t.Fatalf("APIConfigFromEnv: got error: `%s`", err)
+	}
+	if config.Host != "api.example.com" || config.Auth.Type != AuthDefault ||
+		config.Auth.APIKey != "key" || config.Auth.Token != "env://RC_TEST_TOKEN" ||
+		!reflect.DeepEqual(config.Auth.Scopes, []string{"tracks:read", "tracks:write"}) ||
+		config.Client.CAFile != "/etc/ssl/radiochecker.pem" ||
+		config.MaxAttempts != DefaultRetryPolicy.MaxAttempts {
+		t.Errorf("APIConfigFromEnv: got %+v", config)
+	}
+
+	t.Setenv("RC_API_MAX_ATTEMPTS", "5")
+	if config, err := APIConfigFromEnv(); err != nil || config.MaxAttempts != 5 {
+		t.Errorf("APIConfigFromEnv: got (%d, %v), expected (5, nil)", config.MaxAttempts, err)
+	}
+	for _, maxAttempts := range []string{"0", "many"} {
+		t.Setenv("RC_API_MAX_ATTEMPTS", maxAttempts)
+		if _, err := APIConfigFromEnv(); err == nil {
+			t.Errorf("APIConfigFromEnv: got no error for RC_API_MAX_ATTEMPTS `%s`", maxAttempts)
+		}
+	}
+}
+
+func TestOpenHomeBase(t *testing.T) {
+	api := APIConfig{URL: "http://localhost:8080", Auth: AuthConfig{Type: AuthBearer},
+		MaxAttempts: 5}
+	homeBase, err := OpenHomeBase(SinkConfig{}, api)
+	connector, ok := homeBase.(HomeBaseConnector)
+	if err != nil || !ok {
+		t.Fatalf("OpenHomeBase: got (%T, %v), expected a HomeBaseConnector", homeBase, err)
+	}
+	if connector.BaseURL != api.URL || connector.HTTPClient == nil ||
+		connector.RetryPolicy.MaxAttempts != 5 {
+		t.Errorf("OpenHomeBase: got %+v", connector)
+	}
+	if _, ok := connector.Authenticator.(*ResolvingAuthenticator); !ok {
+		t.Errorf("OpenHomeBase: got authenticator %T, expected *ResolvingAuthenticator",
+			connector.Authenticator)
+	}
+
+	var tests = []struct {
+		sink        SinkConfig
+		api         APIConfig
+		expectedErr bool
+	}{
+		{SinkConfig{}, APIConfig{}, true},
+		{SinkConfig{}, APIConfig{Host: "api.example.com", Auth: AuthConfig{Type: "unknown"}},
+			true},
+		{SinkConfig{}, APIConfig{Host: "api.example.com",
+			Client: HTTPClientOptions{CAFile: "/nonexistent/ca.pem"}}, true},
+		{SinkConfig{Type: SinkJSONL, Path: "tracks.jsonl"}, APIConfig{}, false},
+		{SinkConfig{Type: SinkJSONL, Path: "tracks.jsonl",
+			Secondaries: []SinkConfig{{Type: SinkAPI}}}, APIConfig{}, true},
+	}
+	for _, test := range tests {
+		homeBase, err := OpenHomeBase(test.sink, test.api)
+		if (err != nil) != test.expectedErr {
+			t.Errorf("OpenHomeBase(%+v, %+v): got error: (%v), expected error: (%v)", test.sink,
+				test.api, err, test.expectedErr)
+		}
+		if err == nil {
+			CloseHomeBase(context.Background(), homeBase)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package crawler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator adds credentials to the requests sent to the homebase. It is called before
+// every attempt of a request, `payload` is the request body or nil.
+type Authenticator interface {
+	Authenticate(req *http.Request, payload []byte) error
+}
+
+// Invalidator is implemented by Authenticators that cache credentials. The HomeBaseConnector
+// invalidates the credentials if the homebase rejects a request with 401, and retries the
+// request with new ones if Invalidate reports that cached credentials were dropped.
+type Invalidator interface {
+	Invalidate() bool
+}
+
+// BearerToken sends a static token in the `Authorization` header.
+type BearerToken struct {
+	Token string
+}
+
+func (auth BearerToken) Authenticate(req *http.Request, payload []byte) error {
+	req.Header.Set("Authorization", "Bearer "+auth.Token)
+	return nil
+}
+
+// APIKey sends a static key in the `X-API-KEY` header, or in Header if it is set.
+type APIKey struct {
+	Key    string
+	Header string
+}
+
+func (auth APIKey) Authenticate(req *http.Request, payload []byte) error {
+	header := auth.Header
+	if header == "" {
+		header = "X-API-KEY"
+	}
+	req.Header.Set(header, auth.Key)
+	return nil
+}
+
+// SplitAuthenticator authenticates GET requests using Read and all other requests using Write.
+// This matches the RadioChecker API, which expects an API key for read access and a bearer
+// token for write access.
+type SplitAuthenticator struct {
+	Read  Authenticator
+	Write Authenticator
+}
+
+func (auth SplitAuthenticator) Authenticate(req *http.Request, payload []byte) error {
+	if req.Method == http.MethodGet {
+		return auth.Read.Authenticate(req, payload)
+	}
+	return auth.Write.Authenticate(req, payload)
+}
+
+// tokenExpiryMargin is the time before the expiry of an OAuth2 token at which a new token is
+// requested.
+const tokenExpiryMargin = 30 * time.Second
+
+// OAuth2ClientCredentials requests access tokens using the OAuth2 client credentials grant and
+// sends them as bearer tokens. Tokens are cached until shortly before they expire.
+type OAuth2ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// HTTPClient is used to request tokens. A shared client is used if it is nil.
+	HTTPClient *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func NewOAuth2ClientCredentials(tokenURL, clientID, clientSecret string,
+	scopes ...string) *OAuth2ClientCredentials {
+	return &OAuth2ClientCredentials{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+	}
+}
+
+func (auth *OAuth2ClientCredentials) Authenticate(req *http.Request, payload []byte) error {
+	token, err := auth.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Token returns the cached access token or requests a new one if it is about to expire.
+func (auth *OAuth2ClientCredentials) Token(ctx context.Context) (string, error) {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+	if auth.token != "" && time.Now().Add(tokenExpiryMargin).Before(auth.expires) {
+		return auth.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(auth.Scopes) > 0 {
+		form.Set("scope", strings.Join(auth.Scopes, " "))
+	}
+	req, err := http.NewRequest(http.MethodPost, auth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(auth.ClientID), url.QueryEscape(auth.ClientSecret))
+
+	client := auth.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("unable to request OAuth2 token: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("unable to request OAuth2 token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &HomeBaseError{StatusCode: resp.StatusCode,
+			Message: "OAuth2 token request failed: " + string(body), Err: ErrUnauthorized}
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return "", errors.New("OAuth2 token response does not contain an access token")
+	}
+	auth.token = token.AccessToken
+	auth.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	if token.ExpiresIn <= 0 {
+		// tokens without expiry are kept for an hour
+		auth.expires = time.Now().Add(time.Hour)
+	}
+	return auth.token, nil
+}
+
+// Invalidate drops the cached token, so that the next request uses a new one, e.g. after the
+// token was revoked. It reports whether a token was cached.
+func (auth *OAuth2ClientCredentials) Invalidate() bool {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+	cached := auth.token != ""
+	auth.token = ""
+	auth.expires = time.Time{}
+	return cached
+}
+
+// HMACSigner signs every request with a shared secret. It sets the headers `X-RC-Key-Id`,
+// `X-RC-Timestamp` (Unix seconds) and `X-RC-Signature`, the hex encoded HMAC-SHA256 of
+//
+//	METHOD + "\n" + PATH?QUERY + "\n" + TIMESTAMP + "\n" + hex(SHA256(BODY))
+type HMACSigner struct {
+	KeyID  string
+	Secret []byte
+	// now is used in tests.
+	now func() time.Time
+}
+
+func (auth HMACSigner) Authenticate(req *http.Request, payload []byte) error {
+	now := time.Now
+	if auth.now != nil {
+		now = auth.now
+	}
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+	bodyHash := sha256.Sum256(payload)
+
+	mac := hmac.New(sha256.New, auth.Secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", req.Method, req.URL.RequestURI(), timestamp,
+		hex.EncodeToString(bodyHash[:]))
+
+	req.Header.Set("X-RC-Key-Id", auth.KeyID)
+	req.Header.Set("X-RC-Timestamp", timestamp)
+	req.Header.Set("X-RC-Signature", hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
+
+// Authentication types supported by AuthConfig.
+const (
+	AuthDefault = "default"
+	AuthBearer  = "bearer"
+	AuthAPIKey  = "api-key"
+	AuthOAuth2  = "oauth2"
+	AuthHMAC    = "hmac"
+)
+
+// AuthConfig describes the Authenticator of a HomeBaseConnector, e.g. as read from flags or
+// environment variables.
+type AuthConfig struct {
+	// Type is one of the authentication types above. The default type uses APIKey for read
+	// requests and Token for write requests.
+	Type   string
+	APIKey string
+	Token  string
+
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	KeyID  string
+	Secret string
+}
+
+// ResolveSecrets returns a copy of the config whose APIKey, Token, ClientSecret and Secret are
+// resolved using ResolveSecret.
+func (config AuthConfig) ResolveSecrets(ctx context.Context) (AuthConfig, error) {
+	for _, secret := range []*string{&config.APIKey, &config.Token, &config.ClientSecret,
+		&config.Secret} {
+		resolved, err := ResolveSecret(ctx, *secret)
+		if err != nil {
+			return AuthConfig{}, err
+		}
+		*secret = resolved
+	}
+	return config, nil
+}
+
+// NewAuthenticator creates the Authenticator described by `config`.
+func NewAuthenticator(config AuthConfig) (Authenticator, error) {
+	switch config.Type {
+	case "", AuthDefault:
+		return SplitAuthenticator{APIKey{Key: config.APIKey}, BearerToken{config.Token}}, nil
+	case AuthBearer:
+		return BearerToken{config.Token}, nil
+	case AuthAPIKey:
+		return APIKey{Key: config.APIKey}, nil
+	case AuthOAuth2:
+		if config.TokenURL == "" || config.ClientID == "" {
+			return nil, errors.New("OAuth2 authentication requires a token URL and client id")
+		}
+		return NewOAuth2ClientCredentials(config.TokenURL, config.ClientID, config.ClientSecret,
+			config.Scopes...), nil
+	case AuthHMAC:
+		if config.KeyID == "" || config.Secret == "" {
+			return nil, errors.New("HMAC authentication requires a key id and secret")
+		}
+		return HMACSigner{KeyID: config.KeyID, Secret: []byte(config.Secret)}, nil
+	}
+	return nil, fmt.Errorf("unknown authentication type `%s` (available: %s)", config.Type,
+		strings.Join([]string{AuthAPIKey, AuthBearer, AuthDefault, AuthHMAC, AuthOAuth2}, ", "))
+}
+
+// DefaultSecretTTL is the time the secrets resolved by a ResolvingAuthenticator are reused.
+const DefaultSecretTTL = time.Minute
+
+// ResolvingAuthenticator resolves the secrets of Config (see AuthConfig.ResolveSecrets) once
+// they are older than TTL and after the API rejected them (see Invalidate), so rotated secrets
+// are used without a restart. The underlying Authenticator is only recreated if a secret
+// changed, so OAuth2 tokens stay cached in between.
+type ResolvingAuthenticator struct {
+	Config AuthConfig
+	// HTTPClient is used to request OAuth2 tokens. A shared client is used if it is nil.
+	HTTPClient *http.Client
+	// TTL is the time resolved secrets are reused (default: DefaultSecretTTL).
+	TTL time.Duration
+
+	mu            sync.Mutex
+	resolved      AuthConfig
+	resolvedAt    time.Time
+	authenticator Authenticator
+}
+
+// NewResolvingAuthenticator creates a ResolvingAuthenticator. It returns an error if `config`
+// does not describe a valid Authenticator, secrets are not resolved until the first request.
+func NewResolvingAuthenticator(config AuthConfig) (*ResolvingAuthenticator, error) {
+	if _, err := NewAuthenticator(config); err != nil {
+		return nil, err
+	}
+	return &ResolvingAuthenticator{Config: config}, nil
+}
+
+func (auth *ResolvingAuthenticator) Authenticate(req *http.Request, payload []byte) error {
+	authenticator, err := auth.current(req.Context())
+	if err != nil {
+		return err
+	}
+	return authenticator.Authenticate(req, payload)
+}
+
+// Invalidate invalidates the credentials of the underlying Authenticator, if it caches any, and
+// reports whether cached credentials were dropped. The secrets are resolved again for the next
+// request in any case.
+func (auth *ResolvingAuthenticator) Invalidate() bool {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+	auth.resolvedAt = time.Time{}
+	if invalidator, ok := auth.authenticator.(Invalidator); ok {
+		return invalidator.Invalidate()
+	}
+	return false
+}
+
+// current returns the Authenticator for the currently resolved secrets, which are resolved
+// again once they are older than TTL.
+func (auth *ResolvingAuthenticator) current(ctx context.Context) (Authenticator, error) {
+	ttl := auth.TTL
+	if ttl <= 0 {
+		ttl = DefaultSecretTTL
+	}
+	auth.mu.Lock()
+	if auth.authenticator != nil && time.Since(auth.resolvedAt) < ttl {
+		defer auth.mu.Unlock()
+		return auth.authenticator, nil
+	}
+	auth.mu.Unlock()
+
+	resolved, err := auth.Config.ResolveSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+	if auth.authenticator != nil && reflect.DeepEqual(resolved, auth.resolved) {
+		auth.resolvedAt = time.Now()
+		return auth.authenticator, nil
+	}
+	authenticator, err := NewAuthenticator(resolved)
+	if err != nil {
+		return nil, err
+	}
+	if oauth2, ok := authenticator.(*OAuth2ClientCredentials); ok {
+		oauth2.HTTPClient = auth.HTTPClient
+	}
+	auth.resolved, auth.resolvedAt, auth.authenticator = resolved, time.Now(), authenticator
+	return authenticator, nil
+}
@@ -0,0 +1,203 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAuthenticators(t *testing.T) {
+	var tests = []struct {
+		auth            Authenticator
+		method          string
+		expectedHeaders map[string]string
+	}{
+		{BearerToken{"token"}, http.MethodGet, map[string]string{"Authorization": "Bearer token"}},
+		{APIKey{Key: "key"}, http.MethodPut, map[string]string{"X-API-KEY": "key"}},
+		{APIKey{Key: "key", Header: "X-Key"}, http.MethodGet, map[string]string{"X-Key": "key"}},
+		{SplitAuthenticator{APIKey{Key: "key"}, BearerToken{"token"}}, http.MethodGet,
+			map[string]string{"X-API-KEY": "key", "Authorization": ""}},
+		{SplitAuthenticator{APIKey{Key: "key"}, BearerToken{"token"}}, http.MethodPost,
+			map[string]string{"X-API-KEY": "", "Authorization": "Bearer token"}},
+		{HMACSigner{KeyID: "crawler", Secret: []byte("secret"),
+			now: func() time.Time { return time.Unix(1535301120, 0) }}, http.MethodPut,
+			map[string]string{
+				"X-RC-Key-Id":    "crawler",
+				"X-RC-Timestamp": "1535301120",
+				"X-RC-Signature": "c6360ee8d84fb45abbcddc7d065910aa9b75c65d206c863916724bb3ec34cd87",
+			}},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method,
+			"https://api.radiochecker.com/stations/kronehit/tracks/1535301120?x=1", nil)
+		payload := []byte(`{"artist":"a","title":"b"}`)
+		if err := test.auth.Authenticate(req, payload); err != nil {
+			t.Errorf("%T Authenticate: got error (%v)", test.auth, err)
+		}
+		for header, expected := range test.expectedHeaders {
+			if value := req.Header.Get(header); value != expected {
+				t.Errorf("%T Authenticate (%s): got header %s `%s`, expected `%s`",
+					test.auth, test.method, header, value, expected)
+			}
+		}
+	}
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	var requests int32
+	expiresIn := 3600
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		clientID, clientSecret, _ := r.BasicAuth()
+		if clientID != "crawler" || clientSecret != "secret" ||
+			r.FormValue("grant_type") != "client_credentials" ||
+			r.FormValue("scope") != "tracks:write" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "bearer", "expires_in": %d}`,
+			n, expiresIn)
+	}))
+	defer server.Close()
+
+	auth := NewOAuth2ClientCredentials(server.URL, "crawler", "secret", "tracks:write")
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPut, "https://api.radiochecker.com/", nil)
+		if err := auth.Authenticate(req, nil); err != nil ||
+			req.Header.Get("Authorization") != "Bearer token-1" {
+			t.Errorf("Authenticate: got (%s, %v), expected cached token",
+				req.Header.Get("Authorization"), err)
+		}
+	}
+
+	// tokens expiring within the margin are refreshed
+	auth.expires = time.Now().Add(tokenExpiryMargin / 2)
+	req := httptest.NewRequest(http.MethodPut, "https://api.radiochecker.com/", nil)
+	if err := auth.Authenticate(req, nil); err != nil ||
+		req.Header.Get("Authorization") != "Bearer token-2" {
+		t.Errorf("Authenticate: got (%s, %v), expected refreshed token",
+			req.Header.Get("Authorization"), err)
+	}
+
+	// invalidated tokens are requested again
+	if !auth.Invalidate() || auth.Invalidate() {
+		t.Errorf("Invalidate: expected to drop the cached token only once")
+	}
+	if token, err := auth.Token(req.Context()); err != nil || token != "token-3" {
+		t.Errorf("Token: got (%s, %v) after Invalidate, expected new token", token, err)
+	}
+
+	auth = NewOAuth2ClientCredentials(server.URL, "crawler", "wrong", "tracks:write")
+	if _, err := auth.Token(req.Context()); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Token: got error (%v), expected (%v)", err, ErrUnauthorized)
+	}
+	if requests != 4 {
+		t.Errorf("Token: got %d token requests, expected 4", requests)
+	}
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	var tests = []struct {
+		config      AuthConfig
+		expectedErr bool
+	}{
+		{AuthConfig{APIKey: "key", Token: "token"}, false},
+		{AuthConfig{Type: AuthBearer, Token: "token"}, false},
+		{AuthConfig{Type: AuthAPIKey, APIKey: "key"}, false},
+		{AuthConfig{Type: AuthOAuth2, TokenURL: "https://auth/token", ClientID: "id"}, false},
+		{AuthConfig{Type: AuthOAuth2, ClientID: "id"}, true},
+		{AuthConfig{Type: AuthHMAC, KeyID: "id", Secret: "secret"}, false},
+		{AuthConfig{Type: AuthHMAC, KeyID: "id"}, true},
+		{AuthConfig{Type: "basic"}, true},
+	}
+
+	for _, test := range tests {
+		auth, err := NewAuthenticator(test.config)
+		if (err != nil) != test.expectedErr || (err == nil) != (auth != nil) {
+			t.Errorf("NewAuthenticator(%+v): got (%T, %v), expected error: (%v)",
+				test.config, auth, err, test.expectedErr)
+		}
+	}
+}
+
+func TestResolvingAuthenticator(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		_, clientSecret, _ := r.BasicAuth()
+		fmt.Fprintf(w, `{"access_token": "%s-%d", "expires_in": 3600}`, clientSecret, n)
+	}))
+	defer server.Close()
+
+	secretPath := filepath.Join(t.TempDir(), "client-secret")
+	if err := os.WriteFile(secretPath, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := NewResolvingAuthenticator(AuthConfig{Type: AuthOAuth2, TokenURL: server.URL,
+		ClientID: "crawler", ClientSecret: "file://" + secretPath})
+	if err != nil {
+		t.Fatalf("NewResolvingAuthenticator: got error (%v)", err)
+	}
+
+	var tests = []struct {
+		secret                string
+		invalidate            bool
+		expectedAuthorization string
+	}{
+		{"", false, "Bearer secret-1"},
+		// the token stays cached as long as the secret is unchanged
+		{"", false, "Bearer secret-1"},
+		{"", true, "Bearer secret-2"},
+		// a rotated secret is not resolved again until the API rejected the credentials
+		{"rotated", false, "Bearer secret-2"},
+		{"", true, "Bearer rotated-3"},
+		{"", false, "Bearer rotated-3"},
+	}
+
+	for i, test := range tests {
+		if test.secret != "" {
+			if err := os.WriteFile(secretPath, []byte(test.secret), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if test.invalidate {
+			auth.Invalidate()
+		}
+		req := httptest.NewRequest(http.MethodPut, "https://api.radiochecker.com/", nil)
+		if err := auth.Authenticate(req, nil); err != nil ||
+			req.Header.Get("Authorization") != test.expectedAuthorization {
+			t.Errorf("Authenticate #%d: got (%s, %v), expected %s", i,
+				req.Header.Get("Authorization"), err, test.expectedAuthorization)
+		}
+	}
+
+	// secrets older than the TTL are resolved again
+	auth.TTL = time.Nanosecond
+	if err := os.WriteFile(secretPath, []byte("expired"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPut, "https://api.radiochecker.com/", nil)
+	if err := auth.Authenticate(req, nil); err != nil ||
+		req.Header.Get("Authorization") != "Bearer expired-4" {
+		t.Errorf("Authenticate: got (%s, %v), expected Bearer expired-4",
+			req.Header.Get("Authorization"), err)
+	}
+
+	if err := os.Remove(secretPath); err != nil {
+		t.Fatal(err)
+	}
+	req = httptest.NewRequest(http.MethodPut, "https://api.radiochecker.com/", nil)
+	if err := auth.Authenticate(req, nil); err == nil {
+		t.Errorf("Authenticate: got no error for a missing secret")
+	}
+	if _, err := NewResolvingAuthenticator(AuthConfig{Type: AuthOAuth2}); err == nil {
+		t.Errorf("NewResolvingAuthenticator: got no error for an invalid config")
+	}
+}
@@ -0,0 +1,405 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/RadioCheckerApp/crawlers/fetcher"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Config describes all radio stations that should be crawled and where their TrackRecords are
+// stored.
+type Config struct {
+	Sink     SinkConfig      `json:"sink,omitempty" yaml:"sink,omitempty"`
+	Stations []StationConfig `json:"stations" yaml:"stations"`
+	// Notifiers deliver the alerts of the Watchdog.
+	Notifiers []NotifierConfig `json:"notifiers,omitempty" yaml:"notifiers,omitempty"`
+}
+
+// Sink types supported by SinkConfig.
+const (
+	SinkAPI      = "api"
+	SinkJSONL    = "jsonl"
+	SinkSQLite   = "sqlite"
+	SinkPostgres = "postgres"
+)
+
+// SinkConfig selects the storage of the TrackRecords. The RadioChecker API is used if Type is
+// empty or `api`; it is configured separately. Path and DSN may reference environment variables
+// using the `${NAME}` syntax, DSN may also be a secret reference (see ResolveSecret).
+type SinkConfig struct {
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+	// Path is the file used by the `jsonl` and `sqlite` sinks.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// DSN is the connection string used by the `postgres` sink.
+	DSN string `json:"dsn,omitempty" yaml:"dsn,omitempty"`
+
+	// Secondaries receive every TrackRecord persisted to this sink, see FanOutHomeBase. This
+	// sink stays authoritative for the latest TrackRecord of a station.
+	Secondaries []SinkConfig `json:"secondaries,omitempty" yaml:"secondaries,omitempty"`
+	// Policy is the FanOutPolicy applied if there are secondary sinks (default: `primary`).
+	Policy FanOutPolicy `json:"policy,omitempty" yaml:"policy,omitempty"`
+}
+
+// StationConfig describes a single radio station: the fetcher type that is used to crawl it, the
+// options passed to the fetcher's factory and the schedule the crawler should run on. The ID must
+// match the fetcher type, since the fetchers stamp the id of their station on the TrackRecords.
+// Option values may reference environment variables using the `${NAME}` syntax and secrets,
+// e.g. `file:///run/secrets/twitter-token` (see ResolveSecret).
+type StationConfig struct {
+	ID       string            `json:"id" yaml:"id"`
+	Fetcher  string            `json:"fetcher" yaml:"fetcher"`
+	Options  map[string]string `json:"options,omitempty" yaml:"options,omitempty"`
+	Schedule string            `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	// MaxGap is the longest time span without tracks that is not reported as gap, e.g. `45m`.
+	MaxGap string `json:"max_gap,omitempty" yaml:"max_gap,omitempty"`
+	// MaxAge is the age of the latest TrackRecord at which the Watchdog reports the station as
+	// stale, e.g. `3h`.
+	MaxAge string `json:"max_age,omitempty" yaml:"max_age,omitempty"`
+	// MaxFailureRate and MaxSkipRate are the percentages of fetched items that may fail to parse
+	// or be skipped before a crawler run fails, see Thresholds. They are nil if not configured,
+	// so that 0 can be used as the strictest threshold.
+	MaxFailureRate *float64 `json:"max_failure_rate,omitempty" yaml:"max_failure_rate,omitempty"`
+	MaxSkipRate    *float64 `json:"max_skip_rate,omitempty" yaml:"max_skip_rate,omitempty"`
+	// Lambda overrides the resources of the station's Lambda function.
+	Lambda LambdaConfig `json:"lambda,omitempty" yaml:"lambda,omitempty"`
+}
+
+// LambdaConfig sets the resources of the Lambda function generated for a station by
+// crawlers-aws/functions. Unset values use the defaults of the generator.
+type LambdaConfig struct {
+	// MemorySize is the memory of the function in MB, between 128 and 10240.
+	MemorySize int `json:"memory_size,omitempty" yaml:"memory_size,omitempty"`
+	// Timeout is the maximum duration of an invocation in whole seconds, e.g. `10s`, at most
+	// 15 minutes.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// LoadConfig reads and validates the station configuration stored at `path`. Files ending in
+// `.json` are decoded as JSON, all other files as YAML.
+func LoadConfig(path string) (Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return ParseConfig(data, filepath.Ext(path))
+}
+
+// ParseConfig decodes and validates a station configuration. `format` is either `json` or
+// `yaml`/`yml` and may be prefixed with a dot. Unknown fields are rejected in both formats.
+func ParseConfig(data []byte, format string) (Config, error) {
+	var config Config
+	var err error
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&config)
+	case "yaml", "yml", "":
+		err = yaml.UnmarshalStrict(data, &config)
+	default:
+		return Config{}, errors.New("unsupported config format `" + format + "`")
+	}
+	if err != nil {
+		return Config{}, errors.New("unable to decode config: " + err.Error())
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// Validate checks the sink, the notifiers and that every station has an unique id, refers to a
+// registered fetcher type matching its id and has a valid schedule, gap threshold, max age,
+// rates and Lambda resources, if any.
+func (config Config) Validate() error {
+	if len(config.Stations) == 0 {
+		return errors.New("config does not contain any stations")
+	}
+	if err := config.Sink.Validate(); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for i, station := range config.Stations {
+		if station.ID == "" {
+			return fmt.Errorf("station #%d: id must not be empty", i)
+		}
+		if seen[station.ID] {
+			return fmt.Errorf("station `%s`: duplicate id", station.ID)
+		}
+		seen[station.ID] = true
+
+		if !fetcher.IsRegistered(station.Fetcher) {
+			return fmt.Errorf("station `%s`: unknown fetcher type `%s` (available: %s)",
+				station.ID, station.Fetcher, strings.Join(fetcher.Types(), ", "))
+		}
+		if station.Fetcher != station.ID {
+			return fmt.Errorf("station `%s`: id must match the fetcher type `%s`, which stamps "+
+				"it on the TrackRecords", station.ID, station.Fetcher)
+		}
+
+		if station.Schedule != "" {
+			if _, err := ParseSchedule(station.Schedule); err != nil {
+				return fmt.Errorf("station `%s`: %s", station.ID, err)
+			}
+		}
+
+		if station.MaxGap != "" {
+			if maxGap, err := time.ParseDuration(station.MaxGap); err != nil || maxGap <= 0 {
+				return fmt.Errorf("station `%s`: invalid max_gap `%s`", station.ID,
+					station.MaxGap)
+			}
+		}
+
+		if station.MaxAge != "" {
+			if maxAge, err := time.ParseDuration(station.MaxAge); err != nil || maxAge <= 0 {
+				return fmt.Errorf("station `%s`: invalid max_age `%s`", station.ID,
+					station.MaxAge)
+			}
+		}
+
+		if !isPercentage(station.MaxFailureRate) {
+			return fmt.Errorf("station `%s`: max_failure_rate must be between 0 and 100",
+				station.ID)
+		}
+		if !isPercentage(station.MaxSkipRate) {
+			return fmt.Errorf("station `%s`: max_skip_rate must be between 0 and 100",
+				station.ID)
+		}
+		if err := station.Lambda.validate(); err != nil {
+			return fmt.Errorf("station `%s`: %s", station.ID, err)
+		}
+	}
+
+	for i, notifier := range config.Notifiers {
+		if err := notifier.Validate(); err != nil {
+			return fmt.Errorf("notifier #%d: %s", i+1, err)
+		}
+	}
+	return nil
+}
+
+// isPercentage reports whether the optional `rate` is unset or between 0 and 100.
+func isPercentage(rate *float64) bool {
+	return rate == nil || (*rate >= 0 && *rate <= 100)
+}
+
+func (lambda LambdaConfig) validate() error {
+	if lambda.MemorySize != 0 && (lambda.MemorySize < 128 || lambda.MemorySize > 10240) {
+		return errors.New("lambda memory_size must be between 128 and 10240")
+	}
+	if lambda.Timeout != "" {
+		timeout, err := time.ParseDuration(lambda.Timeout)
+		if err != nil || timeout < time.Second || timeout > 15*time.Minute ||
+			timeout%time.Second != 0 {
+			return fmt.Errorf("invalid lambda timeout `%s` (whole seconds up to 15m)",
+				lambda.Timeout)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the sink types are known, that their paths or DSNs are set and that the
+// fan-out policy is valid.
+func (sink SinkConfig) Validate() error {
+	switch sink.Type {
+	case "", SinkAPI:
+	case SinkJSONL, SinkSQLite:
+		if sink.Path == "" {
+			return fmt.Errorf("sink `%s`: path must not be empty", sink.Type)
+		}
+	case SinkPostgres:
+		if sink.DSN == "" {
+			return fmt.Errorf("sink `%s`: dsn must not be empty", sink.Type)
+		}
+	default:
+		return fmt.Errorf("unknown sink type `%s` (available: %s)", sink.Type,
+			strings.Join([]string{SinkAPI, SinkJSONL, SinkPostgres, SinkSQLite}, ", "))
+	}
+
+	if err := sink.Policy.validate(); err != nil {
+		return err
+	}
+	for i, secondary := range sink.Secondaries {
+		if len(secondary.Secondaries) > 0 || secondary.Policy != "" {
+			return fmt.Errorf("secondary sink #%d: secondaries cannot be nested", i+1)
+		}
+		if err := secondary.Validate(); err != nil {
+			return fmt.Errorf("secondary sink #%d: %s", i+1, err)
+		}
+	}
+	return nil
+}
+
+// UsesAPI reports whether the sink or any of its secondaries is the RadioChecker API.
+func (sink SinkConfig) UsesAPI() bool {
+	if sink.Type == "" || sink.Type == SinkAPI {
+		return true
+	}
+	for _, secondary := range sink.Secondaries {
+		if secondary.UsesAPI() {
+			return true
+		}
+	}
+	return false
+}
+
+// Open creates the configured sink, wrapped in a FanOutHomeBase if there are secondary sinks.
+// `api` is used for `api` sinks, since the API's host and credentials are configured
+// separately. It may be nil if UsesAPI returns false.
+func (sink SinkConfig) Open(api HomeBase) (HomeBase, error) {
+	primary, err := sink.open(api)
+	if err != nil || len(sink.Secondaries) == 0 {
+		return primary, err
+	}
+
+	fanOut := &FanOutHomeBase{Primary: primary, Policy: sink.Policy}
+	for i, secondaryConfig := range sink.Secondaries {
+		secondary, err := secondaryConfig.open(api)
+		if err != nil {
+			fanOut.Close()
+			return nil, fmt.Errorf("secondary sink #%d: %s", i+1, err)
+		}
+		fanOut.Secondaries = append(fanOut.Secondaries, secondary)
+	}
+	return fanOut, nil
+}
+
+func (sink SinkConfig) open(api HomeBase) (HomeBase, error) {
+	var sqlSink *SQLSink
+	var err error
+	switch sink.Type {
+	case "", SinkAPI:
+		if api == nil {
+			return nil, errors.New("sink `api` is not configured")
+		}
+		return api, nil
+	case SinkJSONL:
+		return NewJSONLSink(os.ExpandEnv(sink.Path)), nil
+	case SinkSQLite:
+		sqlSink, err = NewSQLiteSink(os.ExpandEnv(sink.Path))
+	case SinkPostgres:
+		var dsn string
+		dsn, err = ResolveSecret(context.Background(), os.ExpandEnv(sink.DSN))
+		if err == nil {
+			sqlSink, err = NewPostgresSink(dsn)
+		}
+	default:
+		return nil, fmt.Errorf("unknown sink type `%s`", sink.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open sink `%s`: %s", sink.Type, err)
+	}
+	return sqlSink, nil
+}
+
+// Station returns the configuration of the station with the provided id.
+func (config Config) Station(id string) (StationConfig, bool) {
+	for _, station := range config.Stations {
+		if station.ID == id {
+			return station, true
+		}
+	}
+	return StationConfig{}, false
+}
+
+// OpenNotifiers creates the configured notifiers.
+func (config Config) OpenNotifiers(ctx context.Context) ([]Notifier, error) {
+	var notifiers []Notifier
+	for i, notifierConfig := range config.Notifiers {
+		notifier, err := notifierConfig.Open(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("notifier #%d: %w", i+1, err)
+		}
+		notifiers = append(notifiers, notifier)
+	}
+	return notifiers, nil
+}
+
+// NewCrawlers creates a Crawler for every configured station.
+func (config Config) NewCrawlers(ctx context.Context, homeBase HomeBase) ([]Crawler, error) {
+	var crawlers []Crawler
+	for _, station := range config.Stations {
+		crawler, err := station.NewCrawler(ctx, homeBase)
+		if err != nil {
+			return nil, err
+		}
+		crawlers = append(crawlers, crawler)
+	}
+	return crawlers, nil
+}
+
+// GapThreshold returns the station's `max_gap`, or DefaultGapThreshold if it is not set.
+func (station StationConfig) GapThreshold() time.Duration {
+	maxGap, err := time.ParseDuration(station.MaxGap)
+	if err != nil || maxGap <= 0 {
+		return DefaultGapThreshold
+	}
+	return maxGap
+}
+
+// Thresholds returns the station's `max_failure_rate` and `max_skip_rate`.
+func (station StationConfig) Thresholds() Thresholds {
+	return Thresholds{MaxFailureRate: station.MaxFailureRate, MaxSkipRate: station.MaxSkipRate}
+}
+
+// NewFetcher creates a new instance of the station's fetcher. Environment variable references
+// in the option values are expanded and secrets are resolved before they are passed to the
+// fetcher's factory, so every fetcher uses the current secrets.
+func (station StationConfig) NewFetcher() (fetcher.Fetcher, error) {
+	options := make(map[string]string, len(station.Options))
+	for key, value := range station.Options {
+		option, err := ResolveSecret(context.Background(), os.ExpandEnv(value))
+		if err != nil {
+			return nil, fmt.Errorf("station `%s`: option `%s`: %w", station.ID, key, err)
+		}
+		options[key] = option
+	}
+
+	stationFetcher, err := fetcher.New(station.Fetcher, options)
+	if err != nil {
+		return nil, fmt.Errorf("station `%s`: unable to create fetcher: %s", station.ID, err)
+	}
+	return stationFetcher, nil
+}
+
+// NewCrawler creates a Crawler for the station using a fresh instance of its fetcher and the
+// station's thresholds.
+func (station StationConfig) NewCrawler(ctx context.Context, homeBase HomeBase) (Crawler, error) {
+	stationFetcher, err := station.NewFetcher()
+	if err != nil {
+		return Crawler{}, err
+	}
+
+	crawler, err := NewCrawlerContext(ctx, station.ID, stationFetcher, homeBase)
+	if err != nil {
+		return Crawler{}, fmt.Errorf("station `%s`: %w", station.ID, err)
+	}
+	return crawler.WithThresholds(station.Thresholds()), nil
+}
+
+// NewBackfillCrawler creates a crawler that backfills the station's history within [from, to]
+// using a fresh instance of its fetcher.
+func (station StationConfig) NewBackfillCrawler(homeBase HomeBase, from, to time.Time) (Crawler,
+	error) {
+	stationFetcher, err := station.NewFetcher()
+	if err != nil {
+		return Crawler{}, err
+	}
+
+	crawler, err := NewBackfillCrawler(station.ID, stationFetcher, homeBase, from, to)
+	if err != nil {
+		return Crawler{}, fmt.Errorf("station `%s`: %w", station.ID, err)
+	}
+	return crawler.WithThresholds(station.Thresholds()), nil
+}
@@ -0,0 +1,162 @@
+package crawler
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var validYAMLConfig = []byte(`
+stations:
+  - id: hitradio-oe3
+    fetcher: hitradio-oe3
+    schedule: rate(1 hour)
+    options:
+      consumer_key: ${TEST_CONSUMER_KEY}
+      consumer_key_secret: abcdefg
+      oauth_access_token: abcdefg
+      oauth_access_token_secret: abcdefg
+  - id: kronehit
+    fetcher: kronehit
+    max_gap: 45m
+    max_age: 3h
+    max_failure_rate: 20
+`)
+
+var validJSONConfig = []byte(`{"stations": [{"id": "kronehit", "fetcher": "kronehit"}]}`)
+
+func TestParseConfig(t *testing.T) {
+	const station = "stations:\n  - id: kronehit\n    fetcher: kronehit"
+	var tests = []struct {
+		data        []byte
+		format      string
+		expectedIDs []string
+		expectedErr bool
+	}{
+		{validYAMLConfig, ".yml", []string{"hitradio-oe3", "kronehit"}, false},
+		{validJSONConfig, "json", []string{"kronehit"}, false},
+		{validJSONConfig, ".toml", nil, true},
+		{[]byte(`{"stations": [{"id": "kronehit", "fetcher": "kronehit", "shedule": "@daily"}]}`),
+			"json", nil, true},
+		{[]byte("stations: []"), "yaml", nil, true},
+		{[]byte("stations:\n  - id: a\n    fetcher: unknown"), "yaml", nil, true},
+		{[]byte("stations:\n  - fetcher: kronehit"), "yaml", nil, true},
+		{[]byte("stations:\n  - id: kronehit-2\n    fetcher: kronehit"), "yaml", nil, true},
+		{[]byte(station + "\n" + strings.TrimPrefix(station, "stations:\n")), "yaml", nil, true},
+		{[]byte(station + "\n    unknown: field"), "yaml", nil, true},
+		{[]byte(station + "\n    schedule: rate(1 week)"), "yaml", nil, true},
+		{[]byte(station + "\n    max_gap: 45"), "yaml", nil, true},
+		{[]byte("sink:\n  type: jsonl\n  path: tracks.jsonl\n" + station), "yaml",
+			[]string{"kronehit"}, false},
+		{[]byte("sink:\n  type: sqlite\n" + station), "yaml", nil, true},
+		{[]byte("sink:\n  type: mysql\n" + station), "yaml", nil, true},
+		{[]byte(station + "\n    max_age: -1h"), "yaml", nil, true},
+		{[]byte(station + "\n    max_failure_rate: 120"), "yaml", nil, true},
+		{[]byte(station + "\n    max_skip_rate: -5"), "yaml", nil, true},
+		{[]byte(station + "\n    max_skip_rate: 0"), "yaml", []string{"kronehit"}, false},
+		{[]byte(station + "\n    lambda:\n      memory_size: 512\n      timeout: 10s"), "yaml",
+			[]string{"kronehit"}, false},
+		{[]byte(station + "\n    lambda:\n      memory_size: 64"), "yaml", nil, true},
+		{[]byte(station + "\n    lambda:\n      timeout: 1500ms"), "yaml", nil, true},
+		{[]byte(station + "\n    lambda:\n      timeout: 1h"), "yaml", nil, true},
+		{[]byte("notifiers:\n  - type: slack\n    url: https://hooks.example.com/x\n" + station),
+			"yaml", []string{"kronehit"}, false},
+		{[]byte("notifiers:\n  - type: email\n    smtp: localhost:25\n" + station), "yaml", nil,
+			true},
+		{[]byte("notifiers:\n  - type: pager\n" + station), "yaml", nil, true},
+	}
+
+	for _, test := range tests {
+		config, err := ParseConfig(test.data, test.format)
+		if (err != nil) != test.expectedErr {
+			t.Errorf("ParseConfig(%s, %q): got error: (%v), expected error: (%v)",
+				test.data, test.format, err, test.expectedErr)
+		}
+		if err != nil {
+			continue
+		}
+		var ids []string
+		for _, station := range config.Stations {
+			ids = append(ids, station.ID)
+		}
+		if !reflect.DeepEqual(ids, test.expectedIDs) {
+			t.Errorf("ParseConfig(%s, %q): got stations %v, expected %v",
+				test.data, test.format, ids, test.expectedIDs)
+		}
+	}
+}
+
+func TestStationConfig_NewCrawler(t *testing.T) {
+	config, err := ParseConfig(validYAMLConfig, "yaml")
+	if err != nil {
+		t.Fatalf("ParseConfig: got error: `%s`", err)
+	}
+	station, _ := config.Station("hitradio-oe3")
+
+	os.Setenv("TEST_CONSUMER_KEY", "")
+	if _, err := station.NewCrawler(context.Background(), MockHomeBaseSuccess{}); err == nil {
+		t.Errorf("NewCrawler: expected error for empty consumer key")
+	}
+
+	os.Setenv("TEST_CONSUMER_KEY", "env://TEST_MISSING_SECRET")
+	if _, err := station.NewCrawler(context.Background(), MockHomeBaseSuccess{}); err == nil {
+		t.Errorf("NewCrawler: expected error for unresolvable consumer key")
+	}
+
+	os.Setenv("TEST_CONSUMER_KEY", "abcdefg")
+	defer os.Unsetenv("TEST_CONSUMER_KEY")
+	crawlers, err := config.NewCrawlers(context.Background(), MockHomeBaseSuccess{})
+	if err != nil {
+		t.Fatalf("NewCrawlers: got error: `%s`", err)
+	}
+	if len(crawlers) != 2 || crawlers[0].stationId != "hitradio-oe3" ||
+		crawlers[1].stationId != "kronehit" {
+		t.Errorf("NewCrawlers: got %d crawlers, expected crawlers for `hitradio-oe3` and "+
+			"`kronehit`", len(crawlers))
+	}
+	if thresholds := crawlers[1].thresholds; thresholds.MaxFailureRate == nil ||
+		*thresholds.MaxFailureRate != 20 || thresholds.MaxSkipRate != nil {
+		t.Errorf("NewCrawlers: got thresholds %+v for `kronehit`", crawlers[1].thresholds)
+	}
+}
+
+func TestStationConfig_GapThreshold(t *testing.T) {
+	config, _ := ParseConfig(validYAMLConfig, "yaml")
+	var tests = []struct {
+		stationId         string
+		expectedThreshold time.Duration
+	}{
+		{"hitradio-oe3", DefaultGapThreshold},
+		{"kronehit", 45 * time.Minute},
+	}
+
+	for _, test := range tests {
+		station, _ := config.Station(test.stationId)
+		if threshold := station.GapThreshold(); threshold != test.expectedThreshold {
+			t.Errorf("GapThreshold(%q): got %s, expected %s",
+				test.stationId, threshold, test.expectedThreshold)
+		}
+	}
+}
+
+func TestStationConfig_MaxAgeThreshold(t *testing.T) {
+	config, _ := ParseConfig(validYAMLConfig, "yaml")
+	var tests = []struct {
+		stationId      string
+		expectedMaxAge time.Duration
+	}{
+		{"hitradio-oe3", DefaultMaxAge},
+		{"kronehit", 3 * time.Hour},
+	}
+
+	for _, test := range tests {
+		station, _ := config.Station(test.stationId)
+		if maxAge := station.MaxAgeThreshold(); maxAge != test.expectedMaxAge {
+			t.Errorf("MaxAgeThreshold(%q): got %s, expected %s",
+				test.stationId, maxAge, test.expectedMaxAge)
+		}
+	}
+}
@@ -1,42 +1,144 @@
 package crawler
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/RadioCheckerApp/api/model"
 	"github.com/RadioCheckerApp/crawlers/fetcher"
-	"log"
+	"github.com/RadioCheckerApp/crawlers/logging"
+	"github.com/RadioCheckerApp/crawlers/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"math"
 	"time"
 )
 
+// tracer creates the spans of the crawlers and the homebase connector, see package tracing.
+var tracer = otel.Tracer("github.com/RadioCheckerApp/crawlers/crawler")
+
+// maxEmptyPages is the number of pages in a row without any TrackRecord after which a run is
+// failed with ErrNoProgress.
+const maxEmptyPages = 5
+
+// ErrNoProgress is the error of a crawler run whose fetcher returned maxEmptyPages pages in a row
+// without any TrackRecord, e.g. because it failed to parse every item or its source returns the
+// same page over and over. Use errors.Is to check for it.
+var ErrNoProgress = errors.New("fetcher made no progress")
+
 type Crawler struct {
 	stationId                  string
 	fetcher                    fetcher.Fetcher
 	homeBase                   HomeBase
 	latestTrackRecordTimestamp int64
+	// backfillUntil is the timestamp of the newest TrackRecord persisted by a backfill crawler.
+	// It is 0 for regular crawlers.
+	backfillUntil int64
+	// outbox stores the TrackRecords that could not be persisted. It is optional.
+	outbox Outbox
+	// logger is used instead of the logger of the context passed to CrawlContext, if set.
+	logger *slog.Logger
+	// thresholds fail a run whose fetcher was unable to parse too many items.
+	thresholds Thresholds
+	// stop ends a run before the next fetch once it is closed. It is optional.
+	stop <-chan struct{}
 }
 
 func NewCrawler(stationId string, fetcher fetcher.Fetcher, homeBase HomeBase) (Crawler, error) {
+	return NewCrawlerContext(context.Background(), stationId, fetcher, homeBase)
+}
+
+// NewCrawlerContext is like NewCrawler but uses `ctx` to request the latest TrackRecord from the
+// homebase.
+func NewCrawlerContext(ctx context.Context, stationId string, fetcher fetcher.Fetcher,
+	homeBase HomeBase) (Crawler, error) {
 	if stationId == "" || fetcher == nil || homeBase == nil {
 		return Crawler{}, errors.New("invalid parameter(s) provided")
 	}
 
 	latestTrackRecordTimestamp := currentDayBeginTimestamp()
-	mostRecentTrackRecord, err := homeBase.getLatestTrackRecord(stationId)
-	if err != nil && err.Error() != "request did not return any data" {
-		return Crawler{}, errors.New("unable to fetch latest TrackRecord: " + err.Error())
+	mostRecentTrackRecord, err := homeBase.LatestTrackRecord(ctx, stationId)
+	if err != nil && !errors.Is(err, ErrNoData) {
+		return Crawler{}, fmt.Errorf("unable to fetch latest TrackRecord: %w", err)
 	} else if err == nil {
 		latestTrackRecordTimestamp = mostRecentTrackRecord.Timestamp
 	}
 
-	return Crawler{stationId, fetcher, homeBase, latestTrackRecordTimestamp}, nil
+	return Crawler{
+		stationId:                  stationId,
+		fetcher:                    fetcher,
+		homeBase:                   homeBase,
+		latestTrackRecordTimestamp: latestTrackRecordTimestamp,
+	}, nil
 }
 
-func currentDayBeginTimestamp() int64 {
-	loc, err := time.LoadLocation("Europe/Vienna")
-	if err != nil {
-		log.Fatal("FATAL:   Unable to load timezone `Europe/Vienna`.")
+// NewBackfillCrawler creates a crawler that persists all TrackRecords aired within [from, to],
+// regardless of the latest TrackRecord known to the homebase. The fetcher has to implement
+// fetcher.Seeker, it is positioned at `to` before the crawler is returned. Fetchers that
+// implement fetcher.Stopper are stopped at `from`.
+func NewBackfillCrawler(stationId string, stationFetcher fetcher.Fetcher, homeBase HomeBase,
+	from, to time.Time) (Crawler, error) {
+	if stationId == "" || stationFetcher == nil || homeBase == nil {
+		return Crawler{}, errors.New("invalid parameter(s) provided")
+	}
+	if !from.Before(to) {
+		return Crawler{}, errors.New("backfill range is empty: `from` must be before `to`")
+	}
+	seeker, ok := stationFetcher.(fetcher.Seeker)
+	if !ok {
+		return Crawler{}, errors.New("fetcher does not support backfilling")
 	}
-	timeInVienna := time.Now().In(loc)
+
+	// Seek is exclusive, the record aired at `to` should be included.
+	seeker.Seek(to.Add(time.Second))
+	if stopper, ok := stationFetcher.(fetcher.Stopper); ok {
+		stopper.StopAt(from)
+	}
+
+	return Crawler{
+		stationId:                  stationId,
+		fetcher:                    stationFetcher,
+		homeBase:                   homeBase,
+		latestTrackRecordTimestamp: from.Unix() - 1,
+		backfillUntil:              to.Unix(),
+	}, nil
+}
+
+// WithOutbox returns a copy of the crawler that adds TrackRecords which could not be persisted
+// to `outbox`, so that they can be replayed with ReplayOutbox later.
+func (crawler Crawler) WithOutbox(outbox Outbox) Crawler {
+	crawler.outbox = outbox
+	return crawler
+}
+
+// WithLogger returns a copy of the crawler that logs to `logger`. Each run adds the fields
+// `station`, `run` and `page` and passes the logger on to the fetcher and the homebase.
+func (crawler Crawler) WithLogger(logger *slog.Logger) Crawler {
+	crawler.logger = logger
+	return crawler
+}
+
+// WithThresholds returns a copy of the crawler that fails runs whose fetcher exceeds
+// `thresholds`. The default thresholds are used otherwise.
+func (crawler Crawler) WithThresholds(thresholds Thresholds) Crawler {
+	crawler.thresholds = thresholds
+	return crawler
+}
+
+// WithStop returns a copy of the crawler that stops fetching once `stop` is closed, e.g. on
+// shutdown. Unlike cancelling the context passed to CrawlContext, the TrackRecords that have
+// already been fetched are still persisted. A fetch in progress is cancelled.
+func (crawler Crawler) WithStop(stop <-chan struct{}) Crawler {
+	crawler.stop = stop
+	return crawler
+}
+
+func currentDayBeginTimestamp() int64 {
+	timeInVienna := time.Now().In(fetcher.Vienna)
 	todayMidnight := time.Date(
 		timeInVienna.Year(),
 		timeInVienna.Month(),
@@ -46,50 +148,285 @@ func currentDayBeginTimestamp() int64 {
 	return todayMidnight.AddDate(0, 0, -1).Unix()
 }
 
-func (crawler Crawler) Crawl() {
+// Crawl fetches and persists TrackRecords until the homebase is up to date and reports the
+// outcome of the run.
+func (crawler Crawler) Crawl() CrawlReport {
+	return crawler.CrawlContext(context.Background())
+}
+
+// CrawlContext is like Crawl but stops fetching and persisting TrackRecords once `ctx` is done.
+// A request that is in flight at that moment is cancelled as well.
+func (crawler Crawler) CrawlContext(ctx context.Context) CrawlReport {
+	report := CrawlReport{StationId: crawler.stationId, RunId: newRunId(), Start: time.Now()}
+	ctx, span := tracer.Start(ctx, "Crawl", trace.WithAttributes(
+		tracing.StationKey.String(crawler.stationId),
+		tracing.RunKey.String(report.RunId),
+		attribute.Bool("radiochecker.backfill", crawler.backfillUntil > 0)))
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("radiochecker.pages_fetched", report.PagesFetched),
+			attribute.Int("radiochecker.records_fetched", report.RecordsFetched),
+			attribute.Int("radiochecker.records_persisted", report.RecordsPersisted),
+			attribute.Int("radiochecker.records_failed", report.RecordsFailed),
+			attribute.Int("radiochecker.items_unparsed", report.FetchStats.Failed),
+			attribute.Bool("radiochecker.up_to_date", report.UpToDate))
+		tracing.End(span, report.Err)
+	}()
+
+	logger := crawler.logger
+	if logger == nil {
+		logger = logging.FromContext(ctx)
+	}
+	logger = logger.With("station", crawler.stationId, "run", report.RunId)
+	if span.SpanContext().IsValid() {
+		logger = logger.With("trace", span.SpanContext().TraceID().String())
+	}
+	ctx = logging.NewContext(ctx, logger)
+	stats := &fetcher.StatsCollector{}
+	ctx = fetcher.WithStatsCollector(ctx, stats)
+
 	if time.Now().Unix() <= crawler.latestTrackRecordTimestamp {
-		log.Println("INFO:    Crawler quit since latest TrackRecord is newer than current time.")
-		return
+		logger.Info("Crawler quit since latest TrackRecord is newer than current time.")
+		report.UpToDate = true
+		report.End = time.Now()
+		recordCrawlMetrics(report)
+		return report
 	}
 
-	overallPersistedCounter := 0
-	upToDate := false
-	var fetchErr error = nil
-	for !upToDate {
-		trackRecords, err := crawler.fetcher.Next()
+	// oldestFetched and resumedAt are used to resume backfills that hit the request limit of
+	// their fetcher. They ensure that every resumption makes progress.
+	var oldestFetched int64
+	resumedAt := int64(math.MaxInt64)
+	emptyPages := 0
+	for !report.UpToDate {
+		if err := ctx.Err(); err != nil {
+			report.Err = err
+			break
+		}
+		if crawler.stopped() {
+			report.Err = context.Canceled
+			break
+		}
+		page := report.PagesFetched + 1
+		pageCtx := logging.NewContext(ctx, logger.With("page", page))
+		fetchCtx, fetchSpan := tracer.Start(pageCtx, "Fetcher.Next", trace.WithAttributes(
+			tracing.StationKey.String(crawler.stationId), tracing.PageKey.Int(page)))
+		fetchStart := time.Now()
+		trackRecords, err := crawler.fetch(fetchCtx)
+		crawlerFetchDuration.WithLabelValues(crawler.stationId).
+			Observe(time.Since(fetchStart).Seconds())
+		fetchSpan.SetAttributes(attribute.Int("radiochecker.records", len(trackRecords)))
+		tracing.End(fetchSpan, err)
+		if errors.Is(err, fetcher.ErrRequestLimitExceeded) && oldestFetched < resumedAt &&
+			crawler.resumeBackfill(pageCtx, oldestFetched) {
+			resumedAt = oldestFetched
+			continue
+		}
+		if errors.Is(err, fetcher.ErrExhausted) && crawler.backfillUntil > 0 {
+			// the source has no TrackRecords aired before the ones fetched so far
+			logger.Info("Fetcher has no older TrackRecords, backfill is complete.")
+			report.UpToDate = true
+			break
+		}
 		if err != nil {
-			fetchErr = err
+			report.Err = err
 			break
 		}
-		persistedCounter, status := crawler.batchPersistTrackRecords(trackRecords)
-		upToDate = status
-		overallPersistedCounter += persistedCounter
+		for _, trackRecord := range trackRecords {
+			if oldestFetched == 0 || trackRecord.Timestamp < oldestFetched {
+				oldestFetched = trackRecord.Timestamp
+			}
+		}
+		report.PagesFetched++
+		report.RecordsFetched += len(trackRecords)
+		persistCtx, persistSpan := tracer.Start(pageCtx, "Crawler.persistTrackRecords",
+			trace.WithAttributes(tracing.StationKey.String(crawler.stationId),
+				tracing.PageKey.Int(page)))
+		report.UpToDate = crawler.batchPersistTrackRecords(persistCtx, trackRecords, &report)
+		tracing.End(persistSpan, report.Err)
+		if report.Err != nil {
+			break
+		}
+		// the thresholds are checked after every page, since a fetcher that fails to parse
+		// its source might never reach the latest TrackRecord
+		if err := crawler.thresholds.check(stats.Stats()); err != nil {
+			report.Err = err
+			break
+		}
+		if len(trackRecords) > 0 {
+			emptyPages = 0
+		} else if emptyPages++; emptyPages >= maxEmptyPages {
+			report.Err = fmt.Errorf("%w: %d pages in a row without TrackRecords", ErrNoProgress,
+				emptyPages)
+			break
+		}
+	}
+
+	report.FetchStats = stats.Stats()
+	if err := crawler.thresholds.check(report.FetchStats); err != nil &&
+		!errors.Is(report.Err, ErrThresholdExceeded) {
+		if report.Err == nil {
+			report.Err = err
+		} else {
+			report.Err = errors.Join(report.Err, err)
+		}
+	}
+	if errors.Is(report.Err, ErrThresholdExceeded) {
+		logger.Error("Fetcher exceeded threshold, the source might have changed its format.",
+			"items", report.FetchStats.Items, "failed", report.FetchStats.Failed,
+			"skipRate", report.FetchStats.SkipRate(), "err", report.Err)
 	}
 
-	if fetchErr != nil {
-		log.Printf("WARNING: Crawler finished with error. Message: `%s`.", fetchErr.Error())
+	if (ctx.Err() != nil || crawler.stopped()) && !report.UpToDate {
+		logger.Warn("Crawler stopped before it was up to date. TrackRecords older than the "+
+			"last persisted one are missing.", "err", report.Err)
+	} else if report.Err != nil {
+		logger.Warn("Crawler finished with error.", "err", report.Err)
 	}
 
-	if upToDate {
-		log.Println("INFO:    Crawler successfully updated records.")
+	if report.UpToDate {
+		logger.Info("Crawler successfully updated records.")
 	}
 
-	log.Printf("INFO:    %d TrackRecords persisted.", overallPersistedCounter)
+	logger.Info("TrackRecords persisted.", "persisted", report.RecordsPersisted,
+		"fetched", report.RecordsFetched, "failed", report.RecordsFailed)
+	report.End = time.Now()
+	recordCrawlMetrics(report)
+	return report
 }
 
-func (crawler Crawler) batchPersistTrackRecords(trackRecords []*model.TrackRecord) (int, bool) {
-	insertedTracksCounter := 0
-	for _, trackRecord := range trackRecords {
+// fetch fetches the next page of TrackRecords. The request is cancelled once the stop channel
+// set with WithStop is closed.
+func (crawler Crawler) fetch(ctx context.Context) ([]*model.TrackRecord, error) {
+	if crawler.stop == nil {
+		return fetcher.NextContext(ctx, crawler.fetcher)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-crawler.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return fetcher.NextContext(ctx, crawler.fetcher)
+}
+
+// stopped reports whether the stop channel set with WithStop is closed.
+func (crawler Crawler) stopped() bool {
+	select {
+	case <-crawler.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// resumeBackfill repositions the fetcher of a backfill crawler that ran into the request limit
+// of its fetcher. It reports whether the crawl can continue.
+func (crawler Crawler) resumeBackfill(ctx context.Context, oldestFetched int64) bool {
+	if crawler.backfillUntil == 0 || oldestFetched == 0 {
+		return false
+	}
+	seeker, ok := crawler.fetcher.(fetcher.Seeker)
+	if !ok {
+		return false
+	}
+	logging.FromContext(ctx).Info("Request limit reached while backfilling, continuing.",
+		"timestamp", oldestFetched)
+	seeker.Seek(time.Unix(oldestFetched, 0))
+	return true
+}
+
+// batchPersistTrackRecords persists all TrackRecords that are newer than the latest TrackRecord
+// known to the homebase, records the outcome in `report` and returns whether the homebase is up
+// to date. It stops early and sets `report.Err` if the homebase rejects the credentials.
+func (crawler Crawler) batchPersistTrackRecords(ctx context.Context,
+	trackRecords []*model.TrackRecord, report *CrawlReport) bool {
+	upToDate := false
+	var pending []*model.TrackRecord
+	for i, trackRecord := range trackRecords {
 		if trackRecord.Timestamp <= crawler.latestTrackRecordTimestamp {
-			return insertedTracksCounter, true
+			report.RecordsSkipped += len(trackRecords) - i
+			upToDate = true
+			break
 		}
-		err := crawler.homeBase.persistTrackRecord(trackRecord)
+		if crawler.backfillUntil > 0 && trackRecord.Timestamp > crawler.backfillUntil {
+			report.RecordsSkipped++
+			continue
+		}
+		pending = append(pending, trackRecord)
+	}
+
+	errs := crawler.persistTrackRecords(ctx, pending)
+	for i, err := range errs {
+		trackRecord := pending[i]
 		if err != nil {
-			log.Printf("ERROR:   Unable to persist TrackRecord: `%q`. Message: `%s`.",
-				trackRecord, err.Error())
+			logging.FromContext(ctx).Error("Unable to persist TrackRecord.",
+				"timestamp", trackRecord.Timestamp, "trackRecord", trackRecord, "err", err)
+			report.RecordsFailed++
+			crawler.addToOutbox(ctx, trackRecord, err, report)
+			if errors.Is(err, ErrUnauthorized) {
+				// all further requests would be rejected as well
+				report.Err = err
+			}
 			continue
 		}
-		insertedTracksCounter++
+		report.addPersisted(trackRecord)
+	}
+	return upToDate && len(errs) == len(pending) && report.Err == nil
+}
+
+// persistTrackRecords persists the TrackRecords with a single request if the homebase supports
+// it and returns the error of each attempted TrackRecord. It stops early if `ctx` is done or the
+// homebase rejects the credentials.
+func (crawler Crawler) persistTrackRecords(ctx context.Context,
+	trackRecords []*model.TrackRecord) []error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	if batchHomeBase, ok := crawler.homeBase.(BatchHomeBase); ok && len(trackRecords) > 1 {
+		return batchHomeBase.PersistTrackRecords(ctx, trackRecords)
+	}
+	return persistEach(ctx, crawler.homeBase, trackRecords)
+}
+
+// persistEach persists the TrackRecords one by one, see Crawler.persistTrackRecords.
+func persistEach(ctx context.Context, homeBase HomeBase,
+	trackRecords []*model.TrackRecord) []error {
+	var errs []error
+	for _, trackRecord := range trackRecords {
+		if ctx.Err() != nil {
+			break
+		}
+		err := homeBase.PersistTrackRecord(ctx, trackRecord)
+		errs = append(errs, err)
+		if errors.Is(err, ErrUnauthorized) {
+			break
+		}
+	}
+	return errs
+}
+
+func (crawler Crawler) addToOutbox(ctx context.Context, trackRecord *model.TrackRecord,
+	cause error, report *CrawlReport) {
+	if crawler.outbox == nil {
+		return
+	}
+	if err := crawler.outbox.Add(trackRecord, cause); err != nil {
+		logging.FromContext(ctx).Error("Unable to add TrackRecord to outbox.",
+			"timestamp", trackRecord.Timestamp, "trackRecord", trackRecord, "err", err)
+		return
+	}
+	report.RecordsOutboxed++
+}
+
+// newRunId returns a random id that identifies a crawler run in logs and reports.
+func newRunId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000")
 	}
-	return insertedTracksCounter, false
+	return hex.EncodeToString(b)
 }
@@ -1,17 +1,23 @@
 package crawler
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"github.com/RadioCheckerApp/api/model"
 	"github.com/RadioCheckerApp/crawlers/fetcher"
+	"log/slog"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
 
 type MockHomeBaseSuccess struct{}
 
-func (api MockHomeBaseSuccess) getLatestTrackRecord(stationId string) (*model.TrackRecord, error) {
+func (api MockHomeBaseSuccess) LatestTrackRecord(ctx context.Context,
+	stationId string) (*model.TrackRecord, error) {
 	return &model.TrackRecord{
 		"station-a",
 		1234567890,
@@ -20,7 +26,8 @@ func (api MockHomeBaseSuccess) getLatestTrackRecord(stationId string) (*model.Tr
 	}, nil
 }
 
-func (api MockHomeBaseSuccess) persistTrackRecord(trackRecord *model.TrackRecord) error {
+func (api MockHomeBaseSuccess) PersistTrackRecord(ctx context.Context,
+	trackRecord *model.TrackRecord) error {
 	if trackRecord.StationId == "fail" {
 		return errors.New("just a test")
 	}
@@ -29,14 +36,16 @@ func (api MockHomeBaseSuccess) persistTrackRecord(trackRecord *model.TrackRecord
 
 type MockHomeBaseFail struct{}
 
-func (api MockHomeBaseFail) getLatestTrackRecord(stationId string) (*model.TrackRecord, error) {
+func (api MockHomeBaseFail) LatestTrackRecord(ctx context.Context,
+	stationId string) (*model.TrackRecord, error) {
 	if stationId == "fail gracefully" {
-		return nil, errors.New("request did not return any data")
+		return nil, ErrNoData
 	}
 	return nil, errors.New("")
 }
 
-func (api MockHomeBaseFail) persistTrackRecord(trackRecord *model.TrackRecord) error {
+func (api MockHomeBaseFail) PersistTrackRecord(ctx context.Context,
+	trackRecord *model.TrackRecord) error {
 	return errors.New("")
 }
 
@@ -68,9 +77,14 @@ func TestNewCrawler_Success(t *testing.T) {
 			}
 			continue
 		}
-		expectedCrawler := Crawler{test.stationId, test.fetcher, test.homeBase, 1234567890}
+		expectedCrawler := Crawler{
+			stationId:                  test.stationId,
+			fetcher:                    test.fetcher,
+			homeBase:                   test.homeBase,
+			latestTrackRecordTimestamp: 1234567890,
+		}
 		if !reflect.DeepEqual(crawler, expectedCrawler) {
-			t.Errorf("NewCrawler: got\n(%q, %v), expected\n(%q, nil)", crawler, err, expectedCrawler)
+			t.Errorf("NewCrawler: got\n(%v, %v), expected\n(%v, nil)", crawler, err, expectedCrawler)
 		}
 	}
 }
@@ -95,7 +109,72 @@ func TestCrawler_Crawl_QuitIfUpToDate(t *testing.T) {
 		homeBase:                   MockHomeBaseSuccess{},
 		latestTrackRecordTimestamp: time.Now().AddDate(0, 0, 1).Unix(),
 	}
-	crawler.Crawl()
+	if report := crawler.Crawl(); !report.UpToDate || report.PagesFetched != 0 {
+		t.Errorf("Crawler Crawl: got report `%+v`, expected up to date report without fetches",
+			report)
+	}
+}
+
+func TestCrawler_Crawl_Report(t *testing.T) {
+	crawler := Crawler{
+		stationId: "station-a",
+		fetcher: &MockFetcher{batches: [][]*model.TrackRecord{
+			trackRecordBatch0, trackRecordBatch1}},
+		homeBase:                   MockHomeBaseSuccess{},
+		latestTrackRecordTimestamp: 1535301300,
+	}
+
+	report := crawler.Crawl()
+	expectedReport := CrawlReport{
+		StationId:        "station-a",
+		PagesFetched:     1,
+		RecordsFetched:   3,
+		RecordsPersisted: 1,
+		RecordsSkipped:   2,
+		UpToDate:         true,
+		NewestTimestamp:  1535301540,
+		OldestTimestamp:  1535301540,
+	}
+	if len(report.RunId) != 16 {
+		t.Errorf("Crawler Crawl: got run id `%s`, expected 16 hex digits", report.RunId)
+	}
+	report.Start, report.End, report.RunId = time.Time{}, time.Time{}, ""
+	if !reflect.DeepEqual(report, expectedReport) {
+		t.Errorf("Crawler Crawl: got report\n`%+v`, expected\n`%+v`", report, expectedReport)
+	}
+}
+
+func TestCrawler_WithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	crawler := Crawler{
+		stationId: "station-a",
+		fetcher: &MockFetcher{batches: [][]*model.TrackRecord{
+			trackRecordBatch2}},
+		homeBase:                   MockHomeBaseFail{},
+		latestTrackRecordTimestamp: 1535301000,
+	}.WithLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	report := crawler.Crawl()
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) == 0 || len(lines[0]) == 0 {
+		t.Fatalf("Crawler WithLogger: nothing logged")
+	}
+	pages := 0
+	for _, line := range lines {
+		var record map[string]interface{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("Crawler WithLogger: unable to decode `%s`: %s", line, err)
+		}
+		if record["station"] != "station-a" || record["run"] != report.RunId {
+			t.Errorf("Crawler WithLogger: missing station or run id in `%s`", line)
+		}
+		if _, ok := record["page"]; ok {
+			pages++
+		}
+	}
+	if pages == 0 {
+		t.Errorf("Crawler WithLogger: no line contains the page")
+	}
 }
 
 var trackRecordBatch0 = []*model.TrackRecord{
@@ -120,23 +199,269 @@ func TestCrawler_batchPersistTrackRecords(t *testing.T) {
 		trackRecords                []*model.TrackRecord
 		expectedInsertedTracksCount int
 		expectedUpToDate            bool
+		expectedReport              CrawlReport
 	}{
-		{trackRecordBatch0, 3, false},
-		{trackRecordBatch1, 1, true},
-		{trackRecordBatch2, 2, false},
+		{trackRecordBatch0, 3, false, CrawlReport{RecordsPersisted: 3,
+			NewestTimestamp: 1535301540, OldestTimestamp: 1535301120}},
+		{trackRecordBatch1, 1, true, CrawlReport{RecordsPersisted: 1, RecordsSkipped: 1,
+			NewestTimestamp: 1535301540, OldestTimestamp: 1535301540}},
+		{trackRecordBatch2, 2, false, CrawlReport{RecordsPersisted: 2, RecordsFailed: 1,
+			NewestTimestamp: 1535301540, OldestTimestamp: 1535301120}},
 	}
 	crawler := Crawler{latestTrackRecordTimestamp: 1234567890, homeBase: MockHomeBaseSuccess{}}
 
 	for _, test := range tests {
-		insertedTracksCount, upToDate := crawler.batchPersistTrackRecords(test.trackRecords)
+		var report CrawlReport
+		upToDate := crawler.batchPersistTrackRecords(context.Background(), test.trackRecords,
+			&report)
+		insertedTracksCount := report.RecordsPersisted
+		if !reflect.DeepEqual(report, test.expectedReport) {
+			t.Errorf("Crawler batchPersistTrackRecords(%v): got report: `%+v`, expected: `%+v`",
+				test.trackRecords, report, test.expectedReport)
+		}
 		if insertedTracksCount != test.expectedInsertedTracksCount {
-			t.Errorf("Crawler batchPersistTrackRecords(%q): got insertedTracksCount: `%d`, "+
+			t.Errorf("Crawler batchPersistTrackRecords(%v): got insertedTracksCount: `%d`, "+
 				"expected: `%d`", test.trackRecords, insertedTracksCount, test.expectedInsertedTracksCount)
 		}
 
 		if upToDate != test.expectedUpToDate {
-			t.Errorf("Crawler batchPersistTrackRecords(%q): got upToDate: `%v`, "+
+			t.Errorf("Crawler batchPersistTrackRecords(%v): got upToDate: `%v`, "+
 				"expected: `%v`", test.trackRecords, upToDate, test.expectedUpToDate)
 		}
 	}
 }
+
+type MockFetcher struct {
+	batches [][]*model.TrackRecord
+	calls   int
+}
+
+func (fetcher *MockFetcher) Next() ([]*model.TrackRecord, error) {
+	if fetcher.calls >= len(fetcher.batches) {
+		return nil, errors.New("no more batches")
+	}
+	defer func() { fetcher.calls++ }()
+	return fetcher.batches[fetcher.calls], nil
+}
+
+type MockHomeBaseCounting struct {
+	persisted int
+	cancel    context.CancelFunc
+}
+
+func (api *MockHomeBaseCounting) LatestTrackRecord(ctx context.Context,
+	stationId string) (*model.TrackRecord, error) {
+	return nil, ErrNoData
+}
+
+func (api *MockHomeBaseCounting) PersistTrackRecord(ctx context.Context,
+	trackRecord *model.TrackRecord) error {
+	api.persisted++
+	if api.cancel != nil {
+		api.cancel()
+	}
+	return ctx.Err()
+}
+
+func TestCrawler_CrawlContext_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	homeBase := &MockHomeBaseCounting{cancel: cancel}
+	mockFetcher := &MockFetcher{batches: [][]*model.TrackRecord{trackRecordBatch0, trackRecordBatch1}}
+	crawler := Crawler{
+		stationId:                  "station-a",
+		fetcher:                    mockFetcher,
+		homeBase:                   homeBase,
+		latestTrackRecordTimestamp: 1234567890,
+	}
+
+	report := crawler.CrawlContext(ctx)
+
+	if report.Err != context.Canceled || report.UpToDate || report.RecordsFailed != 1 {
+		t.Errorf("Crawler CrawlContext: got report `%+v`, expected cancelled run with 1 failed "+
+			"record", report)
+	}
+	if homeBase.persisted != 1 {
+		t.Errorf("Crawler CrawlContext: got %d persist calls, expected 1", homeBase.persisted)
+	}
+	if mockFetcher.calls != 1 {
+		t.Errorf("Crawler CrawlContext: got %d fetches, expected 1", mockFetcher.calls)
+	}
+}
+
+func TestCrawler_CrawlContext_Stopped(t *testing.T) {
+	stop := make(chan struct{})
+	var once sync.Once
+	homeBase := &MockHomeBaseCounting{cancel: func() { once.Do(func() { close(stop) }) }}
+	mockFetcher := &MockFetcher{batches: [][]*model.TrackRecord{
+		trackRecordBatch0, trackRecordBatch1}}
+	crawler := Crawler{
+		stationId:                  "station-a",
+		fetcher:                    mockFetcher,
+		homeBase:                   homeBase,
+		latestTrackRecordTimestamp: 1234567890,
+	}.WithStop(stop)
+
+	report := crawler.CrawlContext(context.Background())
+
+	// the page fetched before the stop is persisted completely, the next one is not fetched
+	if report.Err != context.Canceled || report.UpToDate || report.RecordsPersisted != 3 {
+		t.Errorf("Crawler CrawlContext: got report `%+v`, expected stopped run with 3 "+
+			"persisted records", report)
+	}
+	if mockFetcher.calls != 1 {
+		t.Errorf("Crawler CrawlContext: got %d fetches, expected 1", mockFetcher.calls)
+	}
+}
+
+type MockSeekingFetcher struct {
+	MockFetcher
+	seekedTo []time.Time
+	limit    int
+}
+
+func (mock *MockSeekingFetcher) Next() ([]*model.TrackRecord, error) {
+	if mock.calls >= mock.limit {
+		return nil, fetcher.ErrRequestLimitExceeded
+	}
+	return mock.MockFetcher.Next()
+}
+
+// Seek allows a single request until the next call to Seek.
+func (mock *MockSeekingFetcher) Seek(t time.Time) {
+	mock.seekedTo = append(mock.seekedTo, t)
+	mock.limit = mock.calls + 1
+}
+
+func TestNewBackfillCrawler(t *testing.T) {
+	from, to := time.Unix(1535301120, 0), time.Unix(1535301540, 0)
+	var tests = []struct {
+		fetcher     fetcher.Fetcher
+		from, to    time.Time
+		expectedErr bool
+	}{
+		{&MockSeekingFetcher{}, from, to, false},
+		{&MockSeekingFetcher{}, to, from, true},
+		{&MockFetcher{}, from, to, true},
+	}
+
+	for _, test := range tests {
+		crawler, err := NewBackfillCrawler("station-a", test.fetcher, MockHomeBaseSuccess{},
+			test.from, test.to)
+		if (err != nil) != test.expectedErr {
+			t.Errorf("NewBackfillCrawler(%s, %s): got error: (%v), expected error: (%v)",
+				test.from, test.to, err, test.expectedErr)
+		}
+		if err != nil {
+			continue
+		}
+		if crawler.latestTrackRecordTimestamp != from.Unix()-1 ||
+			crawler.backfillUntil != to.Unix() {
+			t.Errorf("NewBackfillCrawler(%s, %s): got range (%d, %d]", test.from, test.to,
+				crawler.latestTrackRecordTimestamp, crawler.backfillUntil)
+		}
+		seekedTo := test.fetcher.(*MockSeekingFetcher).seekedTo
+		if len(seekedTo) != 1 || !seekedTo[0].Equal(to.Add(time.Second)) {
+			t.Errorf("NewBackfillCrawler(%s, %s): got Seek calls %v", test.from, test.to, seekedTo)
+		}
+	}
+}
+
+func TestCrawler_Crawl_Backfill(t *testing.T) {
+	mockFetcher := &MockSeekingFetcher{MockFetcher: MockFetcher{batches: [][]*model.TrackRecord{
+		trackRecordBatch0[:1],
+		trackRecordBatch0[1:2],
+		trackRecordBatch0[2:],
+	}}}
+	// only the record aired at 1535301300 is inside the backfill range
+	crawler, _ := NewBackfillCrawler("station-a", mockFetcher, MockHomeBaseSuccess{},
+		time.Unix(1535301200, 0), time.Unix(1535301400, 0))
+
+	report := crawler.Crawl()
+
+	if report.RecordsPersisted != 1 || report.NewestTimestamp != 1535301300 ||
+		report.RecordsSkipped != 2 || !report.UpToDate || report.Err != nil {
+		t.Errorf("Crawler Crawl: got backfill report `%+v`", report)
+	}
+	// initial Seek plus one resumption per request limit hit
+	if len(mockFetcher.seekedTo) != 3 {
+		t.Errorf("Crawler Crawl: got Seek calls %v, expected 3", mockFetcher.seekedTo)
+	}
+}
+
+// MockExhaustingFetcher returns ErrExhausted once its batches are used up, like a fetcher that
+// reached the end of its source.
+type MockExhaustingFetcher struct {
+	MockSeekingFetcher
+	stoppedAt []time.Time
+}
+
+func (mock *MockExhaustingFetcher) Next() ([]*model.TrackRecord, error) {
+	if mock.calls >= len(mock.batches) {
+		return nil, fetcher.ErrExhausted
+	}
+	return mock.MockFetcher.Next()
+}
+
+func (mock *MockExhaustingFetcher) StopAt(t time.Time) {
+	mock.stoppedAt = append(mock.stoppedAt, t)
+}
+
+func TestCrawler_Crawl_Exhausted(t *testing.T) {
+	from, to := time.Unix(1535301000, 0), time.Unix(1535301600, 0)
+	mockFetcher := &MockExhaustingFetcher{MockSeekingFetcher: MockSeekingFetcher{
+		MockFetcher: MockFetcher{batches: [][]*model.TrackRecord{trackRecordBatch0}}}}
+	backfillCrawler, _ := NewBackfillCrawler("station-a", mockFetcher, MockHomeBaseSuccess{},
+		from, to)
+
+	// a backfill ends once the fetcher has no older TrackRecords
+	report := backfillCrawler.Crawl()
+	if report.RecordsPersisted != 3 || !report.UpToDate || report.Err != nil {
+		t.Errorf("Crawler Crawl: got backfill report `%+v`", report)
+	}
+	if len(mockFetcher.stoppedAt) != 1 || !mockFetcher.stoppedAt[0].Equal(from) {
+		t.Errorf("NewBackfillCrawler: got StopAt calls %v, expected %s", mockFetcher.stoppedAt,
+			from)
+	}
+
+	// a regular crawl that did not reach the latest TrackRecord misses TrackRecords
+	crawler := Crawler{
+		stationId:                  "station-a",
+		fetcher:                    &MockExhaustingFetcher{},
+		homeBase:                   MockHomeBaseSuccess{},
+		latestTrackRecordTimestamp: 1234567890,
+	}
+	report = crawler.Crawl()
+	if !errors.Is(report.Err, fetcher.ErrExhausted) || report.UpToDate {
+		t.Errorf("Crawler Crawl: got report `%+v`, expected error (%v)", report,
+			fetcher.ErrExhausted)
+	}
+}
+
+type MockHomeBaseUnauthorized struct {
+	MockHomeBaseCounting
+}
+
+func (api *MockHomeBaseUnauthorized) PersistTrackRecord(ctx context.Context,
+	trackRecord *model.TrackRecord) error {
+	api.persisted++
+	return &HomeBaseError{StatusCode: 401, Err: ErrUnauthorized}
+}
+
+func TestCrawler_Crawl_Unauthorized(t *testing.T) {
+	homeBase := &MockHomeBaseUnauthorized{}
+	crawler := Crawler{
+		stationId:                  "station-a",
+		fetcher:                    &MockFetcher{batches: [][]*model.TrackRecord{trackRecordBatch0}},
+		homeBase:                   homeBase,
+		latestTrackRecordTimestamp: 1234567890,
+	}
+
+	report := crawler.Crawl()
+
+	if !errors.Is(report.Err, ErrUnauthorized) || report.RecordsFailed != 1 {
+		t.Errorf("Crawler Crawl: got report `%+v`, expected run aborted after 1 record", report)
+	}
+	if homeBase.persisted != 1 {
+		t.Errorf("Crawler Crawl: got %d persist calls, expected 1", homeBase.persisted)
+	}
+}
@@ -0,0 +1,12 @@
+// Package drivers registers the database drivers used by the SQLite and PostgreSQL sinks of the
+// crawler package. Programs opening the sinks of a configuration import it for its side effects:
+//
+//	import _ "github.com/RadioCheckerApp/crawlers/crawler/drivers"
+//
+// The SQLite driver requires cgo, binaries built with CGO_ENABLED=0 fail to open SQLite sinks.
+package drivers
+
+import (
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
@@ -0,0 +1,102 @@
+package crawler
+
+import (
+	"errors"
+	"github.com/RadioCheckerApp/api/model"
+	"github.com/RadioCheckerApp/crawlers/fakehomebase"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// The tests in this file run crawlers against the fake RadioChecker API.
+
+func newFakeHomeBase() (*fakehomebase.Server, HomeBaseConnector, func()) {
+	server := fakehomebase.New("key", "token")
+	server.Put(model.TrackRecord{"station-a", 1234567890, "track",
+		model.Track{"rhcp", "californication"}})
+	api, shutdown := newTestHomeBase(server.ServeHTTP)
+	api.APIKey, api.APIAuthorization = "key", "token"
+	return server, api, shutdown
+}
+
+func TestCrawler_Crawl_FakeHomeBase(t *testing.T) {
+	for _, disableBatch := range []bool{false, true} {
+		server, api, shutdown := newFakeHomeBase()
+		server.DisableBatch = disableBatch
+		fetcher := &MockFetcher{batches: [][]*model.TrackRecord{trackRecordBatch0,
+			trackRecordBatch1}}
+
+		crawler, err := NewCrawler("station-a", fetcher, api)
+		if err != nil {
+			shutdown()
+			t.Fatalf("NewCrawler (batch disabled: %v): got error (%v)", disableBatch, err)
+		}
+		report := crawler.Crawl()
+		shutdown()
+
+		if report.Err != nil || !report.UpToDate || report.RecordsPersisted != 4 {
+			t.Errorf("Crawler Crawl (batch disabled: %v): got report `%+v`", disableBatch, report)
+		}
+		trackRecords := server.TrackRecords("station-a")
+		if len(trackRecords) != 4 || trackRecords[3].Timestamp != 1535301540 ||
+			trackRecords[3].Track.Title != "River" {
+			t.Errorf("Crawler Crawl (batch disabled: %v): got stored TrackRecords %v",
+				disableBatch, trackRecords)
+		}
+	}
+}
+
+func TestNewCrawler_FakeHomeBase(t *testing.T) {
+	server, api, shutdown := newFakeHomeBase()
+	defer shutdown()
+
+	crawler, err := NewCrawler("station-a", &MockFetcher{}, api)
+	if err != nil || crawler.latestTrackRecordTimestamp != 1234567890 {
+		t.Errorf("NewCrawler: got (%d, %v), expected (1234567890, nil)",
+			crawler.latestTrackRecordTimestamp, err)
+	}
+
+	crawler, err = NewCrawler("station-b", &MockFetcher{}, api)
+	if err != nil || crawler.latestTrackRecordTimestamp != currentDayBeginTimestamp() {
+		t.Errorf("NewCrawler: got (%d, %v) for station without TrackRecords",
+			crawler.latestTrackRecordTimestamp, err)
+	}
+
+	api.APIKey = "wrong"
+	if _, err := NewCrawler("station-a", &MockFetcher{}, api); !errors.Is(err,
+		ErrUnauthorized) {
+		t.Errorf("NewCrawler: got error (%v) for wrong API key, expected (%v)", err,
+			ErrUnauthorized)
+	}
+
+	if server.Requests() != 3 {
+		t.Errorf("NewCrawler: got %d requests, expected 3", server.Requests())
+	}
+}
+
+func TestCrawler_Crawl_FakeHomeBase_Failures(t *testing.T) {
+	server, api, shutdown := newFakeHomeBase()
+	defer shutdown()
+	api.RetryPolicy = &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond,
+		RetryableStatusCodes: []int{http.StatusServiceUnavailable}}
+
+	crawler, err := NewCrawler("station-a", &MockFetcher{batches: [][]*model.TrackRecord{
+		trackRecordBatch1}}, api)
+	if err != nil {
+		t.Fatalf("NewCrawler: got error (%v)", err)
+	}
+	server.FailNext(2, http.StatusServiceUnavailable)
+	report := crawler.Crawl()
+	if report.Err != nil || report.RecordsPersisted != 1 {
+		t.Errorf("Crawler Crawl: got report `%+v` after retried failures", report)
+	}
+
+	crawler.fetcher = &MockFetcher{batches: [][]*model.TrackRecord{trackRecordBatch0}}
+	api.APIAuthorization = "wrong"
+	crawler.homeBase = api
+	report = crawler.Crawl()
+	if !errors.Is(report.Err, ErrUnauthorized) || report.RecordsFailed != 3 {
+		t.Errorf("Crawler Crawl: got report `%+v` for wrong authorization", report)
+	}
+}
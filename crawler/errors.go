@@ -0,0 +1,89 @@
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors returned by the HomeBaseConnector, wrapped in a *HomeBaseError. Use errors.Is to check
+// for them.
+var (
+	// ErrNoData is returned if the homebase has no data for the request, e.g. because no
+	// TrackRecord has been persisted for a station yet.
+	ErrNoData = errors.New("request did not return any data")
+	// ErrEndpointNotFound is returned for a 404 without the API's envelope, i.e. if the
+	// homebase does not provide the requested endpoint at all.
+	ErrEndpointNotFound = errors.New("homebase does not provide the endpoint")
+	// ErrUnauthorized is returned if the homebase rejected the API key or authorization.
+	ErrUnauthorized = errors.New("homebase rejected the credentials")
+	// ErrRateLimited is returned if the homebase throttled the request.
+	ErrRateLimited = errors.New("homebase rate limit exceeded")
+	// ErrServer is returned if the homebase failed to handle the request.
+	ErrServer = errors.New("homebase server error")
+	// ErrBadRequest is returned if the homebase rejected the request for any other reason.
+	ErrBadRequest = errors.New("homebase rejected the request")
+	// ErrMalformedResponse is returned if the response does not match the API's envelope
+	// `{"success": bool, "message": string, "data": any}` or its data cannot be decoded.
+	ErrMalformedResponse = errors.New("malformed homebase response")
+)
+
+// HomeBaseError describes a failed request to the homebase.
+type HomeBaseError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Message is the `message` of the response, or details on why the response is malformed.
+	Message string
+	// Err is one of the errors listed above.
+	Err error
+}
+
+func (err *HomeBaseError) Error() string {
+	message := err.Err.Error()
+	if err.StatusCode != 0 {
+		message = fmt.Sprintf("%s (status %d)", message, err.StatusCode)
+	}
+	if err.Message != "" {
+		message += ": " + err.Message
+	}
+	return message
+}
+
+func (err *HomeBaseError) Unwrap() error {
+	return err.Err
+}
+
+// statusError returns the error described by the status code of a response, or nil if the
+// request succeeded. A 404 only means that there is no data if the response carries the API's
+// envelope with `success: false`, e.g. a proxy or a misconfigured base URL answers without it.
+func statusError(statusCode int, body []byte) error {
+	var envelope struct {
+		Success *bool  `json:"success"`
+		Message string `json:"message"`
+	}
+	json.Unmarshal(body, &envelope)
+
+	var cause error
+	switch {
+	case statusCode >= 200 && statusCode < 300:
+		return nil
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		cause = ErrUnauthorized
+	case statusCode == http.StatusNotFound && envelope.Success != nil && !*envelope.Success:
+		cause = ErrNoData
+	case statusCode == http.StatusNotFound:
+		cause = ErrEndpointNotFound
+	case statusCode == http.StatusTooManyRequests:
+		cause = ErrRateLimited
+	case statusCode >= 500:
+		cause = ErrServer
+	default:
+		cause = ErrBadRequest
+	}
+	return &HomeBaseError{StatusCode: statusCode, Message: envelope.Message, Err: cause}
+}
+
+func malformedResponseError(format string, args ...interface{}) error {
+	return &HomeBaseError{Message: fmt.Sprintf(format, args...), Err: ErrMalformedResponse}
+}
@@ -0,0 +1,48 @@
+package crawler
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestStatusError(t *testing.T) {
+	var tests = []struct {
+		statusCode      int
+		body            string
+		expectedErr     error
+		expectedMessage string
+	}{
+		{http.StatusOK, `{"success": true}`, nil, ""},
+		{http.StatusCreated, `{"success": true}`, nil, ""},
+		{http.StatusUnauthorized, `{"success": false, "message": "Unauthorized"}`,
+			ErrUnauthorized, "homebase rejected the credentials (status 401): Unauthorized"},
+		{http.StatusForbidden, ``, ErrUnauthorized,
+			"homebase rejected the credentials (status 403)"},
+		{http.StatusNotFound, `{"success": false, "message": "no tracks"}`, ErrNoData,
+			"request did not return any data (status 404): no tracks"},
+		{http.StatusNotFound, `{"success": false}`, ErrNoData,
+			"request did not return any data (status 404)"},
+		{http.StatusNotFound, `404 page not found`, ErrEndpointNotFound,
+			"homebase does not provide the endpoint (status 404)"},
+		{http.StatusNotFound, `{"message": "no route"}`, ErrEndpointNotFound,
+			"homebase does not provide the endpoint (status 404): no route"},
+		{http.StatusTooManyRequests, `Too Many Requests`, ErrRateLimited,
+			"homebase rate limit exceeded (status 429)"},
+		{http.StatusBadGateway, `<html></html>`, ErrServer, "homebase server error (status 502)"},
+		{http.StatusBadRequest, `{"success": false, "message": "invalid track"}`, ErrBadRequest,
+			"homebase rejected the request (status 400): invalid track"},
+	}
+
+	for _, test := range tests {
+		err := statusError(test.statusCode, []byte(test.body))
+		if !errors.Is(err, test.expectedErr) {
+			t.Errorf("statusError(%d): got error (%v), expected (%v)",
+				test.statusCode, err, test.expectedErr)
+		}
+		if err != nil && err.Error() != test.expectedMessage {
+			t.Errorf("statusError(%d): got message `%s`, expected `%s`",
+				test.statusCode, err.Error(), test.expectedMessage)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/RadioCheckerApp/api/model"
+	"github.com/RadioCheckerApp/crawlers/logging"
+	"io"
+	"sync"
+	"time"
+)
+
+// FanOutPolicy determines which sinks of a FanOutHomeBase have to persist a TrackRecord for the
+// TrackRecord to be considered persisted.
+type FanOutPolicy string
+
+const (
+	// FanOutAll requires every sink to succeed.
+	FanOutAll FanOutPolicy = "all"
+	// FanOutPrimary requires the primary sink to succeed. Failures of secondary sinks are only
+	// logged.
+	FanOutPrimary FanOutPolicy = "primary"
+	// FanOutBestEffort requires any sink to succeed.
+	FanOutBestEffort FanOutPolicy = "best-effort"
+)
+
+// FanOutHomeBase persists every TrackRecord to several sinks at the same time, e.g. to migrate
+// from one homebase to another. The primary sink is authoritative: the latest TrackRecord and
+// the history of a station are requested from it only.
+type FanOutHomeBase struct {
+	Primary     HomeBase
+	Secondaries []HomeBase
+	// Policy defaults to FanOutPrimary.
+	Policy FanOutPolicy
+}
+
+func (fanOut *FanOutHomeBase) LatestTrackRecord(ctx context.Context,
+	stationId string) (*model.TrackRecord, error) {
+	return fanOut.Primary.LatestTrackRecord(ctx, stationId)
+}
+
+func (fanOut *FanOutHomeBase) TrackRecords(ctx context.Context, stationId string,
+	from, to time.Time) ([]*model.TrackRecord, error) {
+	history, ok := fanOut.Primary.(TrackHistory)
+	if !ok {
+		return nil, errors.New("the primary sink is unable to list TrackRecords")
+	}
+	return history.TrackRecords(ctx, stationId, from, to)
+}
+
+// PersistTrackRecord persists the TrackRecord to all sinks concurrently. If the policy is not
+// met, the error of the first failed sink is returned, starting with the primary sink.
+func (fanOut *FanOutHomeBase) PersistTrackRecord(ctx context.Context,
+	trackRecord *model.TrackRecord) error {
+	sinks := append([]HomeBase{fanOut.Primary}, fanOut.Secondaries...)
+	errs := make([]error, len(sinks))
+	var wg sync.WaitGroup
+	for i, sink := range sinks {
+		wg.Add(1)
+		go func(i int, sink HomeBase) {
+			defer wg.Done()
+			errs[i] = sink.PersistTrackRecord(ctx, trackRecord)
+		}(i, sink)
+	}
+	wg.Wait()
+
+	var firstErr error
+	failed := 0
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		failed++
+		if firstErr == nil {
+			firstErr = err
+		}
+		if i > 0 {
+			logging.FromContext(ctx).Warn("Secondary sink was unable to persist TrackRecord.",
+				"sink", i, "timestamp", trackRecord.Timestamp, "trackRecord", trackRecord,
+				"err", err)
+		}
+	}
+
+	switch fanOut.Policy {
+	case FanOutAll:
+		return firstErr
+	case FanOutBestEffort:
+		if failed == len(sinks) {
+			return firstErr
+		}
+		return nil
+	default:
+		return errs[0]
+	}
+}
+
+// Close closes all sinks that hold resources.
+func (fanOut *FanOutHomeBase) Close() error {
+	var firstErr error
+	for _, sink := range append([]HomeBase{fanOut.Primary}, fanOut.Secondaries...) {
+		if closer, ok := sink.(io.Closer); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (policy FanOutPolicy) validate() error {
+	switch policy {
+	case "", FanOutAll, FanOutPrimary, FanOutBestEffort:
+		return nil
+	}
+	return fmt.Errorf("unknown fan-out policy `%s` (available: %s, %s, %s)", policy,
+		FanOutAll, FanOutBestEffort, FanOutPrimary)
+}
@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFanOutHomeBase_PersistTrackRecord(t *testing.T) {
+	ok, fail := MockHomeBaseSuccess{}, MockHomeBaseFail{}
+	var tests = []struct {
+		policy      FanOutPolicy
+		primary     HomeBase
+		secondaries []HomeBase
+		expectedErr bool
+	}{
+		{FanOutAll, ok, []HomeBase{ok, ok}, false},
+		{FanOutAll, ok, []HomeBase{ok, fail}, true},
+		{FanOutPrimary, ok, []HomeBase{fail, fail}, false},
+		{FanOutPrimary, fail, []HomeBase{ok}, true},
+		{"", fail, []HomeBase{ok}, true},
+		{FanOutBestEffort, fail, []HomeBase{fail, ok}, false},
+		{FanOutBestEffort, fail, []HomeBase{fail}, true},
+	}
+
+	for _, test := range tests {
+		fanOut := &FanOutHomeBase{test.primary, test.secondaries, test.policy}
+		err := fanOut.PersistTrackRecord(context.Background(), trackRecordBatch0[0])
+		if (err != nil) != test.expectedErr {
+			t.Errorf("FanOutHomeBase PersistTrackRecord (policy `%s`, primary %T, "+
+				"secondaries %T): got error (%v), expected error: (%v)", test.policy,
+				test.primary, test.secondaries, err, test.expectedErr)
+		}
+	}
+}
+
+func TestFanOutHomeBase_LatestTrackRecord(t *testing.T) {
+	fanOut := &FanOutHomeBase{Primary: MockHomeBaseFail{}, Secondaries: []HomeBase{
+		MockHomeBaseSuccess{}}}
+	if _, err := fanOut.LatestTrackRecord(context.Background(), "station-a"); err == nil {
+		t.Errorf("FanOutHomeBase LatestTrackRecord: expected error of the primary sink")
+	}
+}
+
+func TestSinkConfig_Open_FanOut(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := SinkConfig{
+		Type:   SinkJSONL,
+		Path:   filepath.Join(dir, "primary.jsonl"),
+		Policy: FanOutAll,
+		Secondaries: []SinkConfig{
+			{Type: SinkAPI},
+			{Type: SinkJSONL, Path: filepath.Join(dir, "secondary.jsonl")},
+		},
+	}
+	if err := config.Validate(); err != nil || !config.UsesAPI() {
+		t.Fatalf("SinkConfig Validate: got (%v, UsesAPI: %v)", err, config.UsesAPI())
+	}
+	if _, err := config.Open(nil); err == nil {
+		t.Errorf("SinkConfig Open: expected error without API")
+	}
+
+	homeBase, err := config.Open(MockHomeBaseSuccess{})
+	if err != nil {
+		t.Fatalf("SinkConfig Open: got error (%v)", err)
+	}
+	ctx := context.Background()
+	if err := homeBase.PersistTrackRecord(ctx, trackRecordBatch0[0]); err != nil {
+		t.Fatalf("PersistTrackRecord: got error (%v)", err)
+	}
+	secondary := NewJSONLSink(filepath.Join(dir, "secondary.jsonl"))
+	for _, sink := range []HomeBase{homeBase, secondary} {
+		latest, err := sink.LatestTrackRecord(ctx, "station-a")
+		if err != nil || latest.Timestamp != trackRecordBatch0[0].Timestamp {
+			t.Errorf("LatestTrackRecord (%T): got (%v, %v)", sink, latest, err)
+		}
+	}
+
+	nested := SinkConfig{Secondaries: []SinkConfig{{Secondaries: []SinkConfig{{}}}}}
+	if err := nested.Validate(); err == nil {
+		t.Errorf("SinkConfig Validate: expected error for nested secondaries")
+	}
+	if err := (SinkConfig{Policy: "most"}).Validate(); err == nil {
+		t.Errorf("SinkConfig Validate: expected error for unknown policy")
+	}
+}
@@ -0,0 +1,84 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"github.com/RadioCheckerApp/api/model"
+	"time"
+)
+
+// DefaultGapThreshold is used by FindGaps for stations that do not configure `max_gap`.
+const DefaultGapThreshold = 30 * time.Minute
+
+// gapWindow is the time span requested from the homebase at once.
+const gapWindow = 24 * time.Hour
+
+// ErrHistoryUnavailable is returned if the homebase does not provide the endpoint
+// `GET /stations/{id}/tracks?from=&to=`, which lists the TrackRecords of a station aired within
+// a time range and is required to find gaps. Use errors.Is to check for it.
+var ErrHistoryUnavailable = errors.New("homebase is unable to list the TrackRecords of a station")
+
+// Gap is a time span in which no TrackRecords have been persisted for a station. From and To
+// are the airtimes of the tracks enclosing the gap (or the bounds of the analyzed window), so a
+// gap can directly be used as range of a backfill run.
+type Gap struct {
+	StationId string    `json:"stationId"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+}
+
+// Duration returns the length of the gap.
+func (gap Gap) Duration() time.Duration {
+	return gap.To.Sub(gap.From)
+}
+
+// FindGaps requests the TrackRecords of the station aired within [from, to] from the homebase
+// and returns all intervals without tracks that are longer than `threshold`.
+func FindGaps(ctx context.Context, history TrackHistory, stationId string, from, to time.Time,
+	threshold time.Duration) ([]Gap, error) {
+	if !from.Before(to) {
+		return nil, errors.New("`from` must be before `to`")
+	}
+	if threshold <= 0 {
+		threshold = DefaultGapThreshold
+	}
+
+	var trackRecords []*model.TrackRecord
+	for windowStart := from; windowStart.Before(to); windowStart = windowStart.Add(gapWindow) {
+		// the windows exclude their end, which is the start of the next window, so that no
+		// TrackRecord is requested twice. Timestamps are in seconds, hence [start, end-1s] is
+		// requested. The last window includes `to`.
+		windowEnd := windowStart.Add(gapWindow - time.Second)
+		if !windowEnd.Before(to) {
+			windowEnd = to
+		}
+		records, err := history.TrackRecords(ctx, stationId, windowStart, windowEnd)
+		if err != nil {
+			return nil, err
+		}
+		trackRecords = append(trackRecords, records...)
+	}
+
+	return findGaps(stationId, trackRecords, from, to, threshold), nil
+}
+
+// findGaps expects `trackRecords` to be ordered by their timestamp.
+func findGaps(stationId string, trackRecords []*model.TrackRecord, from, to time.Time,
+	threshold time.Duration) []Gap {
+	var gaps []Gap
+	previous := from
+	for _, trackRecord := range trackRecords {
+		airtime := time.Unix(trackRecord.Timestamp, 0)
+		if airtime.Before(from) || airtime.After(to) {
+			continue
+		}
+		if airtime.Sub(previous) > threshold {
+			gaps = append(gaps, Gap{stationId, previous, airtime})
+		}
+		previous = airtime
+	}
+	if to.Sub(previous) > threshold {
+		gaps = append(gaps, Gap{stationId, previous, to})
+	}
+	return gaps
+}
@@ -0,0 +1,78 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"github.com/RadioCheckerApp/api/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type MockTrackHistory struct {
+	trackRecords []*model.TrackRecord
+	requests     int
+	returned     int
+}
+
+func (history *MockTrackHistory) TrackRecords(ctx context.Context, stationId string,
+	from, to time.Time) ([]*model.TrackRecord, error) {
+	history.requests++
+	if stationId == "fail" {
+		return nil, errors.New("just a test")
+	}
+	var trackRecords []*model.TrackRecord
+	for _, trackRecord := range history.trackRecords {
+		if trackRecord.Timestamp >= from.Unix() && trackRecord.Timestamp <= to.Unix() {
+			trackRecords = append(trackRecords, trackRecord)
+		}
+	}
+	history.returned += len(trackRecords)
+	return trackRecords, nil
+}
+
+func TestFindGaps(t *testing.T) {
+	base := time.Unix(1535300000, 0)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+	history := &MockTrackHistory{trackRecords: []*model.TrackRecord{
+		{"station-a", at(10).Unix(), "track", model.Track{"Katy Perry", "Last Friday Night"}},
+		{"station-a", at(14).Unix(), "track", model.Track{"Simon Lewis", "Hey Jessy"}},
+		{"station-a", at(80).Unix(), "track", model.Track{"Alan Walker", "Faded"}},
+		{"station-a", at(90).Unix(), "track", model.Track{"Harry Styles", "Sign of the Times"}},
+		// aired at the end of the first window
+		{"station-a", at(1440).Unix(), "track", model.Track{"Alan Walker", "Faded"}},
+		{"station-a", at(1500).Unix(), "track", model.Track{"Eminem feat. Ed Sheeran", "River"}},
+	}}
+
+	gaps, err := FindGaps(context.Background(), history, "station-a", at(0), at(1510),
+		30*time.Minute)
+	if err != nil {
+		t.Fatalf("FindGaps: got error `%s`", err)
+	}
+
+	expectedGaps := []Gap{
+		{"station-a", at(14), at(80)},
+		{"station-a", at(90), at(1440)},
+		{"station-a", at(1440), at(1500)},
+	}
+	if !reflect.DeepEqual(gaps, expectedGaps) {
+		t.Errorf("FindGaps: got\n%v, expected\n%v", gaps, expectedGaps)
+	}
+	if history.requests != 2 || history.returned != 6 {
+		t.Errorf("FindGaps: got %d requests returning %d TrackRecords, expected 2 requests "+
+			"(one per day) returning each of the 6 TrackRecords once", history.requests,
+			history.returned)
+	}
+
+	gaps, _ = FindGaps(context.Background(), history, "station-a", at(100), at(200), 0)
+	if len(gaps) != 1 || !gaps[0].From.Equal(at(100)) || gaps[0].Duration() != 100*time.Minute {
+		t.Errorf("FindGaps: got %v, expected a single gap covering the whole window", gaps)
+	}
+
+	if _, err := FindGaps(context.Background(), history, "fail", at(0), at(10), 0); err == nil {
+		t.Errorf("FindGaps: expected error of the homebase to be returned")
+	}
+	if _, err := FindGaps(context.Background(), history, "station-a", at(10), at(0), 0); err == nil {
+		t.Errorf("FindGaps: expected error for empty window")
+	}
+}
@@ -2,138 +2,456 @@ package crawler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/RadioCheckerApp/api/model"
+	"github.com/RadioCheckerApp/crawlers/logging"
+	"github.com/RadioCheckerApp/crawlers/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
+// HomeBase stores the TrackRecords of the crawlers, e.g. the RadioChecker API (see
+// HomeBaseConnector) or one of the sinks. Other storages can be used by implementing it.
 type HomeBase interface {
-	getLatestTrackRecord(stationId string) (*model.TrackRecord, error)
-	persistTrackRecord(trackRecord *model.TrackRecord) error
+	// LatestTrackRecord returns the newest TrackRecord persisted for the station. The error
+	// wraps ErrNoData if there is none.
+	LatestTrackRecord(ctx context.Context, stationId string) (*model.TrackRecord, error)
+	// PersistTrackRecord stores the TrackRecord, replacing the one of the station aired at the
+	// same time, if any.
+	PersistTrackRecord(ctx context.Context, trackRecord *model.TrackRecord) error
 }
 
+// TrackHistory is implemented by homebases that are able to list the TrackRecords persisted
+// for a station.
+type TrackHistory interface {
+	// TrackRecords returns the TrackRecords of the station aired within [from, to], ordered
+	// by their timestamp.
+	TrackRecords(ctx context.Context, stationId string, from, to time.Time) (
+		[]*model.TrackRecord, error)
+}
+
+// BatchHomeBase is implemented by homebases that are able to persist several TrackRecords with
+// a single request.
+type BatchHomeBase interface {
+	HomeBase
+	// PersistTrackRecords returns the error of each attempted TrackRecord, in order. It stops
+	// early if `ctx` is done or the homebase rejects the credentials.
+	PersistTrackRecords(ctx context.Context, trackRecords []*model.TrackRecord) []error
+}
+
+// maxBatchSize is the maximum number of TrackRecords sent in a single batch request.
+const maxBatchSize = 100
+
+// batchUnsupported holds the base URLs of the homebases that turned out not to support batch
+// requests, so that they are not sent a failing batch request by every crawler run. It is
+// shared by all copies of a HomeBaseConnector and lasts for the lifetime of the process.
+var batchUnsupported sync.Map
+
 type HomeBaseConnector struct {
-	APIHost          string
+	// APIHost is the host of the API, which is called using HTTPS. It is ignored if BaseURL is
+	// set.
+	APIHost string
+	// BaseURL is the URL the endpoint paths are appended to, e.g. `http://localhost:8080/v1`.
+	BaseURL string
+	// APIKey and APIAuthorization are sent as `X-API-KEY` header with GET requests and as
+	// bearer token with all other requests, unless Authenticator is set.
 	APIKey           string
 	APIAuthorization string
+	// Authenticator adds the credentials to every request, see NewAuthenticator.
+	Authenticator Authenticator
+	// HTTPClient is used for all requests, see NewHTTPClient. A shared client with a timeout of
+	// 5 seconds is used if it is nil.
+	HTTPClient *http.Client
+	// RetryPolicy is applied to every request. DefaultRetryPolicy is used if it is nil.
+	RetryPolicy *RetryPolicy
+	// DisableBatch persists TrackRecords one by one, even if several are persisted at once.
+	// Homebases that do not support batch requests are detected automatically, once per
+	// process.
+	DisableBatch bool
+	// Logger is used for requests whose context does not carry a logger (see package logging).
+	// slog.Default() is used if both are missing.
+	Logger *slog.Logger
 }
 
-func (api HomeBaseConnector) getLatestTrackRecord(stationId string) (*model.TrackRecord, error) {
-	url := fmt.Sprintf("https://%s/stations/%s/tracks?filter=latest",
-		api.APIHost, stationId)
+func (api HomeBaseConnector) LatestTrackRecord(ctx context.Context,
+	stationId string) (*model.TrackRecord, error) {
+	url := api.url("/stations/%s/tracks?filter=latest", stationId)
 
-	responseData, err := api.callEndpoint(http.MethodGet, url, nil)
+	responseData, err := api.callEndpoint(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		log.Printf("WARNING: Unable to get latest TrackRecord from endpoint `%s %s`. "+
-			"Message: `%s`.", http.MethodGet, url, err.Error())
+		api.logger(ctx).Warn("Unable to get latest TrackRecord.", "method", http.MethodGet,
+			"url", url, "err", err)
 		return nil, err
 	}
 
-	responseDataMap := responseData.(map[string]interface{})
+	responseDataMap, ok := responseData.(map[string]interface{})
+	if !ok {
+		api.logger(ctx).Error("Latest TrackRecord is not a JSON object.", "data", responseData)
+		return nil, malformedResponseError("latest TrackRecord is not a JSON object")
+	}
 	trackRecordJSON, _ := json.Marshal(responseDataMap)
 
 	var latestTrackRecord model.TrackRecord
 	err = json.Unmarshal(trackRecordJSON, &latestTrackRecord)
 	if err != nil {
-		log.Printf("ERROR:   Unable to unmarshal JSON object into TrackRecord. Message: `%s`.",
-			err.Error())
-		return nil, err
+		api.logger(ctx).Error("Unable to unmarshal JSON object into TrackRecord.", "err", err)
+		return nil, malformedResponseError("%s", err.Error())
 	}
 
 	return &latestTrackRecord, nil
 }
 
-func (api HomeBaseConnector) persistTrackRecord(trackRecord *model.TrackRecord) error {
-	url := fmt.Sprintf("https://%s/stations/%s/tracks/%d",
-		api.APIHost, trackRecord.StationId, trackRecord.Timestamp)
+func (api HomeBaseConnector) TrackRecords(ctx context.Context, stationId string,
+	from, to time.Time) ([]*model.TrackRecord, error) {
+	url := api.url("/stations/%s/tracks?from=%d&to=%d", stationId, from.Unix(), to.Unix())
+
+	responseData, err := api.callEndpoint(ctx, http.MethodGet, url, nil)
+	var homeBaseErr *HomeBaseError
+	if errors.As(err, &homeBaseErr) && isMissingEndpoint(homeBaseErr) {
+		return nil, fmt.Errorf("%w: `GET /stations/{id}/tracks?from=&to=` is not supported "+
+			"(status %d)", ErrHistoryUnavailable, homeBaseErr.StatusCode)
+	} else if errors.Is(err, ErrNoData) {
+		return nil, nil
+	} else if err != nil {
+		api.logger(ctx).Warn("Unable to get TrackRecords.", "method", http.MethodGet, "url", url,
+			"err", err)
+		return nil, err
+	}
+	if _, ok := responseData.([]interface{}); !ok && responseData != nil {
+		// an API that ignores the `from` and `to` params might answer with a single TrackRecord
+		return nil, fmt.Errorf("%w: `GET /stations/{id}/tracks?from=&to=` did not return a "+
+			"list of TrackRecords", ErrHistoryUnavailable)
+	}
+
+	trackRecordsJSON, _ := json.Marshal(responseData)
+
+	var trackRecords []*model.TrackRecord
+	err = json.Unmarshal(trackRecordsJSON, &trackRecords)
+	if err != nil {
+		api.logger(ctx).Error("Unable to unmarshal JSON array into TrackRecords.", "err", err)
+		return nil, malformedResponseError("%s", err.Error())
+	}
+
+	sort.Slice(trackRecords, func(i, j int) bool {
+		return trackRecords[i].Timestamp < trackRecords[j].Timestamp
+	})
+	return trackRecords, nil
+}
+
+func (api HomeBaseConnector) PersistTrackRecord(ctx context.Context,
+	trackRecord *model.TrackRecord) error {
+	url := api.url("/stations/%s/tracks/%d", trackRecord.StationId, trackRecord.Timestamp)
 
 	payload, err := json.Marshal(trackRecord.Track)
 	if err != nil {
-		log.Printf("ERROR:   Unable to marshal Track to JSON. Message: `%s`.", err.Error())
+		api.logger(ctx).Error("Unable to marshal Track to JSON.", "err", err)
 		return err
 	}
 
-	responseData, err := api.callEndpoint(http.MethodPut, url, bytes.NewBuffer(payload))
+	responseData, err := api.callEndpoint(ctx, http.MethodPut, url, payload)
 	if err != nil {
-		log.Printf("ERROR:   Unable call endpoint `%s %s`. Message: `%s`.",
-			http.MethodPut, url, err.Error())
+		api.logger(ctx).Error("Unable to call endpoint.", "method", http.MethodPut, "url", url,
+			"err", err)
 		return err
 	}
 
-	log.Printf("INFO:    TrackRecord persisted. Message: `%s`.", responseData)
+	api.logger(ctx).Info("TrackRecord persisted.", "timestamp", trackRecord.Timestamp,
+		"response", responseData)
 	return nil
 }
 
-func (api HomeBaseConnector) callEndpoint(method, url string, payload io.Reader) (interface{},
-	error) {
-	responseBody, err := api.sendHTTPRequest(method, url, payload)
+// batchResult is the outcome of a single TrackRecord of a batch request.
+type batchResult struct {
+	Timestamp int64  `json:"timestamp"`
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+}
+
+// PersistTrackRecords sends the TrackRecords of each station with a single
+// `POST /stations/{id}/tracks` request per `maxBatchSize` TrackRecords. TrackRecords are sent
+// one by one if the homebase does not support batch requests.
+func (api HomeBaseConnector) PersistTrackRecords(ctx context.Context,
+	trackRecords []*model.TrackRecord) []error {
+	if _, unsupported := batchUnsupported.Load(api.url("")); api.DisableBatch || unsupported {
+		return persistEach(ctx, api, trackRecords)
+	}
+
+	var errs []error
+	for len(trackRecords) > 0 && ctx.Err() == nil {
+		n := 1
+		for n < len(trackRecords) && n < maxBatchSize &&
+			trackRecords[n].StationId == trackRecords[0].StationId {
+			n++
+		}
+		batchErrs, supported := api.persistBatch(ctx, trackRecords[:n])
+		if !supported {
+			api.logger(ctx).Warn("Homebase does not support batch requests, persisting "+
+				"TrackRecords one by one.", "trackRecords", len(trackRecords))
+			batchUnsupported.Store(api.url(""), true)
+			return append(errs, persistEach(ctx, api, trackRecords)...)
+		}
+		errs = append(errs, batchErrs...)
+		if errors.Is(batchErrs[0], ErrUnauthorized) {
+			break
+		}
+		trackRecords = trackRecords[n:]
+	}
+	return errs
+}
+
+// persistBatch persists TrackRecords of the same station with a single request and returns the
+// error of each TrackRecord. It reports whether the homebase supports batch requests, which is
+// assumed to be false if the endpoint is missing or the response cannot be matched to the
+// TrackRecords.
+func (api HomeBaseConnector) persistBatch(ctx context.Context,
+	trackRecords []*model.TrackRecord) ([]error, bool) {
+	url := api.url("/stations/%s/tracks", trackRecords[0].StationId)
+
+	payload, err := json.Marshal(trackRecords)
 	if err != nil {
-		log.Printf("ERROR:   Unable to read body of response to `%s %s`. Message: `%s`.",
-			method, url, err.Error())
+		api.logger(ctx).Error("Unable to marshal TrackRecords to JSON.", "err", err)
+		return repeatError(err, len(trackRecords)), true
+	}
+
+	responseData, err := api.callEndpoint(ctx, http.MethodPost, url, payload)
+	var homeBaseErr *HomeBaseError
+	if errors.As(err, &homeBaseErr) && isMissingEndpoint(homeBaseErr) {
+		return nil, false
+	} else if err != nil {
+		api.logger(ctx).Error("Unable to call endpoint.", "method", http.MethodPost, "url", url,
+			"err", err)
+		return repeatError(err, len(trackRecords)), true
+	}
+
+	resultsJSON, _ := json.Marshal(responseData)
+	var results []batchResult
+	if err := json.Unmarshal(resultsJSON, &results); err != nil {
+		api.logger(ctx).Warn("Unable to unmarshal batch results.", "err", err)
+		return nil, false
+	}
+	resultsByTimestamp := make(map[int64]batchResult, len(results))
+	for _, result := range results {
+		resultsByTimestamp[result.Timestamp] = result
+	}
+
+	errs := make([]error, len(trackRecords))
+	persisted := 0
+	for i, trackRecord := range trackRecords {
+		result, ok := resultsByTimestamp[trackRecord.Timestamp]
+		if !ok {
+			api.logger(ctx).Warn("Batch response does not contain a result for TrackRecord.",
+				"timestamp", trackRecord.Timestamp)
+			return nil, false
+		}
+		if !result.Success {
+			errs[i] = &HomeBaseError{Message: result.Message, Err: ErrBadRequest}
+			continue
+		}
+		persisted++
+	}
+
+	api.logger(ctx).Info("TrackRecords persisted with batch request.", "persisted", persisted,
+		"trackRecords", len(trackRecords))
+	return errs, true
+}
+
+// isMissingEndpoint reports whether the homebase does not provide the requested endpoint. A 404
+// only counts as missing endpoint if it lacks the API's envelope (see statusError), otherwise
+// it means that there is no data.
+func isMissingEndpoint(err *HomeBaseError) bool {
+	switch err.StatusCode {
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	case http.StatusNotFound:
+		return errors.Is(err.Err, ErrEndpointNotFound)
+	}
+	return false
+}
+
+func repeatError(err error, n int) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
+
+func (api HomeBaseConnector) callEndpoint(ctx context.Context, method, url string,
+	payload []byte) (interface{}, error) {
+	responseBody, err := api.sendHTTPRequest(ctx, method, url, payload)
+	if err != nil {
+		api.logger(ctx).Error("Request failed.", "method", method, "url", url, "err", err)
 		return nil, err
 	}
 
 	responseData, err := getDataFromResponseBody(responseBody)
 	if err != nil {
-		log.Printf("WARNING: Unable to read data from response. Message: `%s`.", err.Error())
+		api.logger(ctx).Warn("Unable to read data from response.", "err", err)
 		return nil, err
 	}
 
 	return responseData, nil
 }
 
-func (api HomeBaseConnector) sendHTTPRequest(method, url string, payload io.Reader) ([]byte,
-	error) {
-	client := http.Client{
-		Timeout: time.Duration(5 * time.Second),
+// url returns the URL of the endpoint described by `format`, which starts with a slash.
+func (api HomeBaseConnector) url(format string, args ...interface{}) string {
+	baseURL := api.BaseURL
+	if baseURL == "" {
+		baseURL = "https://" + api.APIHost
+	}
+	return strings.TrimSuffix(baseURL, "/") + fmt.Sprintf(format, args...)
+}
+
+func (api HomeBaseConnector) logger(ctx context.Context) *slog.Logger {
+	return logging.FromContextOr(ctx, api.Logger)
+}
+
+func (api HomeBaseConnector) authenticator() Authenticator {
+	if api.Authenticator == nil {
+		return SplitAuthenticator{APIKey{Key: api.APIKey}, BearerToken{api.APIAuthorization}}
+	}
+	return api.Authenticator
+}
+
+func (api HomeBaseConnector) httpClient() *http.Client {
+	if api.HTTPClient == nil {
+		return defaultHTTPClient
+	}
+	return api.HTTPClient
+}
+
+func (api HomeBaseConnector) retryPolicy() RetryPolicy {
+	if api.RetryPolicy == nil {
+		return DefaultRetryPolicy
+	}
+	return *api.RetryPolicy
+}
+
+// sendHTTPRequest sends the request and returns the body of the response. Requests that fail
+// due to network errors or retryable status codes are repeated according to the RetryPolicy.
+// Each request is traced as a single span, retries are recorded as events of the span.
+func (api HomeBaseConnector) sendHTTPRequest(ctx context.Context, method, url string,
+	payload []byte) (body []byte, err error) {
+	ctx, span := tracer.Start(ctx, "HomeBase "+method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(method), semconv.URLFull(url)))
+	defer func() { tracing.End(span, err) }()
+
+	policy := api.retryPolicy()
+	for attempt := 1; ; attempt++ {
+		var delay time.Duration
+		body, delay, err = api.sendHTTPRequestOnce(ctx, method, url, payload, policy)
+		if delay < 0 || attempt >= policy.MaxAttempts || ctx.Err() != nil {
+			return body, err
+		}
+
+		if delay == 0 {
+			delay = policy.backoff(attempt)
+		}
+		api.logger(ctx).Warn("Request failed, retrying.", "method", method, "url", url,
+			"attempt", attempt, "maxAttempts", policy.MaxAttempts, "delay", delay, "err", err)
+		span.AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt),
+			attribute.String("delay", delay.String()), attribute.String("error", fmt.Sprint(err))))
+		if !sleep(ctx, delay) {
+			return body, err
+		}
+	}
+}
+
+// sendHTTPRequestOnce sends the request a single time. The returned delay is negative if the
+// request must not be retried, positive if the server requested a delay via `Retry-After` and
+// 0 otherwise. Requests are not retried if `Retry-After` exceeds the MaxBackoff of `policy`.
+// Requests rejected with 401 are retried if the Authenticator dropped cached credentials, which
+// may have been revoked.
+func (api HomeBaseConnector) sendHTTPRequestOnce(ctx context.Context, method, url string,
+	payload []byte, policy RetryPolicy) ([]byte, time.Duration, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
 	}
-	req, err := http.NewRequest(method, url, payload)
+	req, err := http.NewRequest(method, url, body)
 	if err != nil {
-		log.Printf("ERROR:   Unable to create new %s request. Message: `%s`.", method, err)
-		return nil, err
+		api.logger(ctx).Error("Unable to create request.", "method", method, "err", err)
+		return nil, -1, err
 	}
+	req = req.WithContext(ctx)
+	// the trace context is sent along, so that the spans of the homebase join the crawler's trace
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
-	if method == http.MethodPut {
-		req.Header.Set("Authorization", "Bearer "+api.APIAuthorization)
-	} else {
-		req.Header.Set("X-API-KEY", api.APIKey)
+	if err := api.authenticator().Authenticate(req, payload); err != nil {
+		api.logger(ctx).Error("Unable to authenticate request.", "method", method, "url", url,
+			"err", err)
+		return nil, 0, err
 	}
 
-	resp, err := client.Do(req)
+	start := time.Now()
+	resp, err := api.httpClient().Do(req)
 	if err != nil {
-		log.Printf("ERROR:   Unable to call endpoint `%s %s`. Message: `%s`.",
-			url, method, err.Error())
-		return nil, err
+		recordHomeBaseRequest(method, 0, time.Since(start))
+		api.logger(ctx).Error("Unable to call endpoint.", "method", method, "url", url,
+			"err", err)
+		return nil, 0, err
 	}
 	defer resp.Body.Close()
-	return ioutil.ReadAll(resp.Body)
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	recordHomeBaseRequest(method, resp.StatusCode, time.Since(start))
+	trace.SpanFromContext(ctx).SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if err != nil {
+		return nil, -1, err
+	}
+	err = statusError(resp.StatusCode, responseBody)
+	if invalidator, ok := api.authenticator().(Invalidator); ok &&
+		resp.StatusCode == http.StatusUnauthorized && invalidator.Invalidate() {
+		// the cached credentials may have been revoked, the retry requests new ones
+		api.logger(ctx).Warn("Credentials were rejected, retrying with new ones.",
+			"method", method, "url", url)
+		return responseBody, 0, err
+	}
+	if !policy.isRetryableStatus(resp.StatusCode) {
+		return responseBody, -1, err
+	}
+	delay, _ := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+	if policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+		// waiting that long would stall the crawler, retrying earlier would ignore the server
+		api.logger(ctx).Warn("Not retrying request since Retry-After exceeds the maximum "+
+			"backoff.", "method", method, "url", url, "retryAfter", delay,
+			"maxBackoff", policy.MaxBackoff)
+		return responseBody, -1, err
+	}
+	return responseBody, delay, err
 }
 
+// getDataFromResponseBody returns the `data` of a successful response. A response with
+// `success: false` is reported as ErrNoData. Errors are logged by the caller.
 func getDataFromResponseBody(body []byte) (interface{}, error) {
 	var responseDataFields map[string]interface{}
 	err := json.Unmarshal(body, &responseDataFields)
 	if err != nil {
-		log.Printf("ERROR:   Unable to unmarshal JSON response into a key map. Message: `%s`.",
-			err.Error())
-		return nil, err
+		return nil, malformedResponseError("%s", err.Error())
 	}
 
-	if _, ok := responseDataFields["success"]; !ok {
-		log.Printf("ERROR:   Illegal JSON response format: `%s`.", body)
-		return nil, errors.New("illegal JSON response format")
+	success, ok := responseDataFields["success"].(bool)
+	if !ok {
+		return nil, malformedResponseError("illegal JSON response format")
 	}
 
-	success := responseDataFields["success"].(bool)
 	if !success {
-		log.Printf("WARNING: Request did not return any data. Message: `%s`.",
-			responseDataFields["message"])
-		return nil, errors.New("request did not return any data")
+		message, _ := responseDataFields["message"].(string)
+		return nil, &HomeBaseError{Message: message, Err: ErrNoData}
 	}
 
 	return responseDataFields["data"], nil
@@ -1,42 +1,57 @@
 package crawler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/RadioCheckerApp/api/model"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestGetDataFromResponseBody(t *testing.T) {
 	var tests = []struct {
 		json           []byte
 		expectedResult interface{}
-		expectedErr    bool
+		expectedErr    error
 	}{
 		{
 			[]byte("{\"success\": true,\"data\": \"track created: /stations/hitradio-oe3/tracks/1534966200\"}"),
 			"track created: /stations/hitradio-oe3/tracks/1534966200",
-			false,
+			nil,
 		},
 		{
 			[]byte("invalid json"),
 			nil,
-			true,
+			ErrMalformedResponse,
 		},
 		{
 			[]byte("{\"status\": true,\"data\": \"track created: /stations/hitradio-oe3/tracks/1534966200\"}"),
 			nil,
-			true,
+			ErrMalformedResponse,
 		},
 		{
 			[]byte("{\"success\": false,\"message\": \"API Error\"}"),
 			nil,
-			true,
+			ErrNoData,
+		},
+		{
+			[]byte("{\"success\": \"yes\"}"),
+			nil,
+			ErrMalformedResponse,
 		},
 	}
 
 	for _, test := range tests {
 		data, err := getDataFromResponseBody(test.json)
-		if (err != nil) != test.expectedErr {
-			t.Errorf("GetDataFromResponseBody(%v): got error: (%q), expected error: (%v)",
+		if !errors.Is(err, test.expectedErr) {
+			t.Errorf("GetDataFromResponseBody(%v): got error: (%v), expected error: (%v)",
 				test.json, err, test.expectedErr)
 		}
 
@@ -50,3 +65,226 @@ func TestGetDataFromResponseBody(t *testing.T) {
 		}
 	}
 }
+
+// newTestHomeBase returns a HomeBaseConnector that sends its requests to `handler`. The returned
+// function shuts the server down and forgets whether it supports batch requests, since a later
+// server may listen on the same port.
+func newTestHomeBase(handler http.HandlerFunc) (HomeBaseConnector, func()) {
+	server := httptest.NewTLSServer(handler)
+	api := HomeBaseConnector{
+		BaseURL:     server.URL,
+		HTTPClient:  server.Client(),
+		RetryPolicy: &RetryPolicy{MaxAttempts: 1},
+	}
+	return api, func() {
+		server.Close()
+		batchUnsupported.Delete(api.url(""))
+	}
+}
+
+func TestHomeBaseConnector_PersistTrackRecords(t *testing.T) {
+	var tests = []struct {
+		batchSupported   bool
+		disableBatch     bool
+		expectedRequests []string
+	}{
+		{true, false, []string{
+			"POST /stations/station-a/tracks",
+			"POST /stations/fail/tracks",
+			"POST /stations/station-a/tracks",
+			"POST /stations/station-a/tracks",
+			"POST /stations/fail/tracks",
+			"POST /stations/station-a/tracks",
+		}},
+		{false, false, []string{
+			"POST /stations/station-a/tracks",
+			"PUT /stations/station-a/tracks/1535301540",
+			"PUT /stations/fail/tracks/1535301300",
+			"PUT /stations/station-a/tracks/1535301120",
+			// the missing batch endpoint is remembered
+			"PUT /stations/station-a/tracks/1535301540",
+			"PUT /stations/fail/tracks/1535301300",
+			"PUT /stations/station-a/tracks/1535301120",
+		}},
+		{true, true, []string{
+			"PUT /stations/station-a/tracks/1535301540",
+			"PUT /stations/fail/tracks/1535301300",
+			"PUT /stations/station-a/tracks/1535301120",
+			"PUT /stations/station-a/tracks/1535301540",
+			"PUT /stations/fail/tracks/1535301300",
+			"PUT /stations/station-a/tracks/1535301120",
+		}},
+	}
+
+	for _, test := range tests {
+		var requests []string
+		api, shutdown := newTestHomeBase(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.Method+" "+r.URL.Path)
+			if r.Method == http.MethodPost && !test.batchSupported {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			if strings.Contains(r.URL.Path, "/fail/") {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"success": false, "message": "invalid station"}`))
+				return
+			}
+			if r.Method == http.MethodPost {
+				var trackRecords []*model.TrackRecord
+				json.NewDecoder(r.Body).Decode(&trackRecords)
+				results := make([]batchResult, len(trackRecords))
+				for i, trackRecord := range trackRecords {
+					results[i] = batchResult{Timestamp: trackRecord.Timestamp, Success: true}
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": results})
+				return
+			}
+			w.Write([]byte(`{"success": true, "data": "track created"}`))
+		})
+		api.DisableBatch = test.disableBatch
+
+		api.PersistTrackRecords(context.Background(), trackRecordBatch2)
+		errs := api.PersistTrackRecords(context.Background(), trackRecordBatch2)
+		shutdown()
+
+		if len(errs) != 3 || errs[0] != nil || !errors.Is(errs[1], ErrBadRequest) || errs[2] != nil {
+			t.Errorf("PersistTrackRecords (batch supported: %v, disabled: %v): got errors %v",
+				test.batchSupported, test.disableBatch, errs)
+		}
+		if !reflect.DeepEqual(requests, test.expectedRequests) {
+			t.Errorf("PersistTrackRecords (batch supported: %v, disabled: %v): got requests %v, "+
+				"expected %v", test.batchSupported, test.disableBatch, requests,
+				test.expectedRequests)
+		}
+	}
+}
+
+func TestHomeBaseConnector_PersistTrackRecords_Results(t *testing.T) {
+	api, shutdown := newTestHomeBase(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success": true, "data": [
+			{"timestamp": 1535301540, "success": true},
+			{"timestamp": 1535301300, "success": false, "message": "duplicate track"},
+			{"timestamp": 1535301120, "success": true}
+		]}`))
+	})
+	defer shutdown()
+
+	errs := api.PersistTrackRecords(context.Background(), trackRecordBatch0)
+
+	if len(errs) != 3 || errs[0] != nil || errs[2] != nil || errs[1] == nil ||
+		errs[1].Error() != "homebase rejected the request: duplicate track" {
+		t.Errorf("PersistTrackRecords: got errors %v", errs)
+	}
+}
+
+func TestIsMissingEndpoint(t *testing.T) {
+	var tests = []struct {
+		err      *HomeBaseError
+		expected bool
+	}{
+		{&HomeBaseError{StatusCode: http.StatusMethodNotAllowed, Err: ErrBadRequest}, true},
+		{&HomeBaseError{StatusCode: http.StatusNotImplemented, Err: ErrServer}, true},
+		{&HomeBaseError{StatusCode: http.StatusNotFound, Err: ErrEndpointNotFound}, true},
+		{&HomeBaseError{StatusCode: http.StatusNotFound, Err: ErrNoData}, false},
+		{&HomeBaseError{StatusCode: http.StatusNotFound, Message: "unknown station",
+			Err: ErrNoData}, false},
+		{&HomeBaseError{StatusCode: http.StatusBadRequest, Err: ErrBadRequest}, false},
+	}
+
+	for _, test := range tests {
+		if missing := isMissingEndpoint(test.err); missing != test.expected {
+			t.Errorf("isMissingEndpoint(%v): got %v, expected %v", test.err, missing,
+				test.expected)
+		}
+	}
+}
+
+func TestHomeBaseConnector_TrackRecords(t *testing.T) {
+	var tests = []struct {
+		statusCode  int
+		body        string
+		expectedLen int
+		expectedErr error
+	}{
+		{http.StatusOK, `{"success": true, "data": [{"stationId": "station-a", "timestamp": 2},
+			{"stationId": "station-a", "timestamp": 1}]}`, 2, nil},
+		{http.StatusNotFound, `{"success": false, "message": "no tracks"}`, 0, nil},
+		{http.StatusNotFound, `404 page not found`, 0, ErrHistoryUnavailable},
+		{http.StatusMethodNotAllowed, ``, 0, ErrHistoryUnavailable},
+		{http.StatusOK, `{"success": true, "data": {"stationId": "station-a", "timestamp": 2}}`,
+			0, ErrHistoryUnavailable},
+		{http.StatusInternalServerError, `{"success": false}`, 0, ErrServer},
+	}
+
+	for _, test := range tests {
+		api, shutdown := newTestHomeBase(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.statusCode)
+			w.Write([]byte(test.body))
+		})
+		trackRecords, err := api.TrackRecords(context.Background(), "station-a",
+			time.Unix(0, 0), time.Unix(10, 0))
+		shutdown()
+
+		if len(trackRecords) != test.expectedLen || !errors.Is(err, test.expectedErr) ||
+			(test.expectedErr == nil && err != nil) {
+			t.Errorf("TrackRecords (status %d, %s): got (%v, %v), expected %d TrackRecords "+
+				"and error (%v)", test.statusCode, test.body, trackRecords, err,
+				test.expectedLen, test.expectedErr)
+		}
+	}
+}
+
+func TestHomeBaseConnector_InvalidatesRejectedToken(t *testing.T) {
+	var tokenRequests int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		n := atomic.AddInt32(&tokenRequests, 1)
+		fmt.Fprintf(w, `{"access_token": "token-%d", "expires_in": 3600}`, n)
+	}))
+	defer tokenServer.Close()
+
+	// the first token is revoked before it expires
+	api, shutdown := newTestHomeBase(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"success": true, "data": "ok"}`))
+	})
+	defer shutdown()
+	api.Authenticator = NewOAuth2ClientCredentials(tokenServer.URL, "crawler", "secret")
+	api.RetryPolicy = &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+
+	if _, err := api.sendHTTPRequest(context.Background(), http.MethodGet, api.url("/"),
+		nil); err != nil {
+		t.Errorf("sendHTTPRequest: got error (%v), expected retry with a new token", err)
+	}
+	if tokenRequests != 2 {
+		t.Errorf("sendHTTPRequest: got %d token requests, expected 2", tokenRequests)
+	}
+}
+
+func TestHomeBaseConnector_DoesNotRetryRejectedStaticToken(t *testing.T) {
+	var requests int32
+	api, shutdown := newTestHomeBase(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	defer shutdown()
+	// static tokens implement Invalidator through the ResolvingAuthenticator, but there are no
+	// cached credentials to drop
+	authenticator, err := NewResolvingAuthenticator(AuthConfig{Type: AuthBearer, Token: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.Authenticator = authenticator
+	api.RetryPolicy = &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	if _, err := api.sendHTTPRequest(context.Background(), http.MethodGet, api.url("/"),
+		nil); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("sendHTTPRequest: got error (%v), expected (%v)", err, ErrUnauthorized)
+	}
+	if requests != 1 {
+		t.Errorf("sendHTTPRequest: got %d requests, expected 1", requests)
+	}
+}
@@ -0,0 +1,78 @@
+package crawler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultTimeout limits the duration of a single request to the homebase.
+const defaultTimeout = 5 * time.Second
+
+// defaultHTTPClient is used by HomeBaseConnectors without HTTPClient. It is shared, so that
+// connections are reused across requests.
+var defaultHTTPClient = &http.Client{Timeout: defaultTimeout}
+
+// HTTPClientOptions configures the HTTP client created by NewHTTPClient.
+type HTTPClientOptions struct {
+	// Timeout limits the duration of a single request (default: 5s).
+	Timeout time.Duration
+	// CAFile is a PEM bundle of the certificate authorities that are trusted in addition to
+	// the system's.
+	CAFile string
+	// CertFile and KeyFile are the PEM encoded client certificate and key used for mutual TLS.
+	CertFile string
+	KeyFile  string
+	// ProxyURL is the proxy used for all requests. The proxy is read from the environment
+	// variables HTTPS_PROXY, HTTP_PROXY and NO_PROXY if it is empty.
+	ProxyURL string
+}
+
+// NewHTTPClient creates an HTTP client for a HomeBaseConnector. The client should be reused for
+// all requests.
+func NewHTTPClient(options HTTPClientOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{}
+
+	if options.CAFile != "" {
+		pem, err := ioutil.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in `" + options.CAFile + "`")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if options.CertFile != "" || options.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if options.ProxyURL != "" {
+		proxyURL, err := url.Parse(options.ProxyURL)
+		if err != nil {
+			return nil, errors.New("invalid proxy URL: " + err.Error())
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
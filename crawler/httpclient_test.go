@@ -0,0 +1,126 @@
+package crawler
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCertificate writes a self-signed client certificate and its key to `dir`.
+func writeClientCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "crawler"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY",
+		Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		w.Write([]byte(`{"success": true, "data": "ok"}`))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE",
+		Bytes: server.Certificate().Raw}), 0600)
+	certFile, keyFile := writeClientCertificate(t, dir)
+
+	var tests = []struct {
+		options            HTTPClientOptions
+		expectedClientErr  bool
+		expectedRequestErr bool
+	}{
+		{HTTPClientOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, false, false},
+		// client certificate missing
+		{HTTPClientOptions{CAFile: caFile}, false, true},
+		// server certificate not trusted
+		{HTTPClientOptions{CertFile: certFile, KeyFile: keyFile}, false, true},
+		{HTTPClientOptions{CAFile: certFile + ".missing"}, true, false},
+		{HTTPClientOptions{CAFile: keyFile}, true, false},
+		{HTTPClientOptions{CertFile: certFile}, true, false},
+		{HTTPClientOptions{ProxyURL: "://proxy"}, true, false},
+	}
+
+	for _, test := range tests {
+		client, err := NewHTTPClient(test.options)
+		if (err != nil) != test.expectedClientErr {
+			t.Errorf("NewHTTPClient(%+v): got error: (%v), expected error: (%v)",
+				test.options, err, test.expectedClientErr)
+		}
+		if err != nil {
+			continue
+		}
+
+		api := HomeBaseConnector{BaseURL: server.URL, HTTPClient: client,
+			RetryPolicy: &RetryPolicy{MaxAttempts: 1}}
+		_, err = api.callEndpoint(context.Background(), http.MethodGet, api.url("/"), nil)
+		if (err != nil) != test.expectedRequestErr {
+			t.Errorf("NewHTTPClient(%+v): got request error: (%v), expected error: (%v)",
+				test.options, err, test.expectedRequestErr)
+		}
+	}
+}
+
+func TestHomeBaseConnector_url(t *testing.T) {
+	var tests = []struct {
+		api         HomeBaseConnector
+		expectedURL string
+	}{
+		{HomeBaseConnector{APIHost: "api.radiochecker.com"},
+			"https://api.radiochecker.com/stations/kronehit/tracks/1"},
+		{HomeBaseConnector{APIHost: "ignored", BaseURL: "http://localhost:8080"},
+			"http://localhost:8080/stations/kronehit/tracks/1"},
+		{HomeBaseConnector{BaseURL: "https://staging.radiochecker.com/api/v1/"},
+			"https://staging.radiochecker.com/api/v1/stations/kronehit/tracks/1"},
+	}
+
+	for _, test := range tests {
+		if url := test.api.url("/stations/%s/tracks/%d", "kronehit", 1); url != test.expectedURL {
+			t.Errorf("url (%+v): got `%s`, expected `%s`", test.api, url, test.expectedURL)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/RadioCheckerApp/api/model"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// JSONLSink is a HomeBase that appends TrackRecords as JSON lines to a local file. It is meant for
+// research and testing: every lookup reads the whole file.
+type JSONLSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewJSONLSink(path string) *JSONLSink {
+	return &JSONLSink{path: path}
+}
+
+func (sink *JSONLSink) LatestTrackRecord(ctx context.Context,
+	stationId string) (*model.TrackRecord, error) {
+	var latest *model.TrackRecord
+	err := sink.scan(func(trackRecord *model.TrackRecord) {
+		if trackRecord.StationId == stationId &&
+			(latest == nil || trackRecord.Timestamp >= latest.Timestamp) {
+			latest = trackRecord
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if latest == nil {
+		return nil, ErrNoData
+	}
+	return latest, nil
+}
+
+// TrackRecords returns the TrackRecords of the station aired within [from, to]. If a
+// TrackRecord has been persisted several times, the last version is returned.
+func (sink *JSONLSink) TrackRecords(ctx context.Context, stationId string,
+	from, to time.Time) ([]*model.TrackRecord, error) {
+	byTimestamp := make(map[int64]*model.TrackRecord)
+	err := sink.scan(func(trackRecord *model.TrackRecord) {
+		if trackRecord.StationId == stationId && trackRecord.Timestamp >= from.Unix() &&
+			trackRecord.Timestamp <= to.Unix() {
+			byTimestamp[trackRecord.Timestamp] = trackRecord
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var trackRecords []*model.TrackRecord
+	for _, trackRecord := range byTimestamp {
+		trackRecords = append(trackRecords, trackRecord)
+	}
+	sort.Slice(trackRecords, func(i, j int) bool {
+		return trackRecords[i].Timestamp < trackRecords[j].Timestamp
+	})
+	return trackRecords, nil
+}
+
+func (sink *JSONLSink) PersistTrackRecord(ctx context.Context,
+	trackRecord *model.TrackRecord) error {
+	line, err := json.Marshal(trackRecord)
+	if err != nil {
+		return err
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	file, err := os.OpenFile(sink.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// scan calls `fn` for every TrackRecord in the file. A missing file contains no TrackRecords.
+func (sink *JSONLSink) scan(fn func(trackRecord *model.TrackRecord)) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	file, err := os.Open(sink.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var trackRecord model.TrackRecord
+		if err := json.Unmarshal(scanner.Bytes(), &trackRecord); err != nil {
+			slog.Warn("Skipped malformed line.", "line", lineNumber, "path", sink.path)
+			continue
+		}
+		fn(&trackRecord)
+	}
+	return scanner.Err()
+}
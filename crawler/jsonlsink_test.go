@@ -0,0 +1,56 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"github.com/RadioCheckerApp/api/model"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJSONLSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	sink := NewJSONLSink(filepath.Join(dir, "tracks.jsonl"))
+	testSink(t, sink, sink)
+}
+
+// testSink persists trackRecordBatch2 and checks the lookups of the sink.
+func testSink(t *testing.T, sink HomeBase, history TrackHistory) {
+	ctx := context.Background()
+	if _, err := sink.LatestTrackRecord(ctx, "station-a"); !errors.Is(err, ErrNoData) {
+		t.Errorf("LatestTrackRecord: got error (%v) for empty sink, expected (%v)",
+			err, ErrNoData)
+	}
+
+	for _, trackRecord := range trackRecordBatch2 {
+		if err := sink.PersistTrackRecord(ctx, trackRecord); err != nil {
+			t.Fatalf("PersistTrackRecord(%v): got error (%v)", trackRecord, err)
+		}
+	}
+	updated := &model.TrackRecord{"station-a", 1535301120, "track",
+		model.Track{"Simon Lewis", "Hey Jessie"}}
+	if err := sink.PersistTrackRecord(ctx, updated); err != nil {
+		t.Fatalf("PersistTrackRecord(%v): got error (%v)", updated, err)
+	}
+
+	latest, err := sink.LatestTrackRecord(ctx, "station-a")
+	if err != nil || !reflect.DeepEqual(latest, trackRecordBatch2[0]) {
+		t.Errorf("LatestTrackRecord: got (%v, %v), expected (%v, nil)",
+			latest, err, trackRecordBatch2[0])
+	}
+
+	trackRecords, err := history.TrackRecords(ctx, "station-a", time.Unix(1535301000, 0),
+		time.Unix(1535301540, 0))
+	expected := []*model.TrackRecord{updated, trackRecordBatch2[0]}
+	if err != nil || !reflect.DeepEqual(trackRecords, expected) {
+		t.Errorf("TrackRecords: got (%v, %v), expected (%v, nil)", trackRecords, err, expected)
+	}
+}
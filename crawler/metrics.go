@@ -0,0 +1,113 @@
+package crawler
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The metrics are registered with the default Prometheus registry, see MetricsHandler and
+// PushMetrics.
+var (
+	crawlerRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "radiochecker",
+		Subsystem: "crawler",
+		Name:      "runs_total",
+		Help:      "Crawler runs by station and result (success, failure).",
+	}, []string{"station", "result"})
+	crawlerRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "radiochecker",
+		Subsystem: "crawler",
+		Name:      "records_total",
+		Help: "TrackRecords by station and outcome (fetched, persisted, skipped, failed, " +
+			"outboxed).",
+	}, []string{"station", "outcome"})
+	crawlerFetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "radiochecker",
+		Subsystem: "crawler",
+		Name:      "fetch_duration_seconds",
+		Help:      "Duration of the requests of the station's fetcher.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"station"})
+	crawlerLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "radiochecker",
+		Subsystem: "crawler",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the end of the last crawler run without error.",
+	}, []string{"station"})
+
+	homeBaseRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "radiochecker",
+		Subsystem: "homebase",
+		Name:      "requests_total",
+		Help:      "HTTP requests to the homebase by method and status code (`error` if none).",
+	}, []string{"method", "code"})
+	homeBaseRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "radiochecker",
+		Subsystem: "homebase",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of the HTTP requests to the homebase.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	watchdogStale = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "radiochecker",
+		Subsystem: "watchdog",
+		Name:      "stale",
+		Help:      "1 if the latest TrackRecord of the station is older than its max age.",
+	}, []string{"station"})
+)
+
+// recordCrawlMetrics updates the metrics of the report's station after a crawler run.
+func recordCrawlMetrics(report CrawlReport) {
+	station := report.StationId
+	for outcome, n := range map[string]int{
+		"fetched":   report.RecordsFetched,
+		"persisted": report.RecordsPersisted,
+		"skipped":   report.RecordsSkipped,
+		"failed":    report.RecordsFailed,
+		"outboxed":  report.RecordsOutboxed,
+	} {
+		crawlerRecords.WithLabelValues(station, outcome).Add(float64(n))
+	}
+
+	if report.Failed() {
+		crawlerRuns.WithLabelValues(station, "failure").Inc()
+		return
+	}
+	crawlerRuns.WithLabelValues(station, "success").Inc()
+	crawlerLastSuccess.WithLabelValues(station).Set(float64(report.End.Unix()))
+}
+
+// recordHomeBaseRequest updates the metrics of the homebase after a request. `statusCode` is 0
+// if no response was received.
+func recordHomeBaseRequest(method string, statusCode int, duration time.Duration) {
+	code := "error"
+	if statusCode > 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	homeBaseRequests.WithLabelValues(method, code).Inc()
+	homeBaseRequestDuration.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+// MetricsHandler serves the metrics of the crawlers, fetchers and the homebase in the
+// Prometheus text format, e.g. on `/metrics`.
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// PushMetrics pushes the metrics to the Prometheus Pushgateway at `url`, replacing the metrics
+// previously pushed for the same job and instance (if not empty). It is meant for short-lived
+// runs that cannot be scraped, e.g. Lambda invocations.
+func PushMetrics(ctx context.Context, url, job, instance string) error {
+	pusher := push.New(url, job).Gatherer(prometheus.DefaultGatherer)
+	if instance != "" {
+		pusher = pusher.Grouping("instance", instance)
+	}
+	return pusher.PushContext(ctx)
+}
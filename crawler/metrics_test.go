@@ -0,0 +1,89 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"github.com/RadioCheckerApp/api/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordCrawlMetrics(t *testing.T) {
+	recordCrawlMetrics(CrawlReport{StationId: "metrics-a", End: time.Unix(1535301120, 0),
+		RecordsFetched: 3, RecordsPersisted: 2, RecordsSkipped: 1})
+	recordCrawlMetrics(CrawlReport{StationId: "metrics-a", End: time.Unix(1535304720, 0),
+		RecordsFetched: 1, RecordsFailed: 1, Err: errors.New("just a test")})
+
+	var tests = []struct {
+		value    float64
+		expected float64
+		name     string
+	}{
+		{testutil.ToFloat64(crawlerRuns.WithLabelValues("metrics-a", "success")), 1, "success"},
+		{testutil.ToFloat64(crawlerRuns.WithLabelValues("metrics-a", "failure")), 1, "failure"},
+		{testutil.ToFloat64(crawlerRecords.WithLabelValues("metrics-a", "fetched")), 4, "fetched"},
+		{testutil.ToFloat64(crawlerRecords.WithLabelValues("metrics-a", "persisted")), 2,
+			"persisted"},
+		{testutil.ToFloat64(crawlerRecords.WithLabelValues("metrics-a", "failed")), 1, "failed"},
+		{testutil.ToFloat64(crawlerLastSuccess.WithLabelValues("metrics-a")), 1535301120,
+			"last success"},
+	}
+
+	for _, test := range tests {
+		if test.value != test.expected {
+			t.Errorf("recordCrawlMetrics: got %s %v, expected %v", test.name, test.value,
+				test.expected)
+		}
+	}
+}
+
+func TestHomeBaseConnector_metrics(t *testing.T) {
+	api, closeServer := newTestHomeBase(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"success": true, "data": "track created"}`))
+	})
+	defer closeServer()
+
+	counter := homeBaseRequests.WithLabelValues(http.MethodPut, "201")
+	before := testutil.ToFloat64(counter)
+	trackRecord := &model.TrackRecord{"kronehit", 1535301120, "track", model.Track{"a", "b"}}
+	if err := api.PersistTrackRecord(context.Background(), trackRecord); err != nil {
+		t.Fatalf("PersistTrackRecord: got error (%v)", err)
+	}
+	if requests := testutil.ToFloat64(counter) - before; requests != 1 {
+		t.Errorf("PersistTrackRecord: got %v counted requests, expected 1", requests)
+	}
+
+	recorder := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if body := recorder.Body.String(); !strings.Contains(body,
+		`radiochecker_homebase_requests_total{code="201",method="PUT"}`) {
+		t.Errorf("MetricsHandler: response does not contain the homebase requests:\n%s", body)
+	}
+}
+
+func TestPushMetrics(t *testing.T) {
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	recordCrawlMetrics(CrawlReport{StationId: "metrics-b", End: time.Now()})
+	if err := PushMetrics(context.Background(), server.URL, "crawlers-aws",
+		"metrics-b"); err != nil {
+		t.Fatalf("PushMetrics: got error (%v)", err)
+	}
+	if method != http.MethodPut || path != "/metrics/job/crawlers-aws/instance/metrics-b" ||
+		len(body) == 0 {
+		t.Errorf("PushMetrics: got request `%s %s` with %d bytes, expected `PUT "+
+			"/metrics/job/crawlers-aws/instance/metrics-b`", method, path, len(body))
+	}
+}
@@ -0,0 +1,255 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Notifier delivers the alerts of the Watchdog, e.g. to a chat or an on-call system.
+// Implementations must be safe for concurrent use.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// WebhookNotifier posts every alert as JSON object to URL.
+type WebhookNotifier struct {
+	URL string
+	// HTTPClient is used to send the requests. A shared client is used if it is nil.
+	HTTPClient *http.Client
+}
+
+func (notifier WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, notifier.HTTPClient, notifier.URL, alert)
+}
+
+// SlackNotifier posts the message of every alert to a Slack-compatible incoming webhook, which
+// is also supported by e.g. Mattermost, Rocket.Chat and Microsoft Teams.
+type SlackNotifier struct {
+	URL string
+	// HTTPClient is used to send the requests. A shared client is used if it is nil.
+	HTTPClient *http.Client
+}
+
+func (notifier SlackNotifier) Notify(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, notifier.HTTPClient, notifier.URL,
+		map[string]string{"text": alert.Message})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook responded with status %d: %s", resp.StatusCode,
+			strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// smtpTimeout limits the delivery of an email, including the connection to the SMTP server, if
+// the context of Notify has no earlier deadline.
+const smtpTimeout = 30 * time.Second
+
+// SMTPNotifier sends every alert as plain text email. Addr is the `host:port` of the SMTP server,
+// which must support STARTTLS if Username is set.
+type SMTPNotifier struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+	// sendMail is used in tests.
+	sendMail func(ctx context.Context, addr string, auth smtp.Auth, from string, to []string,
+		msg []byte) error
+}
+
+func (notifier SMTPNotifier) Notify(ctx context.Context, alert Alert) error {
+	var auth smtp.Auth
+	if notifier.Username != "" {
+		host := notifier.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", notifier.Username, notifier.Password, host)
+	}
+	sendMail := notifier.sendMail
+	if sendMail == nil {
+		sendMail = sendSMTPMail
+	}
+	return sendMail(ctx, notifier.Addr, auth, notifier.From, notifier.To, notifier.message(alert))
+}
+
+// sendSMTPMail works like smtp.SendMail, but aborts once `ctx` is done or smtpTimeout passed, so
+// that an unresponsive mail server does not block the Watchdog.
+func sendSMTPMail(ctx context.Context, addr string, auth smtp.Auth, from string, to []string,
+	msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	// closing the connection interrupts a pending read or write once `ctx` is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	host, _, _ := net.SplitHostPort(addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("SMTP server does not support authentication")
+		}
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (notifier SMTPNotifier) message(alert Alert) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", notifier.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(notifier.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [RadioChecker] Station %s is %s\r\n", alert.StationId,
+		alert.Status)
+	fmt.Fprintf(&msg, "Date: %s\r\n", alert.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n", alert.Message)
+	return msg.Bytes()
+}
+
+// Notifier types supported by NotifierConfig.
+const (
+	NotifierWebhook = "webhook"
+	NotifierSlack   = "slack"
+	NotifierEmail   = "email"
+)
+
+// NotifierConfig describes a Notifier of the Watchdog. URL and Password may reference
+// environment variables using the `${NAME}` syntax and secrets (see ResolveSecret).
+type NotifierConfig struct {
+	Type string `json:"type" yaml:"type"`
+	// URL is the endpoint of `webhook` and `slack` notifiers.
+	URL string `json:"url,omitempty" yaml:"url,omitempty"`
+
+	// SMTP is the `host:port` of the mail server of `email` notifiers.
+	SMTP     string   `json:"smtp,omitempty" yaml:"smtp,omitempty"`
+	Username string   `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string   `json:"password,omitempty" yaml:"password,omitempty"`
+	From     string   `json:"from,omitempty" yaml:"from,omitempty"`
+	To       []string `json:"to,omitempty" yaml:"to,omitempty"`
+}
+
+// Validate checks that the notifier type is known and that its required fields are set.
+func (config NotifierConfig) Validate() error {
+	switch config.Type {
+	case NotifierWebhook, NotifierSlack:
+		if config.URL == "" {
+			return fmt.Errorf("notifier `%s`: url must not be empty", config.Type)
+		}
+	case NotifierEmail:
+		if config.SMTP == "" || config.From == "" || len(config.To) == 0 {
+			return fmt.Errorf("notifier `%s`: smtp, from and to must not be empty",
+				config.Type)
+		}
+	default:
+		return fmt.Errorf("unknown notifier type `%s` (available: %s)", config.Type,
+			strings.Join([]string{NotifierEmail, NotifierSlack, NotifierWebhook}, ", "))
+	}
+	return nil
+}
+
+// Open creates the configured Notifier.
+func (config NotifierConfig) Open(ctx context.Context) (Notifier, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	url, err := ResolveSecret(ctx, os.ExpandEnv(config.URL))
+	if err != nil {
+		return nil, fmt.Errorf("notifier `%s`: %w", config.Type, err)
+	}
+	password, err := ResolveSecret(ctx, os.ExpandEnv(config.Password))
+	if err != nil {
+		return nil, fmt.Errorf("notifier `%s`: %w", config.Type, err)
+	}
+
+	switch config.Type {
+	case NotifierWebhook:
+		return WebhookNotifier{URL: url}, nil
+	case NotifierSlack:
+		return SlackNotifier{URL: url}, nil
+	}
+	return SMTPNotifier{Addr: config.SMTP, Username: config.Username, Password: password,
+		From: config.From, To: config.To}, nil
+}
+
+// notifyAll sends the alert using every notifier. It reports whether at least one notifier
+// succeeded, or there are none, and returns the errors of the others.
+func notifyAll(ctx context.Context, notifiers []Notifier, alert Alert) (bool, error) {
+	var errs []error
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(ctx, alert); err != nil {
+			errs = append(errs, fmt.Errorf("%T: %w", notifier, err))
+		}
+	}
+	return len(errs) < len(notifiers) || len(notifiers) == 0, errors.Join(errs...)
+}
@@ -0,0 +1,176 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testAlert = Alert{
+	StationId:       "station-a",
+	Status:          AlertStale,
+	LatestTimestamp: 1535301540,
+	AgeSeconds:      7200,
+	MaxAgeSeconds:   3600,
+	Time:            time.Date(2018, 8, 26, 20, 39, 0, 0, time.UTC),
+	Message:         "Station `station-a` is stale.",
+}
+
+func TestWebhookNotifiers(t *testing.T) {
+	var tests = []struct {
+		notifier func(url string) Notifier
+		expected string
+	}{
+		{func(url string) Notifier { return WebhookNotifier{URL: url} },
+			`{"stationId":"station-a","status":"stale","latestTimestamp":1535301540,` +
+				`"ageSeconds":7200,"maxAgeSeconds":3600,"staleSince":"0001-01-01T00:00:00Z",` +
+				`"time":"2018-08-26T20:39:00Z","message":"Station ` + "`station-a`" +
+				` is stale."}`},
+		{func(url string) Notifier { return SlackNotifier{URL: url} },
+			`{"text":"Station ` + "`station-a`" + ` is stale."}`},
+	}
+
+	for _, test := range tests {
+		var body string
+		status := http.StatusOK
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+			r *http.Request) {
+			var payload json.RawMessage
+			json.NewDecoder(r.Body).Decode(&payload)
+			body = string(payload)
+			if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+				body = "unexpected request " + r.Method
+			}
+			w.WriteHeader(status)
+		}))
+		notifier := test.notifier(server.URL)
+
+		if err := notifier.Notify(context.Background(), testAlert); err != nil {
+			t.Errorf("%T Notify: unexpected error: %s", notifier, err)
+		}
+		if body != test.expected {
+			t.Errorf("%T Notify: got body\n`%s`, expected\n`%s`", notifier, body, test.expected)
+		}
+
+		status = http.StatusForbidden
+		if err := notifier.Notify(context.Background(), testAlert); err == nil ||
+			!strings.Contains(err.Error(), "403") {
+			t.Errorf("%T Notify: got error (%v), expected status 403", notifier, err)
+		}
+		server.Close()
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	var sent struct {
+		addr string
+		auth smtp.Auth
+		from string
+		to   []string
+		msg  string
+	}
+	notifier := SMTPNotifier{
+		Addr:     "mail.example.com:587",
+		Username: "crawlers",
+		Password: "secret",
+		From:     "crawlers@example.com",
+		To:       []string{"ops@example.com", "dev@example.com"},
+		sendMail: func(ctx context.Context, addr string, auth smtp.Auth, from string,
+			to []string, msg []byte) error {
+			sent.addr, sent.auth, sent.from, sent.to, sent.msg = addr, auth, from, to, string(msg)
+			return nil
+		},
+	}
+
+	if err := notifier.Notify(context.Background(), testAlert); err != nil {
+		t.Fatalf("Notify: unexpected error: %s", err)
+	}
+	expectedMsg := "From: crawlers@example.com\r\n" +
+		"To: ops@example.com, dev@example.com\r\n" +
+		"Subject: [RadioChecker] Station station-a is stale\r\n" +
+		"Date: Sun, 26 Aug 2018 20:39:00 +0000\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n\r\n" +
+		"Station `station-a` is stale.\r\n"
+	if sent.addr != notifier.Addr || sent.auth == nil || sent.from != notifier.From ||
+		!reflect.DeepEqual(sent.to, notifier.To) || sent.msg != expectedMsg {
+		t.Errorf("Notify: got mail %+v", sent)
+	}
+
+	notifier.Username = ""
+	notifier.Notify(context.Background(), testAlert)
+	if sent.auth != nil {
+		t.Errorf("Notify: expected no authentication without username")
+	}
+}
+
+func TestSMTPNotifier_Timeout(t *testing.T) {
+	// the server accepts connections, but never greets the client
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			<-done
+			conn.Close()
+		}
+	}()
+
+	notifier := SMTPNotifier{Addr: listener.Addr().String(), From: "crawlers@example.com",
+		To: []string{"ops@example.com"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := notifier.Notify(ctx, testAlert); err == nil {
+		t.Errorf("Notify: got no error for an unresponsive server")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Notify: returned after %s, expected to stop with the context", elapsed)
+	}
+}
+
+func TestNotifierConfig_Open(t *testing.T) {
+	os.Setenv("TEST_WEBHOOK_URL", "https://hooks.example.com/abc")
+	defer os.Unsetenv("TEST_WEBHOOK_URL")
+
+	var tests = []struct {
+		config      NotifierConfig
+		expected    Notifier
+		expectedErr bool
+	}{
+		{NotifierConfig{Type: NotifierWebhook, URL: "${TEST_WEBHOOK_URL}"},
+			WebhookNotifier{URL: "https://hooks.example.com/abc"}, false},
+		{NotifierConfig{Type: NotifierSlack, URL: "env://TEST_WEBHOOK_URL"},
+			SlackNotifier{URL: "https://hooks.example.com/abc"}, false},
+		{NotifierConfig{Type: NotifierEmail, SMTP: "localhost:25", From: "a@example.com",
+			To: []string{"b@example.com"}, Username: "a", Password: "env://TEST_WEBHOOK_URL"},
+			SMTPNotifier{Addr: "localhost:25", From: "a@example.com",
+				To: []string{"b@example.com"}, Username: "a",
+				Password: "https://hooks.example.com/abc"}, false},
+		{NotifierConfig{Type: NotifierSlack, URL: "env://TEST_MISSING_SECRET"}, nil, true},
+		{NotifierConfig{Type: NotifierWebhook}, nil, true},
+		{NotifierConfig{Type: "pager", URL: "https://example.com"}, nil, true},
+	}
+
+	for _, test := range tests {
+		notifier, err := test.config.Open(context.Background())
+		if (err != nil) != test.expectedErr {
+			t.Errorf("Open(%+v): got error (%v), expected error: %v", test.config, err,
+				test.expectedErr)
+		}
+		if !reflect.DeepEqual(notifier, test.expected) {
+			t.Errorf("Open(%+v): got %#v, expected %#v", test.config, notifier, test.expected)
+		}
+	}
+}
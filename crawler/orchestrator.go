@@ -0,0 +1,136 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"github.com/RadioCheckerApp/crawlers/logging"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// defaultWorkers is the number of stations crawled at the same time if Orchestrator.Workers is
+// not set.
+const defaultWorkers = 4
+
+// Orchestrator crawls several stations concurrently. A failing or panicking crawler only
+// affects the report of its own station.
+type Orchestrator struct {
+	// Workers limits the number of stations that are crawled at the same time.
+	Workers int
+	// Outbox is passed to the crawlers created by RunStations, see Crawler.WithOutbox.
+	Outbox Outbox
+	// Notifiers receive an alert for every station whose run exceeded its thresholds, see
+	// NotifyThresholdExceeded.
+	Notifiers []Notifier
+}
+
+// OrchestratorReport aggregates the reports of all stations crawled in one run.
+type OrchestratorReport struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// Reports contains one report per station in the order the stations were passed in.
+	Reports []CrawlReport `json:"reports"`
+
+	StationsFailed   int `json:"stationsFailed"`
+	RecordsFetched   int `json:"recordsFetched"`
+	RecordsPersisted int `json:"recordsPersisted"`
+	RecordsFailed    int `json:"recordsFailed"`
+}
+
+// RunStations creates a crawler for every station and crawls them concurrently, using the same
+// homebase for all of them. A station whose crawler cannot be created is reported as failed.
+func (orchestrator Orchestrator) RunStations(ctx context.Context, stations []StationConfig,
+	homeBase HomeBase) OrchestratorReport {
+	return orchestrator.run(ctx, len(stations), func(i int) (string, func() CrawlReport) {
+		station := stations[i]
+		return station.ID, func() CrawlReport {
+			crawler, err := station.NewCrawler(ctx, homeBase)
+			if err != nil {
+				logging.FromContext(ctx).Error("Unable to create crawler.", "station", station.ID,
+					"err", err)
+				now := time.Now()
+				return CrawlReport{StationId: station.ID, Start: now, End: now, Err: err}
+			}
+			if orchestrator.Outbox != nil {
+				crawler = crawler.WithOutbox(orchestrator.Outbox)
+			}
+			return crawler.CrawlContext(ctx)
+		}
+	})
+}
+
+// RunCrawlers crawls all crawlers concurrently.
+func (orchestrator Orchestrator) RunCrawlers(ctx context.Context,
+	crawlers []Crawler) OrchestratorReport {
+	return orchestrator.run(ctx, len(crawlers), func(i int) (string, func() CrawlReport) {
+		return crawlers[i].stationId, func() CrawlReport {
+			return crawlers[i].CrawlContext(ctx)
+		}
+	})
+}
+
+func (orchestrator Orchestrator) run(ctx context.Context, n int,
+	job func(i int) (string, func() CrawlReport)) OrchestratorReport {
+	report := OrchestratorReport{Start: time.Now(), Reports: make([]CrawlReport, n)}
+
+	workers := orchestrator.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				stationId, crawl := job(i)
+				report.Reports[i] = RunIsolated(stationId, crawl)
+				err := NotifyThresholdExceeded(ctx, orchestrator.Notifiers, report.Reports[i])
+				if err != nil {
+					logging.FromContext(ctx).Error("Unable to send alert.", "station", stationId,
+						"err", err)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	for _, stationReport := range report.Reports {
+		if stationReport.Failed() {
+			report.StationsFailed++
+		}
+		report.RecordsFetched += stationReport.RecordsFetched
+		report.RecordsPersisted += stationReport.RecordsPersisted
+		report.RecordsFailed += stationReport.RecordsFailed
+	}
+	report.End = time.Now()
+	return report
+}
+
+// RunIsolated executes `crawl` and turns a panic into a failed report of the station.
+func RunIsolated(stationId string, crawl func() CrawlReport) (report CrawlReport) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Crawler panicked.", "station", stationId, "panic", r,
+				"stack", string(debug.Stack()))
+			report = CrawlReport{
+				StationId: stationId,
+				Start:     start,
+				End:       time.Now(),
+				Err:       fmt.Errorf("crawler panicked: %v", r),
+			}
+		}
+	}()
+	return crawl()
+}
@@ -0,0 +1,116 @@
+package crawler
+
+import (
+	"context"
+	"github.com/RadioCheckerApp/api/model"
+	"github.com/RadioCheckerApp/crawlers/fetcher"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type MockFetcherPanic struct{}
+
+func (fetcher MockFetcherPanic) Next() ([]*model.TrackRecord, error) {
+	panic("just a test")
+}
+
+type MockFetcherConcurrency struct {
+	current, max *int32
+}
+
+func (fetcher MockFetcherConcurrency) Next() ([]*model.TrackRecord, error) {
+	current := atomic.AddInt32(fetcher.current, 1)
+	defer atomic.AddInt32(fetcher.current, -1)
+	for {
+		max := atomic.LoadInt32(fetcher.max)
+		if current <= max || atomic.CompareAndSwapInt32(fetcher.max, max, current) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return trackRecordBatch1, nil
+}
+
+func newTestCrawler(stationId string, f fetcher.Fetcher) Crawler {
+	return Crawler{
+		stationId:                  stationId,
+		fetcher:                    f,
+		homeBase:                   MockHomeBaseSuccess{},
+		latestTrackRecordTimestamp: 1234567890,
+	}
+}
+
+func TestOrchestrator_RunCrawlers(t *testing.T) {
+	var current, max int32
+	concurrencyFetcher := MockFetcherConcurrency{&current, &max}
+	crawlers := []Crawler{
+		newTestCrawler("station-a", concurrencyFetcher),
+		newTestCrawler("station-b", MockFetcherPanic{}),
+		newTestCrawler("station-c", concurrencyFetcher),
+		newTestCrawler("station-d", concurrencyFetcher),
+		newTestCrawler("station-e", concurrencyFetcher),
+	}
+
+	report := Orchestrator{Workers: 2}.RunCrawlers(context.Background(), crawlers)
+
+	if len(report.Reports) != len(crawlers) {
+		t.Fatalf("Orchestrator RunCrawlers: got %d reports, expected %d",
+			len(report.Reports), len(crawlers))
+	}
+	for i, stationReport := range report.Reports {
+		if stationReport.StationId != crawlers[i].stationId {
+			t.Errorf("Orchestrator RunCrawlers: got report for `%s` at index %d, expected `%s`",
+				stationReport.StationId, i, crawlers[i].stationId)
+		}
+		if failed := stationReport.StationId == "station-b"; stationReport.Failed() != failed {
+			t.Errorf("Orchestrator RunCrawlers: got failed `%v` for `%s`, expected `%v`",
+				stationReport.Failed(), stationReport.StationId, failed)
+		}
+	}
+	if report.StationsFailed != 1 || report.RecordsPersisted != 4 || report.RecordsFetched != 8 {
+		t.Errorf("Orchestrator RunCrawlers: got aggregated report `%+v`", report)
+	}
+	if max > 2 {
+		t.Errorf("Orchestrator RunCrawlers: got %d concurrent crawls, expected at most 2", max)
+	}
+}
+
+func TestOrchestrator_RunStations(t *testing.T) {
+	stations := []StationConfig{
+		{ID: "kronehit", Fetcher: "kronehit"},
+		{ID: "unknown", Fetcher: "unknown"},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report := Orchestrator{}.RunStations(ctx, stations, MockHomeBaseSuccess{})
+
+	if report.StationsFailed != 2 {
+		t.Errorf("Orchestrator RunStations: got %d failed stations, expected 2",
+			report.StationsFailed)
+	}
+	if report.Reports[0].Err != context.Canceled {
+		t.Errorf("Orchestrator RunStations: got error `%v` for `kronehit`, expected `%v`",
+			report.Reports[0].Err, context.Canceled)
+	}
+}
+
+func TestOrchestrator_Notifiers(t *testing.T) {
+	notifier := &MockNotifier{}
+	failing := &MockFetcherStats{MockFetcher{batches: [][]*model.TrackRecord{trackRecordBatch1}},
+		fetcher.Stats{Items: 4, Failed: 4}}
+	crawlers := []Crawler{
+		newTestCrawler("station-a", &MockFetcher{batches: [][]*model.TrackRecord{
+			trackRecordBatch1}}),
+		newTestCrawler("station-b", failing),
+	}
+
+	report := Orchestrator{Notifiers: []Notifier{notifier}}.RunCrawlers(context.Background(),
+		crawlers)
+	if report.StationsFailed != 1 || len(notifier.alerts) != 1 ||
+		notifier.alerts[0].StationId != "station-b" {
+		t.Errorf("Orchestrator RunCrawlers: got %d failed stations and alerts %v",
+			report.StationsFailed, alertSummary(notifier.alerts))
+	}
+}
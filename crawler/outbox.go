@@ -0,0 +1,275 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/RadioCheckerApp/api/model"
+	"github.com/RadioCheckerApp/crawlers/logging"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultMaxReplayAttempts is the number of attempts after which ReplayOutbox moves an entry to
+// the dead-letter state, unless configured otherwise.
+const DefaultMaxReplayAttempts = 5
+
+// OutboxEntry is a TrackRecord that could not be persisted.
+type OutboxEntry struct {
+	TrackRecord  *model.TrackRecord `json:"trackRecord"`
+	Attempts     int                `json:"attempts"`
+	LastError    string             `json:"lastError"`
+	FirstFailure time.Time          `json:"firstFailure"`
+	LastAttempt  time.Time          `json:"lastAttempt"`
+	// DeadLetter is set once an entry exceeded the maximum number of attempts. Dead letters
+	// are kept in the outbox for manual inspection but are not replayed anymore.
+	DeadLetter bool `json:"deadLetter"`
+}
+
+func (entry OutboxEntry) key() string {
+	return fmt.Sprintf("%s/%d", entry.TrackRecord.StationId, entry.TrackRecord.Timestamp)
+}
+
+// Outbox stores TrackRecords that could not be persisted, so that they can be replayed later.
+// Implementations must be safe for concurrent use.
+type Outbox interface {
+	// Add stores a TrackRecord whose persistence failed with `cause`. Adding a TrackRecord
+	// that is already stored increases the attempt count of its entry, a dead letter is
+	// replayed again with its attempts counted anew.
+	Add(trackRecord *model.TrackRecord, cause error) error
+	// Entries returns all stored entries, including dead letters.
+	Entries() ([]OutboxEntry, error)
+	// Update replaces all stored entries with the result of `update`. No entries can be added
+	// while `update` is running.
+	Update(update func(entries []OutboxEntry) []OutboxEntry) error
+}
+
+// FileOutbox is an Outbox that stores its entries as JSON lines in a local file. Other stores,
+// e.g. a database, can be used by implementing Outbox.
+//
+// The file may be shared between processes, e.g. a daemon adding entries while the replay
+// command runs: every access is guarded by an advisory lock on the file `<path>.lock`, since
+// Update replaces the file itself. Adding entries blocks while another process updates them.
+type FileOutbox struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileOutbox(path string) *FileOutbox {
+	return &FileOutbox{path: path}
+}
+
+func (outbox *FileOutbox) Add(trackRecord *model.TrackRecord, cause error) error {
+	now := time.Now()
+	entry := OutboxEntry{
+		TrackRecord:  trackRecord,
+		Attempts:     1,
+		LastError:    cause.Error(),
+		FirstFailure: now,
+		LastAttempt:  now,
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := outbox.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	file, err := os.OpenFile(outbox.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (outbox *FileOutbox) Entries() ([]OutboxEntry, error) {
+	unlock, err := outbox.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return outbox.read()
+}
+
+func (outbox *FileOutbox) Update(update func(entries []OutboxEntry) []OutboxEntry) error {
+	unlock, err := outbox.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := outbox.read()
+	if err != nil {
+		return err
+	}
+	return outbox.write(update(entries))
+}
+
+// lock locks the outbox against other goroutines and other processes, see FileOutbox.
+func (outbox *FileOutbox) lock() (unlock func(), err error) {
+	outbox.mu.Lock()
+	unlockFile, err := lockFile(outbox.path + ".lock")
+	if err != nil {
+		outbox.mu.Unlock()
+		return nil, fmt.Errorf("unable to lock outbox: %w", err)
+	}
+	return func() {
+		if err := unlockFile(); err != nil {
+			slog.Warn("Unable to unlock outbox.", "path", outbox.path, "err", err)
+		}
+		outbox.mu.Unlock()
+	}, nil
+}
+
+// read returns the entries of the file. Since Add only appends to the file, entries of the same
+// TrackRecord are merged. A failure appended to a dead letter starts a new series of attempts.
+func (outbox *FileOutbox) read() ([]OutboxEntry, error) {
+	file, err := os.Open(outbox.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []OutboxEntry
+	indices := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry OutboxEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.TrackRecord == nil {
+			slog.Warn("Skipped malformed line of outbox.", "line", lineNumber, "path", outbox.path)
+			continue
+		}
+
+		i, ok := indices[entry.key()]
+		if !ok {
+			indices[entry.key()] = len(entries)
+			entries = append(entries, entry)
+			continue
+		}
+		merged := &entries[i]
+		if merged.DeadLetter && !entry.DeadLetter {
+			merged.Attempts, merged.FirstFailure = 0, entry.FirstFailure
+		}
+		merged.Attempts += entry.Attempts
+		merged.LastError = entry.LastError
+		merged.LastAttempt = entry.LastAttempt
+		merged.DeadLetter = entry.DeadLetter
+	}
+	return entries, scanner.Err()
+}
+
+// write atomically replaces the file with the provided entries.
+func (outbox *FileOutbox) write(entries []OutboxEntry) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(outbox.path), filepath.Base(outbox.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), outbox.path)
+}
+
+// ReplayReport describes the outcome of a ReplayOutbox run.
+type ReplayReport struct {
+	Persisted   int `json:"persisted"`
+	Failed      int `json:"failed"`
+	DeadLetters int `json:"deadLetters"`
+	Remaining   int `json:"remaining"`
+}
+
+// ReplayOutbox tries to persist all pending entries of the outbox. Persisted entries are removed,
+// failed entries are kept with an increased attempt count. Entries that failed `maxAttempts`
+// times are moved to the dead-letter state.
+//
+// The entries are persisted without locking the outbox, so crawlers may add entries meanwhile.
+// The results are applied by key in a single Update afterwards.
+func ReplayOutbox(ctx context.Context, outbox Outbox, homeBase HomeBase,
+	maxAttempts int) (ReplayReport, error) {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxReplayAttempts
+	}
+
+	entries, err := outbox.Entries()
+	if err != nil {
+		return ReplayReport{}, err
+	}
+	var report ReplayReport
+	results := make(map[string]error)
+	for _, entry := range entries {
+		if entry.DeadLetter || ctx.Err() != nil {
+			continue
+		}
+		err := homeBase.PersistTrackRecord(ctx, entry.TrackRecord)
+		if err == nil {
+			report.Persisted++
+		} else {
+			report.Failed++
+		}
+		results[entry.key()] = err
+	}
+
+	err = outbox.Update(func(entries []OutboxEntry) []OutboxEntry {
+		var remaining []OutboxEntry
+		for _, entry := range entries {
+			err, replayed := results[entry.key()]
+			if replayed && err == nil {
+				continue
+			}
+			if replayed && !entry.DeadLetter {
+				entry.Attempts++
+				entry.LastError = err.Error()
+				entry.LastAttempt = time.Now()
+				if entry.Attempts >= maxAttempts {
+					logging.FromContext(ctx).Error("Giving up on TrackRecord.", "key", entry.key(),
+						"attempts", entry.Attempts, "err", err)
+					entry.DeadLetter = true
+				}
+			}
+			remaining = append(remaining, entry)
+		}
+
+		report.DeadLetters, report.Remaining = 0, 0
+		for _, entry := range remaining {
+			if entry.DeadLetter {
+				report.DeadLetters++
+			} else {
+				report.Remaining++
+			}
+		}
+		return remaining
+	})
+	return report, err
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package crawler
+
+// lockFile does not lock anything on platforms without flock, hence a FileOutbox must not be
+// shared between processes there.
+func lockFile(path string) (unlock func() error, err error) {
+	return func() error { return nil }, nil
+}
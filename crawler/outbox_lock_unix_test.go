@@ -0,0 +1,41 @@
+//go:build unix
+
+package crawler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFileOutbox_Update_SharedFile(t *testing.T) {
+	replay, cleanup := newTestOutbox(t)
+	defer cleanup()
+	// a second instance stands in for another process sharing the file
+	daemon := NewFileOutbox(replay.path)
+	replay.Add(trackRecordBatch0[0], errors.New("first"))
+
+	added := make(chan error, 1)
+	err := replay.Update(func(entries []OutboxEntry) []OutboxEntry {
+		go func() { added <- daemon.Add(trackRecordBatch0[1], errors.New("first")) }()
+		select {
+		case err := <-added:
+			t.Errorf("FileOutbox Add: returned (%v) while the outbox was updated", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("FileOutbox Update: got error `%v`", err)
+	}
+	if err := <-added; err != nil {
+		t.Fatalf("FileOutbox Add: got error `%v`", err)
+	}
+
+	entries, err := replay.Entries()
+	if err != nil || len(entries) != 1 ||
+		entries[0].TrackRecord.Timestamp != trackRecordBatch0[1].Timestamp {
+		t.Errorf("FileOutbox Entries: got (%+v, %v), expected the entry added during Update",
+			entries, err)
+	}
+}
@@ -0,0 +1,157 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"github.com/RadioCheckerApp/api/model"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestOutbox(t *testing.T) (*FileOutbox, func()) {
+	dir, err := ioutil.TempDir("", "outbox")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	return NewFileOutbox(filepath.Join(dir, "outbox.jsonl")), func() { os.RemoveAll(dir) }
+}
+
+func TestFileOutbox_Add(t *testing.T) {
+	outbox, cleanup := newTestOutbox(t)
+	defer cleanup()
+
+	if entries, err := outbox.Entries(); err != nil || len(entries) != 0 {
+		t.Errorf("FileOutbox Entries: got (%v, %v) for missing file, expected no entries",
+			entries, err)
+	}
+
+	outbox.Add(trackRecordBatch0[0], errors.New("first"))
+	outbox.Add(trackRecordBatch0[1], errors.New("first"))
+	outbox.Add(trackRecordBatch0[0], errors.New("second"))
+
+	entries, err := outbox.Entries()
+	if err != nil {
+		t.Fatalf("FileOutbox Entries: got error `%v`", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("FileOutbox Entries: got %d entries, expected 2", len(entries))
+	}
+	if entries[0].Attempts != 2 || entries[0].LastError != "second" ||
+		entries[0].TrackRecord.Timestamp != trackRecordBatch0[0].Timestamp {
+		t.Errorf("FileOutbox Entries: got merged entry `%+v`", entries[0])
+	}
+	if entries[1].Attempts != 1 || entries[1].DeadLetter {
+		t.Errorf("FileOutbox Entries: got entry `%+v`", entries[1])
+	}
+}
+
+func TestReplayOutbox(t *testing.T) {
+	outbox, cleanup := newTestOutbox(t)
+	defer cleanup()
+
+	failing := &model.TrackRecord{"fail", 1535301540, "track", model.Track{"Alan Walker", "Faded"}}
+	outbox.Add(trackRecordBatch0[0], errors.New("homebase unavailable"))
+	outbox.Add(failing, errors.New("homebase unavailable"))
+
+	var tests = []struct {
+		expected ReplayReport
+	}{
+		{ReplayReport{Persisted: 1, Failed: 1, Remaining: 1}},
+		{ReplayReport{Failed: 1, DeadLetters: 1}},
+		// dead letters are not replayed anymore
+		{ReplayReport{DeadLetters: 1}},
+	}
+
+	for i, test := range tests {
+		report, err := ReplayOutbox(context.Background(), outbox, MockHomeBaseSuccess{}, 3)
+		if err != nil || report != test.expected {
+			t.Errorf("ReplayOutbox (run %d): got (%+v, %v), expected (%+v, nil)",
+				i, report, err, test.expected)
+		}
+	}
+
+	entries, _ := outbox.Entries()
+	if len(entries) != 1 || entries[0].TrackRecord.StationId != "fail" ||
+		entries[0].Attempts != 3 || !entries[0].DeadLetter || entries[0].LastError != "just a test" {
+		t.Errorf("ReplayOutbox: got remaining entries `%+v`", entries)
+	}
+}
+
+func TestFileOutbox_Add_DeadLetter(t *testing.T) {
+	outbox, cleanup := newTestOutbox(t)
+	defer cleanup()
+
+	failing := &model.TrackRecord{"fail", 1535301540, "track", model.Track{"Alan Walker", "Faded"}}
+	outbox.Add(failing, errors.New("homebase unavailable"))
+	if _, err := ReplayOutbox(context.Background(), outbox, MockHomeBaseSuccess{}, 2); err != nil {
+		t.Fatalf("ReplayOutbox: got error `%v`", err)
+	}
+
+	// a new failure of a dead letter replays it again
+	outbox.Add(failing, errors.New("homebase unavailable"))
+	entries, _ := outbox.Entries()
+	if len(entries) != 1 || entries[0].DeadLetter || entries[0].Attempts != 1 {
+		t.Fatalf("FileOutbox Entries: got `%+v`, expected a pending entry", entries)
+	}
+	report, err := ReplayOutbox(context.Background(), outbox, MockHomeBaseSuccess{}, 3)
+	if expected := (ReplayReport{Failed: 1, Remaining: 1}); err != nil || report != expected {
+		t.Errorf("ReplayOutbox: got (%+v, %v), expected (%+v, nil)", report, err, expected)
+	}
+}
+
+// outboxingHomeBase adds a TrackRecord to the outbox whenever it persists one, like a crawler
+// running during the replay.
+type outboxingHomeBase struct {
+	MockHomeBaseSuccess
+	outbox Outbox
+}
+
+func (homeBase outboxingHomeBase) PersistTrackRecord(ctx context.Context,
+	trackRecord *model.TrackRecord) error {
+	added := *trackRecord
+	added.Timestamp++
+	if err := homeBase.outbox.Add(&added, errors.New("homebase unavailable")); err != nil {
+		return err
+	}
+	return homeBase.MockHomeBaseSuccess.PersistTrackRecord(ctx, trackRecord)
+}
+
+func TestReplayOutbox_ConcurrentAdd(t *testing.T) {
+	outbox, cleanup := newTestOutbox(t)
+	defer cleanup()
+
+	outbox.Add(trackRecordBatch0[0], errors.New("homebase unavailable"))
+	report, err := ReplayOutbox(context.Background(), outbox, outboxingHomeBase{outbox: outbox}, 3)
+	if expected := (ReplayReport{Persisted: 1, Remaining: 1}); err != nil || report != expected {
+		t.Errorf("ReplayOutbox: got (%+v, %v), expected (%+v, nil)", report, err, expected)
+	}
+	entries, _ := outbox.Entries()
+	if len(entries) != 1 || entries[0].TrackRecord.Timestamp != trackRecordBatch0[0].Timestamp+1 ||
+		entries[0].Attempts != 1 {
+		t.Errorf("ReplayOutbox: got remaining entries `%+v`", entries)
+	}
+}
+
+func TestCrawler_Crawl_Outbox(t *testing.T) {
+	outbox, cleanup := newTestOutbox(t)
+	defer cleanup()
+
+	crawler := Crawler{
+		stationId:                  "station-a",
+		fetcher:                    &MockFetcher{batches: [][]*model.TrackRecord{trackRecordBatch1}},
+		homeBase:                   MockHomeBaseFail{},
+		latestTrackRecordTimestamp: 1234567890,
+	}.WithOutbox(outbox)
+
+	report := crawler.Crawl()
+
+	if report.RecordsFailed != 1 || report.RecordsOutboxed != 1 {
+		t.Errorf("Crawler Crawl: got report `%+v`, expected 1 outboxed record", report)
+	}
+	entries, _ := outbox.Entries()
+	if len(entries) != 1 || entries[0].TrackRecord.Timestamp != trackRecordBatch1[0].Timestamp {
+		t.Errorf("Crawler Crawl: got outbox entries `%+v`", entries)
+	}
+}
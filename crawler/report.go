@@ -0,0 +1,72 @@
+package crawler
+
+import (
+	"encoding/json"
+	"github.com/RadioCheckerApp/api/model"
+	"github.com/RadioCheckerApp/crawlers/fetcher"
+	"time"
+)
+
+// CrawlReport describes the outcome of a single crawler run.
+type CrawlReport struct {
+	StationId string `json:"stationId"`
+	// RunId identifies the run in the log, see the field `run`.
+	RunId string    `json:"runId"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	PagesFetched     int `json:"pagesFetched"`
+	RecordsFetched   int `json:"recordsFetched"`
+	RecordsPersisted int `json:"recordsPersisted"`
+	// RecordsSkipped counts the fetched TrackRecords that were already known to the homebase.
+	RecordsSkipped int `json:"recordsSkipped"`
+	RecordsFailed  int `json:"recordsFailed"`
+	// RecordsOutboxed counts the failed TrackRecords that were added to the outbox.
+	RecordsOutboxed int `json:"recordsOutboxed"`
+	// FetchStats counts the items the fetcher received from the station's source, including
+	// the ones it failed to parse. It is empty if the fetcher does not report its stats.
+	FetchStats fetcher.Stats `json:"fetchStats"`
+
+	// UpToDate is set if the crawler reached the latest TrackRecord known to the homebase.
+	UpToDate bool `json:"upToDate"`
+	// NewestTimestamp and OldestTimestamp are the timestamps of the newest and the oldest
+	// TrackRecord persisted during the run, or 0 if no TrackRecord has been persisted.
+	NewestTimestamp int64 `json:"newestTimestamp"`
+	OldestTimestamp int64 `json:"oldestTimestamp"`
+
+	// Err is the error that ended the run, if any. It is encoded as `error` message in JSON.
+	Err error `json:"-"`
+}
+
+// Duration returns how long the run took.
+func (report CrawlReport) Duration() time.Duration {
+	return report.End.Sub(report.Start)
+}
+
+// Failed reports whether the run ended with an error.
+func (report CrawlReport) Failed() bool {
+	return report.Err != nil
+}
+
+// MarshalJSON encodes the report including the message of its error.
+func (report CrawlReport) MarshalJSON() ([]byte, error) {
+	type plainReport CrawlReport
+	var errorMessage string
+	if report.Err != nil {
+		errorMessage = report.Err.Error()
+	}
+	return json.Marshal(struct {
+		plainReport
+		Error string `json:"error,omitempty"`
+	}{plainReport(report), errorMessage})
+}
+
+func (report *CrawlReport) addPersisted(trackRecord *model.TrackRecord) {
+	report.RecordsPersisted++
+	if report.NewestTimestamp == 0 || trackRecord.Timestamp > report.NewestTimestamp {
+		report.NewestTimestamp = trackRecord.Timestamp
+	}
+	if report.OldestTimestamp == 0 || trackRecord.Timestamp < report.OldestTimestamp {
+		report.OldestTimestamp = trackRecord.Timestamp
+	}
+}
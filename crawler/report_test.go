@@ -0,0 +1,31 @@
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCrawlReport_MarshalJSON(t *testing.T) {
+	report := CrawlReport{StationId: "station-a", RecordsPersisted: 2, Err: errors.New("failed")}
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("CrawlReport MarshalJSON: got error `%s`", err)
+	}
+
+	var decoded map[string]interface{}
+	json.Unmarshal(data, &decoded)
+	if decoded["stationId"] != "station-a" || decoded["recordsPersisted"] != float64(2) ||
+		decoded["error"] != "failed" {
+		t.Errorf("CrawlReport MarshalJSON: got `%s`", data)
+	}
+}
+
+func TestCrawlReport_Duration(t *testing.T) {
+	start := time.Now()
+	report := CrawlReport{Start: start, End: start.Add(3 * time.Second)}
+	if report.Duration() != 3*time.Second {
+		t.Errorf("CrawlReport Duration: got %s, expected 3s", report.Duration())
+	}
+}
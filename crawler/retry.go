@@ -0,0 +1,106 @@
+package crawler
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy determines how often and when requests to the homebase are repeated after a
+// network error or a response with a retryable status code.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts per request, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Each further retry waits Multiplier
+	// times longer than the previous one, but never longer than MaxBackoff. Requests are not
+	// retried at all if the server asks for a longer delay using `Retry-After`.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter randomizes each delay by up to the given fraction (0 to 1) of the delay.
+	Jitter float64
+	// RetryableStatusCodes lists the HTTP status codes that cause a request to be retried.
+	RetryableStatusCodes []int
+}
+
+// DefaultRetryPolicy is used by HomeBaseConnector if no RetryPolicy is set.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+	RetryableStatusCodes: []int{
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+func (policy RetryPolicy) isRetryableStatus(statusCode int) bool {
+	for _, retryable := range policy.RetryableStatusCodes {
+		if statusCode == retryable {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the delay before retry number `retry` (starting at 1).
+func (policy RetryPolicy) backoff(retry int) time.Duration {
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(policy.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if policy.MaxBackoff > 0 && delay > float64(policy.MaxBackoff) {
+		delay = float64(policy.MaxBackoff)
+	}
+	if policy.Jitter > 0 {
+		delay += delay * policy.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// retryAfter parses the value of a `Retry-After` header, which is either a number of seconds
+// or an HTTP date.
+func retryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sleep waits for `delay` and reports whether the wait was completed before `ctx` was done.
+// Waits that would outlast the deadline of `ctx` are not started at all.
+func sleep(ctx context.Context, delay time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+		return false
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,127 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second,
+		Multiplier: 2}
+	var tests = []struct {
+		retry         int
+		expectedDelay time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+	}
+
+	for _, test := range tests {
+		if delay := policy.backoff(test.retry); delay != test.expectedDelay {
+			t.Errorf("RetryPolicy backoff(%d): got %s, expected %s",
+				test.retry, delay, test.expectedDelay)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if delay := policy.backoff(1); delay < 50*time.Millisecond || delay > 150*time.Millisecond {
+			t.Errorf("RetryPolicy backoff(1): got %s, expected value in [50ms, 150ms]", delay)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2018, 10, 11, 12, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		header        string
+		expectedDelay time.Duration
+		expectedOk    bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"-1", 0, false},
+		{"Thu, 11 Oct 2018 12:00:30 GMT", 30 * time.Second, true},
+		{"Thu, 11 Oct 2018 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, test := range tests {
+		delay, ok := retryAfter(test.header, now)
+		if delay != test.expectedDelay || ok != test.expectedOk {
+			t.Errorf("retryAfter(%q): got (%s, %v), expected (%s, %v)",
+				test.header, delay, ok, test.expectedDelay, test.expectedOk)
+		}
+	}
+}
+
+func TestHomeBaseConnector_sendHTTPRequest_Retry(t *testing.T) {
+	var tests = []struct {
+		failures         int32
+		statusCode       int
+		retryAfter       string
+		expectedAttempts int32
+		expectedBody     string
+		expectedErr      error
+	}{
+		{2, http.StatusServiceUnavailable, "0", 3, `{"success": true}`, nil},
+		{5, http.StatusTooManyRequests, "0", 3, `{"success": false}`, ErrRateLimited},
+		{5, http.StatusBadRequest, "0", 1, `{"success": false}`, ErrBadRequest},
+		// Retry-After exceeds the maximum backoff of 5 seconds
+		{5, http.StatusTooManyRequests, "3600", 1, `{"success": false}`, ErrRateLimited},
+	}
+
+	for _, test := range tests {
+		var attempts int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+			r *http.Request) {
+			if atomic.AddInt32(&attempts, 1) <= test.failures {
+				w.Header().Set("Retry-After", test.retryAfter)
+				w.WriteHeader(test.statusCode)
+				w.Write([]byte(`{"success": false}`))
+				return
+			}
+			w.Write([]byte(`{"success": true}`))
+		}))
+
+		policy := DefaultRetryPolicy
+		policy.InitialBackoff = time.Millisecond
+		api := HomeBaseConnector{RetryPolicy: &policy}
+		body, err := api.sendHTTPRequest(context.Background(), http.MethodPut, server.URL,
+			[]byte("{}"))
+		server.Close()
+
+		if !errors.Is(err, test.expectedErr) || string(body) != test.expectedBody ||
+			attempts != test.expectedAttempts {
+			t.Errorf("sendHTTPRequest (status %d): got (%s, %v) after %d attempts, "+
+				"expected (%s, %v) after %d attempts", test.statusCode, body, err, attempts,
+				test.expectedBody, test.expectedErr, test.expectedAttempts)
+		}
+	}
+}
+
+func TestHomeBaseConnector_sendHTTPRequest_NetworkError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	policy := RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Hour}
+	api := HomeBaseConnector{RetryPolicy: &policy}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := api.sendHTTPRequest(ctx, http.MethodGet, url, nil); err == nil {
+		t.Errorf("sendHTTPRequest: expected network error")
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("sendHTTPRequest: waited for a retry that exceeds the context deadline")
+	}
+}
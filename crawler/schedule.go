@@ -0,0 +1,211 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule determines when a crawler should run next.
+type Schedule interface {
+	// Next returns the first activation time after `t`.
+	Next(t time.Time) time.Time
+}
+
+// ParseSchedule parses the schedule of a station configuration. Supported formats are
+//
+//	rate(<n> minute[s]|hour[s]|day[s])   as used by AWS CloudWatch, e.g. `rate(1 hour)`
+//	@every <duration>                    any Go duration, e.g. `@every 1h30m`
+//	@hourly, @daily                      shorthands for `0 * * * *` and `0 0 * * *`
+//	<minute> <hour> <dom> <month> <dow>  standard five field cron expressions
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case spec == "":
+		return nil, errors.New("schedule must not be empty")
+	case strings.HasPrefix(spec, "rate(") && strings.HasSuffix(spec, ")"):
+		return parseRate(spec[len("rate(") : len(spec)-1])
+	case strings.HasPrefix(spec, "@every "):
+		interval, err := time.ParseDuration(strings.TrimSpace(spec[len("@every "):]))
+		if err != nil {
+			return nil, errors.New("invalid schedule `" + spec + "`: " + err.Error())
+		}
+		return newIntervalSchedule(interval)
+	case spec == "@hourly":
+		return parseCron("0 * * * *")
+	case spec == "@daily" || spec == "@midnight":
+		return parseCron("0 0 * * *")
+	}
+	return parseCron(spec)
+}
+
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+func newIntervalSchedule(interval time.Duration) (Schedule, error) {
+	if interval < time.Minute {
+		return nil, errors.New("schedule interval must be at least one minute")
+	}
+	return intervalSchedule{interval}, nil
+}
+
+func (schedule intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(schedule.interval)
+}
+
+func parseRate(expression string) (Schedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 2 {
+		return nil, errors.New("invalid rate expression `" + expression + "`")
+	}
+	value, err := strconv.Atoi(fields[0])
+	if err != nil || value <= 0 {
+		return nil, errors.New("invalid rate value `" + fields[0] + "`")
+	}
+
+	var unit time.Duration
+	switch strings.TrimSuffix(fields[1], "s") {
+	case "minute":
+		unit = time.Minute
+	case "hour":
+		unit = time.Hour
+	case "day":
+		unit = 24 * time.Hour
+	default:
+		return nil, errors.New("invalid rate unit `" + fields[1] + "`")
+	}
+	return newIntervalSchedule(time.Duration(value) * unit)
+}
+
+// cronSchedule holds the allowed values of each cron field as bit sets.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domRestricted and dowRestricted are used to apply the cron rule that a day matches if
+	// either the day of month or the day of week matches when both fields are restricted.
+	domRestricted, dowRestricted bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func parseCron(spec string) (Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid schedule `%s`: expected %d cron fields, got %d",
+			spec, len(cronFields), len(fields))
+	}
+
+	var bits [5]uint64
+	for i, field := range fields {
+		var err error
+		bits[i], err = parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule `%s`: %s", spec, err)
+		}
+	}
+	// Sunday may be written as 0 or 7.
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return cronSchedule{
+		minute:        bits[0],
+		hour:          bits[1],
+		dom:           bits[2],
+		month:         bits[3],
+		dow:           bits[4],
+		domRestricted: !strings.HasPrefix(fields[2], "*"),
+		dowRestricted: !strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func parseCronField(field string, bounds cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangePart = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field `%s`", bounds.name, part)
+			}
+		}
+
+		low, high := bounds.min, bounds.max
+		if rangePart != "*" {
+			values := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = strconv.Atoi(values[0]); err != nil {
+				return 0, fmt.Errorf("invalid value in %s field `%s`", bounds.name, part)
+			}
+			high = low
+			if len(values) == 2 {
+				if high, err = strconv.Atoi(values[1]); err != nil {
+					return 0, fmt.Errorf("invalid value in %s field `%s`", bounds.name, part)
+				}
+			} else if step > 1 {
+				// `5/15` is short for `5-<max>/15`
+				high = bounds.max
+			}
+		}
+		if low < bounds.min || high > bounds.max || low > high {
+			return 0, fmt.Errorf("%s field `%s` out of range [%d, %d]",
+				bounds.name, part, bounds.min, bounds.max)
+		}
+
+		for value := low; value <= high; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+func (schedule cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Every valid expression matches at least once within a leap year cycle.
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if schedule.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !schedule.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if schedule.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if schedule.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (schedule cronSchedule) matchesDay(t time.Time) bool {
+	domMatch := schedule.dom&(1<<uint(t.Day())) != 0
+	dowMatch := schedule.dow&(1<<uint(t.Weekday())) != 0
+	if schedule.domRestricted && schedule.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
@@ -0,0 +1,67 @@
+package crawler
+
+import (
+	"testing"
+	"time"
+)
+
+const timeFormat = "2006-01-02 15:04:05"
+
+func TestParseSchedule(t *testing.T) {
+	start, _ := time.ParseInLocation(timeFormat, "2018-10-11 23:42:10", loc)
+	var tests = []struct {
+		spec         string
+		expectedNext string
+		expectedErr  bool
+	}{
+		{"rate(1 hour)", "2018-10-12 00:42:10", false},
+		{"rate(5 minutes)", "2018-10-11 23:47:10", false},
+		{"rate(2 days)", "2018-10-13 23:42:10", false},
+		{"@every 1h30m", "2018-10-12 01:12:10", false},
+		{"@hourly", "2018-10-12 00:00:00", false},
+		{"@daily", "2018-10-12 00:00:00", false},
+		{"*/15 * * * *", "2018-10-11 23:45:00", false},
+		{"5/15 * * * *", "2018-10-11 23:50:00", false},
+		{"30 6-8 * * *", "2018-10-12 06:30:00", false},
+		{"0 12 1,15 * *", "2018-10-15 12:00:00", false},
+		{"0 12 * * 7", "2018-10-14 12:00:00", false},
+		{"0 12 * * 1-5", "2018-10-12 12:00:00", false},
+		{"0 0 31 12 *", "2018-12-31 00:00:00", false},
+		{"0 0 29 2 *", "2020-02-29 00:00:00", false},
+		{"0 0 1 * 1", "2018-10-15 00:00:00", false},
+		{"", "", true},
+		{"rate(1 week)", "", true},
+		{"rate(0 hours)", "", true},
+		{"@every 10s", "", true},
+		{"* * * *", "", true},
+		{"60 * * * *", "", true},
+		{"*/0 * * * *", "", true},
+		{"a * * * *", "", true},
+		{"0 0 30 2 *", "", false},
+	}
+
+	for _, test := range tests {
+		schedule, err := ParseSchedule(test.spec)
+		if (err != nil) != test.expectedErr {
+			t.Errorf("ParseSchedule(%q): got error: (%v), expected error: (%v)",
+				test.spec, err, test.expectedErr)
+		}
+		if err != nil {
+			continue
+		}
+
+		next := schedule.Next(start)
+		if test.expectedNext == "" {
+			if !next.IsZero() {
+				t.Errorf("ParseSchedule(%q).Next(%s): got %s, expected zero time",
+					test.spec, start, next)
+			}
+			continue
+		}
+		expectedNext, _ := time.ParseInLocation(timeFormat, test.expectedNext, loc)
+		if !next.Equal(expectedNext) {
+			t.Errorf("ParseSchedule(%q).Next(%s): got %s, expected %s",
+				test.spec, start, next, expectedNext)
+		}
+	}
+}
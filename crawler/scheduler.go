@@ -0,0 +1,110 @@
+package crawler
+
+import (
+	"context"
+	"github.com/RadioCheckerApp/crawlers/logging"
+	"log/slog"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scheduler runs jobs according to their schedules until its context is cancelled. Runs of the
+// same job never overlap: an activation that occurs while the previous run of the job is still
+// in progress is skipped. A run that panics is logged and does not affect the next runs.
+type Scheduler struct {
+	// Jitter is the maximum random delay added to every activation, so that jobs sharing a
+	// schedule do not hit the upstream APIs and the homebase at the very same moment.
+	Jitter time.Duration
+	// RunOnStart triggers every job once as soon as the scheduler is started.
+	RunOnStart bool
+
+	jobs     []*scheduledJob
+	inFlight sync.WaitGroup
+}
+
+type scheduledJob struct {
+	name     string
+	schedule Schedule
+	run      func(ctx context.Context)
+	running  int32
+}
+
+// Add registers a job that executes `run` whenever `schedule` activates. `run` receives the
+// context passed to Run, so that it is able to wind down once the scheduler is stopped.
+func (scheduler *Scheduler) Add(name string, schedule Schedule, run func(ctx context.Context)) {
+	scheduler.jobs = append(scheduler.jobs, &scheduledJob{name: name, schedule: schedule, run: run})
+}
+
+// Run starts all jobs and blocks until `ctx` is cancelled. Before returning, it waits for all
+// runs that are still in progress to finish.
+func (scheduler *Scheduler) Run(ctx context.Context) {
+	var loops sync.WaitGroup
+	for _, job := range scheduler.jobs {
+		loops.Add(1)
+		go func(job *scheduledJob) {
+			defer loops.Done()
+			scheduler.loop(ctx, job)
+		}(job)
+	}
+
+	loops.Wait()
+	logging.FromContext(ctx).Info("Scheduler stopped. Waiting for running jobs to finish.")
+	scheduler.inFlight.Wait()
+}
+
+func (scheduler *Scheduler) loop(ctx context.Context, job *scheduledJob) {
+	logger := logging.FromContext(ctx).With("job", job.name)
+	if scheduler.RunOnStart {
+		scheduler.trigger(ctx, logger, job)
+	}
+
+	for {
+		next := job.schedule.Next(time.Now())
+		if next.IsZero() {
+			logger.Warn("Schedule of job never activates again.")
+			return
+		}
+		next = next.Add(scheduler.jitter())
+		logger.Info("Next run of job scheduled.", "next", next.Format("2006-01-02 15:04:05"))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			scheduler.trigger(ctx, logger, job)
+		}
+	}
+}
+
+func (scheduler *Scheduler) trigger(ctx context.Context, logger *slog.Logger,
+	job *scheduledJob) {
+	if !atomic.CompareAndSwapInt32(&job.running, 0, 1) {
+		logger.Warn("Skipped run of job since its previous run is still in progress.")
+		return
+	}
+
+	scheduler.inFlight.Add(1)
+	go func() {
+		defer scheduler.inFlight.Done()
+		defer atomic.StoreInt32(&job.running, 0)
+		// a panicking job must neither stop the other jobs nor its own next runs
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("Job panicked.", "panic", r, "stack", string(debug.Stack()))
+			}
+		}()
+		job.run(ctx)
+	}()
+}
+
+func (scheduler *Scheduler) jitter() time.Duration {
+	if scheduler.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(scheduler.Jitter)))
+}
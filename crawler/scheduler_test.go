@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testSchedule struct {
+	interval time.Duration
+}
+
+func (schedule testSchedule) Next(t time.Time) time.Time {
+	return t.Add(schedule.interval)
+}
+
+func TestScheduler_Run(t *testing.T) {
+	var runs, concurrentRuns, maxConcurrentRuns, finishedRuns int32
+	scheduler := Scheduler{RunOnStart: true}
+	scheduler.Add("slow", testSchedule{5 * time.Millisecond}, func(ctx context.Context) {
+		atomic.AddInt32(&runs, 1)
+		current := atomic.AddInt32(&concurrentRuns, 1)
+		if current > atomic.LoadInt32(&maxConcurrentRuns) {
+			atomic.StoreInt32(&maxConcurrentRuns, current)
+		}
+		time.Sleep(30 * time.Millisecond)
+		atomic.AddInt32(&concurrentRuns, -1)
+		atomic.AddInt32(&finishedRuns, 1)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	scheduler.Run(ctx)
+
+	if runs < 2 {
+		t.Errorf("Scheduler Run: got %d runs, expected at least 2", runs)
+	}
+	if maxConcurrentRuns != 1 {
+		t.Errorf("Scheduler Run: got %d concurrent runs, expected 1", maxConcurrentRuns)
+	}
+	if finishedRuns != runs {
+		t.Errorf("Scheduler Run: returned before all runs finished (%d of %d)",
+			finishedRuns, runs)
+	}
+}
+
+func TestScheduler_Run_Stop(t *testing.T) {
+	scheduler := Scheduler{RunOnStart: true}
+	started := make(chan struct{})
+	scheduler.Add("blocking", testSchedule{time.Hour}, func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+	<-started
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Scheduler Run: did not return after the running job was stopped")
+	}
+}
+
+func TestScheduler_Run_Panic(t *testing.T) {
+	var runs int32
+	scheduler := Scheduler{RunOnStart: true}
+	scheduler.Add("panicking", testSchedule{5 * time.Millisecond}, func(ctx context.Context) {
+		atomic.AddInt32(&runs, 1)
+		panic("fetcher bug")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	scheduler.Run(ctx)
+
+	if runs < 2 {
+		t.Errorf("Scheduler Run: got %d runs of a panicking job, expected at least 2", runs)
+	}
+}
+
+func TestScheduler_jitter(t *testing.T) {
+	scheduler := Scheduler{Jitter: time.Second}
+	for i := 0; i < 100; i++ {
+		if jitter := scheduler.jitter(); jitter < 0 || jitter >= time.Second {
+			t.Errorf("Scheduler jitter(): got %s, expected value in [0, 1s)", jitter)
+		}
+	}
+	if jitter := (&Scheduler{}).jitter(); jitter != 0 {
+		t.Errorf("Scheduler jitter(): got %s, expected 0 without jitter", jitter)
+	}
+}
@@ -0,0 +1,133 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SecretProvider looks up secrets by name, e.g. in a secret manager. Providers are called
+// whenever a secret is resolved, so rotated secrets are picked up without a redeployment.
+type SecretProvider interface {
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   = map[string]SecretProvider{
+		"env":  EnvSecretProvider{},
+		"file": FileSecretProvider{},
+	}
+)
+
+// RegisterSecretProvider makes a SecretProvider available for references of the form
+// `scheme://name`. It panics if the scheme is already taken or the provider is nil.
+func RegisterSecretProvider(scheme string, provider SecretProvider) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+	if provider == nil {
+		panic("crawler: RegisterSecretProvider provider is nil")
+	}
+	if _, dup := secretProviders[scheme]; dup {
+		panic("crawler: RegisterSecretProvider called twice for scheme " + scheme)
+	}
+	secretProviders[scheme] = provider
+}
+
+// SecretSchemes returns the sorted schemes of all registered SecretProviders.
+func SecretSchemes() []string {
+	secretProvidersMu.RLock()
+	defer secretProvidersMu.RUnlock()
+	var schemes []string
+	for scheme := range secretProviders {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// ResolveSecret returns the secret referenced by `value`:
+//
+//	file:///run/secrets/token  content of the file, without trailing newlines
+//	env://NAME                 value of the environment variable NAME
+//	{scheme}://{name}          secret `name` of the provider registered for `scheme`
+//
+// Values without the prefix of a registered scheme, e.g. `https://...`, are returned unchanged.
+func ResolveSecret(ctx context.Context, value string) (string, error) {
+	i := strings.Index(value, "://")
+	if i <= 0 {
+		return value, nil
+	}
+	secretProvidersMu.RLock()
+	provider, ok := secretProviders[value[:i]]
+	secretProvidersMu.RUnlock()
+	if !ok {
+		return value, nil
+	}
+
+	secret, err := provider.Secret(ctx, value[i+len("://"):])
+	if err != nil {
+		// the error must not contain the secret, but the reference is safe to log
+		return "", fmt.Errorf("unable to resolve secret `%s`: %w", value, err)
+	}
+	return secret, nil
+}
+
+// EnvSecretProvider reads secrets from environment variables. It is registered as `env`.
+type EnvSecretProvider struct{}
+
+func (EnvSecretProvider) Secret(ctx context.Context, name string) (string, error) {
+	secret, ok := os.LookupEnv(name)
+	if !ok {
+		return "", errors.New("environment variable is not set")
+	}
+	return secret, nil
+}
+
+// FileSecretProvider reads every secret from its own file, e.g. secrets mounted by a container
+// runtime or a vault agent. Names are paths relative to Dir, or absolute paths if Dir is empty.
+// It is registered as `file`.
+type FileSecretProvider struct {
+	Dir string
+}
+
+func (provider FileSecretProvider) Secret(ctx context.Context, name string) (string, error) {
+	path := name
+	if provider.Dir != "" {
+		path = filepath.Join(provider.Dir, name)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// JSONSecretProvider reads secrets from a JSON object mapping names to secrets. The file is read
+// on every lookup. It is a local stand-in for secret managers in tests and development.
+type JSONSecretProvider struct {
+	Path string
+}
+
+func (provider JSONSecretProvider) Secret(ctx context.Context, name string) (string, error) {
+	data, err := ioutil.ReadFile(provider.Path)
+	if err != nil {
+		return "", err
+	}
+	var secrets map[string]string
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return "", fmt.Errorf("unable to decode `%s`: %s", provider.Path, err)
+	}
+	secret, ok := secrets[name]
+	if !ok {
+		return "", fmt.Errorf("secret not found in `%s`", provider.Path)
+	}
+	return secret, nil
+}
@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenPath := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenPath, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	vaultPath := filepath.Join(dir, "vault.json")
+	if err := ioutil.WriteFile(vaultPath, []byte(`{"rc/token": "vault-secret"}`),
+		0600); err != nil {
+		t.Fatal(err)
+	}
+	RegisterSecretProvider("test-vault", JSONSecretProvider{vaultPath})
+	os.Setenv("RC_TEST_SECRET", "env-secret")
+	defer os.Unsetenv("RC_TEST_SECRET")
+
+	var tests = []struct {
+		value          string
+		expectedSecret string
+		expectedErr    bool
+	}{
+		{"literal", "literal", false},
+		{"", "", false},
+		{"https://api.radiochecker.com", "https://api.radiochecker.com", false},
+		{"file://" + tokenPath, "file-secret", false},
+		{"file://" + filepath.Join(dir, "missing"), "", true},
+		{"env://RC_TEST_SECRET", "env-secret", false},
+		{"env://RC_TEST_MISSING", "", true},
+		{"test-vault://rc/token", "vault-secret", false},
+		{"test-vault://rc/missing", "", true},
+	}
+
+	for _, test := range tests {
+		secret, err := ResolveSecret(context.Background(), test.value)
+		if secret != test.expectedSecret || (err != nil) != test.expectedErr {
+			t.Errorf("ResolveSecret(%s): got (%s, %v), expected (%s, error: %v)", test.value,
+				secret, err, test.expectedSecret, test.expectedErr)
+		}
+	}
+
+	// secrets are read on every lookup
+	if err := ioutil.WriteFile(tokenPath, []byte("rotated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if secret, _ := ResolveSecret(context.Background(), "file://"+tokenPath); secret != "rotated" {
+		t.Errorf("ResolveSecret: got `%s` after rotation, expected `rotated`", secret)
+	}
+}
+
+func TestFileSecretProvider_Dir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "token"), []byte("secret\r\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := FileSecretProvider{Dir: dir}.Secret(context.Background(), "token")
+	if secret != "secret" || err != nil {
+		t.Errorf("Secret: got (%s, %v), expected (secret, <nil>)", secret, err)
+	}
+}
+
+func TestAuthConfig_ResolveSecrets(t *testing.T) {
+	os.Setenv("RC_TEST_TOKEN", "token")
+	defer os.Unsetenv("RC_TEST_TOKEN")
+
+	config, err := AuthConfig{APIKey: "key", Token: "env://RC_TEST_TOKEN"}.
+		ResolveSecrets(context.Background())
+	if err != nil || config.APIKey != "key" || config.Token != "token" {
+		t.Errorf("ResolveSecrets: got (%+v, %v), expected resolved token", config, err)
+	}
+	if _, err := (AuthConfig{Secret: "env://RC_TEST_MISSING"}).
+		ResolveSecrets(context.Background()); err == nil {
+		t.Errorf("ResolveSecrets: got no error for a missing secret")
+	}
+}
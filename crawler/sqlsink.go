@@ -0,0 +1,135 @@
+package crawler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/RadioCheckerApp/api/model"
+	"strings"
+	"time"
+)
+
+// SQLSink is a HomeBase that stores TrackRecords in the table `track_records` of a SQLite or
+// PostgreSQL database. The table is created if it does not exist.
+//
+// The database drivers are not imported by this package: programs using a SQLSink have to
+// import them, e.g. using the package crawler/drivers.
+type SQLSink struct {
+	db       *sql.DB
+	postgres bool
+}
+
+const createTrackRecordsTable = `CREATE TABLE IF NOT EXISTS track_records (
+	station_id TEXT NOT NULL,
+	airtime BIGINT NOT NULL,
+	type TEXT NOT NULL,
+	artist TEXT NOT NULL,
+	title TEXT NOT NULL,
+	PRIMARY KEY (station_id, airtime)
+)`
+
+// NewSQLiteSink opens the SQLite database stored at `path` using the driver `sqlite3`.
+func NewSQLiteSink(path string) (*SQLSink, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	return NewSQLSink(db, "sqlite3")
+}
+
+// NewPostgresSink connects to the PostgreSQL database described by `dsn` using the driver
+// `postgres`.
+func NewPostgresSink(dsn string) (*SQLSink, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return NewSQLSink(db, "postgres")
+}
+
+// NewSQLSink uses an open database. `driverName` determines the SQL dialect: `postgres` or
+// `pgx` for PostgreSQL, SQLite otherwise.
+func NewSQLSink(db *sql.DB, driverName string) (*SQLSink, error) {
+	sink := &SQLSink{db: db, postgres: driverName == "postgres" || driverName == "pgx"}
+	if _, err := db.Exec(createTrackRecordsTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to create table `track_records`: %s", err)
+	}
+	return sink, nil
+}
+
+// Close closes the database.
+func (sink *SQLSink) Close() error {
+	return sink.db.Close()
+}
+
+// query replaces the `?` placeholders of `query` with `$1`, `$2`, ... for PostgreSQL.
+func (sink *SQLSink) query(query string) string {
+	if !sink.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (sink *SQLSink) LatestTrackRecord(ctx context.Context,
+	stationId string) (*model.TrackRecord, error) {
+	row := sink.db.QueryRowContext(ctx, sink.query(
+		"SELECT station_id, airtime, type, artist, title FROM track_records "+
+			"WHERE station_id = ? ORDER BY airtime DESC LIMIT 1"), stationId)
+
+	var trackRecord model.TrackRecord
+	err := row.Scan(&trackRecord.StationId, &trackRecord.Timestamp, &trackRecord.Type,
+		&trackRecord.Track.Artist, &trackRecord.Track.Title)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoData
+	} else if err != nil {
+		return nil, err
+	}
+	return &trackRecord, nil
+}
+
+func (sink *SQLSink) TrackRecords(ctx context.Context, stationId string,
+	from, to time.Time) ([]*model.TrackRecord, error) {
+	rows, err := sink.db.QueryContext(ctx, sink.query(
+		"SELECT station_id, airtime, type, artist, title FROM track_records "+
+			"WHERE station_id = ? AND airtime >= ? AND airtime <= ? ORDER BY airtime"),
+		stationId, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trackRecords []*model.TrackRecord
+	for rows.Next() {
+		var trackRecord model.TrackRecord
+		err := rows.Scan(&trackRecord.StationId, &trackRecord.Timestamp, &trackRecord.Type,
+			&trackRecord.Track.Artist, &trackRecord.Track.Title)
+		if err != nil {
+			return nil, err
+		}
+		trackRecords = append(trackRecords, &trackRecord)
+	}
+	return trackRecords, rows.Err()
+}
+
+// PersistTrackRecord inserts the TrackRecord or replaces the track aired at the same time.
+func (sink *SQLSink) PersistTrackRecord(ctx context.Context,
+	trackRecord *model.TrackRecord) error {
+	_, err := sink.db.ExecContext(ctx, sink.query(
+		"INSERT INTO track_records (station_id, airtime, type, artist, title) "+
+			"VALUES (?, ?, ?, ?, ?) ON CONFLICT (station_id, airtime) DO UPDATE SET "+
+			"type = excluded.type, artist = excluded.artist, title = excluded.title"),
+		trackRecord.StationId, trackRecord.Timestamp, trackRecord.Type,
+		trackRecord.Track.Artist, trackRecord.Track.Title)
+	return err
+}
@@ -0,0 +1,35 @@
+package crawler
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLSink_SQLite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink, err := NewSQLiteSink(filepath.Join(dir, "tracks.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteSink: got error (%v)", err)
+	}
+	defer sink.Close()
+	testSink(t, sink, sink)
+}
+
+func TestSQLSink_query(t *testing.T) {
+	query := "SELECT * FROM track_records WHERE station_id = ? AND airtime >= ?"
+	if got := (&SQLSink{}).query(query); got != query {
+		t.Errorf("query: got `%s` for SQLite, expected `%s`", got, query)
+	}
+	expected := "SELECT * FROM track_records WHERE station_id = $1 AND airtime >= $2"
+	if got := (&SQLSink{postgres: true}).query(query); got != expected {
+		t.Errorf("query: got `%s` for PostgreSQL, expected `%s`", got, expected)
+	}
+}
@@ -0,0 +1,69 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/RadioCheckerApp/crawlers/fetcher"
+)
+
+// DefaultMaxFailureRate is the percentage of fetched items that may fail to parse within a run
+// if the station does not configure `max_failure_rate`.
+const DefaultMaxFailureRate = 50.0
+
+// ErrThresholdExceeded is the error of a crawler run whose fetcher failed to parse or skipped
+// too many items, which usually means that the source changed its format. Use errors.Is to check
+// for it.
+var ErrThresholdExceeded = errors.New("fetcher threshold exceeded")
+
+// Thresholds are the highest percentages of the items fetched within a run that may fail to
+// parse or be skipped before the run is failed. A threshold of 0 fails a run as soon as a single
+// item fails or is skipped.
+type Thresholds struct {
+	// MaxFailureRate is the percentage of items that may fail to parse. DefaultMaxFailureRate is
+	// used if it is nil.
+	MaxFailureRate *float64
+	// MaxSkipRate is the percentage of items that may not result in a TrackRecord, including
+	// the ones that failed to parse. It is not checked if it is nil.
+	MaxSkipRate *float64
+}
+
+// check returns an error wrapping ErrThresholdExceeded if `stats` exceed the thresholds.
+func (thresholds Thresholds) check(stats fetcher.Stats) error {
+	maxFailureRate := DefaultMaxFailureRate
+	if thresholds.MaxFailureRate != nil {
+		maxFailureRate = *thresholds.MaxFailureRate
+	}
+	if rate := stats.FailureRate(); rate > maxFailureRate {
+		return fmt.Errorf("%w: %d of %d items (%.1f%%) failed to parse, max %.1f%%",
+			ErrThresholdExceeded, stats.Failed, stats.Items, rate, maxFailureRate)
+	}
+	if rate := stats.SkipRate(); thresholds.MaxSkipRate != nil &&
+		rate > *thresholds.MaxSkipRate {
+		return fmt.Errorf("%w: %d of %d items (%.1f%%) were skipped, max %.1f%%",
+			ErrThresholdExceeded, stats.Items-stats.Extracted, stats.Items, rate,
+			*thresholds.MaxSkipRate)
+	}
+	return nil
+}
+
+// NotifyThresholdExceeded sends an alert using every notifier if the run failed since its
+// fetcher exceeded a threshold. It does nothing for other runs.
+func NotifyThresholdExceeded(ctx context.Context, notifiers []Notifier,
+	report CrawlReport) error {
+	if !errors.Is(report.Err, ErrThresholdExceeded) {
+		return nil
+	}
+	stats := report.FetchStats
+	alert := Alert{
+		StationId:  report.StationId,
+		Status:     AlertFailing,
+		RunId:      report.RunId,
+		FetchStats: &stats,
+		Time:       report.End,
+		Message: fmt.Sprintf("Station `%s` is failing: %s (run %s).", report.StationId,
+			report.Err, report.RunId),
+	}
+	_, err := notifyAll(ctx, notifiers, alert)
+	return err
+}
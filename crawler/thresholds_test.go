@@ -0,0 +1,170 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"github.com/RadioCheckerApp/api/model"
+	"github.com/RadioCheckerApp/crawlers/fetcher"
+	"strings"
+	"testing"
+)
+
+// MockFetcherStats returns the TrackRecords of `batches` and reports `stats` for every batch.
+type MockFetcherStats struct {
+	MockFetcher
+	stats fetcher.Stats
+}
+
+func (mock *MockFetcherStats) NextContext(ctx context.Context) ([]*model.TrackRecord, error) {
+	fetcher.ReportStats(ctx, "mock", mock.stats)
+	return mock.Next()
+}
+
+// rate returns a pointer to `percentage`, for use as threshold.
+func rate(percentage float64) *float64 {
+	return &percentage
+}
+
+func TestThresholds_Check(t *testing.T) {
+	var tests = []struct {
+		thresholds  Thresholds
+		stats       fetcher.Stats
+		expectedErr bool
+	}{
+		{Thresholds{}, fetcher.Stats{}, false},
+		{Thresholds{}, fetcher.Stats{Items: 4, Extracted: 2, Failed: 2}, false},
+		{Thresholds{}, fetcher.Stats{Items: 4, Extracted: 1, Failed: 3}, true},
+		{Thresholds{MaxFailureRate: rate(20)}, fetcher.Stats{Items: 4, Extracted: 3, Failed: 1},
+			true},
+		{Thresholds{MaxFailureRate: rate(100)}, fetcher.Stats{Items: 4, Failed: 4}, false},
+		{Thresholds{}, fetcher.Stats{Items: 4, Extracted: 1, Skipped: 3}, false},
+		{Thresholds{MaxSkipRate: rate(50)}, fetcher.Stats{Items: 4, Extracted: 1, Skipped: 3},
+			true},
+		{Thresholds{MaxSkipRate: rate(50)}, fetcher.Stats{Items: 4, Extracted: 2, Failed: 1,
+			Skipped: 1}, false},
+		// 0 is the strictest threshold, not the default
+		{Thresholds{MaxFailureRate: rate(0)}, fetcher.Stats{Items: 4, Extracted: 3, Failed: 1},
+			true},
+		{Thresholds{MaxFailureRate: rate(0)}, fetcher.Stats{Items: 4, Extracted: 4}, false},
+		{Thresholds{MaxSkipRate: rate(0)}, fetcher.Stats{Items: 4, Extracted: 3, Skipped: 1},
+			true},
+	}
+
+	for _, test := range tests {
+		err := test.thresholds.check(test.stats)
+		if (err != nil) != test.expectedErr || (err != nil && !errors.Is(err,
+			ErrThresholdExceeded)) {
+			t.Errorf("%+v check(%+v): got error (%v), expected error: %v", test.thresholds,
+				test.stats, err, test.expectedErr)
+		}
+	}
+}
+
+func TestCrawler_Crawl_Thresholds(t *testing.T) {
+	newCrawler := func(stats fetcher.Stats) Crawler {
+		return Crawler{
+			stationId: "station-a",
+			fetcher: &MockFetcherStats{MockFetcher{batches: [][]*model.TrackRecord{
+				trackRecordBatch0}}, stats},
+			homeBase:                   MockHomeBaseSuccess{},
+			latestTrackRecordTimestamp: 1535301200,
+		}
+	}
+
+	stats := fetcher.Stats{Items: 10, Extracted: 3, Failed: 7}
+	report := newCrawler(stats).Crawl()
+	if !errors.Is(report.Err, ErrThresholdExceeded) {
+		t.Errorf("Crawler Crawl: got error (%v), expected (%v)", report.Err, ErrThresholdExceeded)
+	}
+	// the extracted TrackRecords are persisted nonetheless
+	if report.FetchStats != stats || report.RecordsPersisted != 2 {
+		t.Errorf("Crawler Crawl: got report `%+v`", report)
+	}
+
+	report = newCrawler(stats).WithThresholds(Thresholds{MaxFailureRate: rate(80)}).Crawl()
+	if report.Err != nil || report.FetchStats != stats {
+		t.Errorf("Crawler WithThresholds: got error (%v) and stats %+v", report.Err,
+			report.FetchStats)
+	}
+}
+
+func TestNotifyThresholdExceeded(t *testing.T) {
+	notifier := &MockNotifier{}
+	notifiers := []Notifier{notifier}
+	stats := fetcher.Stats{Items: 10, Extracted: 3, Failed: 7}
+	report := CrawlReport{StationId: "station-a", RunId: "0123456789abcdef", FetchStats: stats,
+		Err: Thresholds{}.check(stats)}
+
+	for _, other := range []CrawlReport{{StationId: "station-a"},
+		{StationId: "station-a", Err: ErrServer}} {
+		if err := NotifyThresholdExceeded(context.Background(), notifiers, other); err != nil {
+			t.Errorf("NotifyThresholdExceeded: unexpected error: %s", err)
+		}
+	}
+	if err := NotifyThresholdExceeded(context.Background(), notifiers, report); err != nil {
+		t.Errorf("NotifyThresholdExceeded: unexpected error: %s", err)
+	}
+
+	if len(notifier.alerts) != 1 {
+		t.Fatalf("NotifyThresholdExceeded: got %d alerts, expected 1", len(notifier.alerts))
+	}
+	alert := notifier.alerts[0]
+	if alert.Status != AlertFailing || alert.RunId != report.RunId ||
+		*alert.FetchStats != stats || !strings.Contains(alert.Message,
+		"7 of 10 items (70.0%) failed to parse") {
+		t.Errorf("NotifyThresholdExceeded: got alert %+v", alert)
+	}
+
+	notifier.fail = true
+	if err := NotifyThresholdExceeded(context.Background(), notifiers, report); err == nil {
+		t.Errorf("NotifyThresholdExceeded: expected error of the notifier")
+	}
+}
+
+// MockFetcherFailing returns pages of unparsable items and never reaches the latest TrackRecord.
+// It fails after `limit` calls, so that a crawler that does not stop is detected.
+type MockFetcherFailing struct {
+	calls int
+	limit int
+}
+
+func (mock *MockFetcherFailing) Next() ([]*model.TrackRecord, error) {
+	return mock.NextContext(context.Background())
+}
+
+func (mock *MockFetcherFailing) NextContext(ctx context.Context) ([]*model.TrackRecord, error) {
+	mock.calls++
+	if mock.calls > mock.limit {
+		return nil, errors.New("crawler did not stop")
+	}
+	fetcher.ReportStats(ctx, "mock", fetcher.Stats{Items: 20, Failed: 20})
+	return nil, nil
+}
+
+func TestCrawler_CrawlContext_FailingFetcher(t *testing.T) {
+	var tests = []struct {
+		thresholds    Thresholds
+		expectedErr   error
+		expectedCalls int
+	}{
+		{Thresholds{}, ErrThresholdExceeded, 1},
+		{Thresholds{MaxFailureRate: rate(100)}, ErrNoProgress, maxEmptyPages},
+	}
+
+	for _, test := range tests {
+		mockFetcher := &MockFetcherFailing{limit: 100}
+		crawler := Crawler{
+			stationId:                  "station-a",
+			fetcher:                    mockFetcher,
+			homeBase:                   MockHomeBaseSuccess{},
+			latestTrackRecordTimestamp: 1535301200,
+		}.WithThresholds(test.thresholds)
+
+		report := crawler.CrawlContext(context.Background())
+		if !errors.Is(report.Err, test.expectedErr) || mockFetcher.calls != test.expectedCalls {
+			t.Errorf("%+v CrawlContext: got error (%v) after %d calls, expected (%v) after %d",
+				test.thresholds, report.Err, mockFetcher.calls, test.expectedErr,
+				test.expectedCalls)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package crawler
+
+import (
+	"github.com/RadioCheckerApp/api/model"
+	"github.com/RadioCheckerApp/crawlers/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// spanRecorder records the spans of all tests of the package, since the global tracer provider
+// can only be replaced once.
+var spanRecorder = newSpanRecorder()
+
+func newSpanRecorder() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return recorder
+}
+
+// recordedTrace returns the spans of the trace of the crawler run `runId`, in the order in which
+// they ended.
+func recordedTrace(runId string) []sdktrace.ReadOnlySpan {
+	spans := spanRecorder.Ended()
+	var crawlSpan sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		if span.Name() == "Crawl" && spanAttribute(span, tracing.RunKey) == runId {
+			crawlSpan = span
+		}
+	}
+	if crawlSpan == nil {
+		return nil
+	}
+	var trace []sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		if span.SpanContext().TraceID() == crawlSpan.SpanContext().TraceID() {
+			trace = append(trace, span)
+		}
+	}
+	return trace
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) string {
+	for _, attr := range span.Attributes() {
+		if attr.Key == key {
+			return attr.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestCrawler_Crawl_Tracing(t *testing.T) {
+	var traceParents []string
+	api, shutdown := newTestHomeBase(func(w http.ResponseWriter, r *http.Request) {
+		traceParents = append(traceParents, r.Header.Get("traceparent"))
+		w.Write([]byte(`{"success": true, "data": "track created"}`))
+	})
+	defer shutdown()
+	api.DisableBatch = true
+
+	crawler := Crawler{
+		stationId: "station-a",
+		fetcher: &MockFetcher{batches: [][]*model.TrackRecord{
+			trackRecordBatch0}},
+		homeBase:                   api,
+		latestTrackRecordTimestamp: 1535301200,
+	}
+	report := crawler.Crawl()
+	if report.Err != nil || report.RecordsPersisted != 2 {
+		t.Fatalf("Crawler Crawl: got report `%+v`", report)
+	}
+
+	spans := recordedTrace(report.RunId)
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	expectedNames := []string{"Fetcher.Next", "HomeBase PUT", "HomeBase PUT",
+		"Crawler.persistTrackRecords", "Crawl"}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Fatalf("Crawler Crawl: got spans %v, expected %v", names, expectedNames)
+	}
+
+	crawlSpan, fetchSpan, persistSpan := spans[4], spans[0], spans[3]
+	if spanAttribute(crawlSpan, tracing.StationKey) != "station-a" ||
+		spanAttribute(fetchSpan, tracing.PageKey) != "1" ||
+		spanAttribute(persistSpan, tracing.PageKey) != "1" {
+		t.Errorf("Crawler Crawl: missing station or page attributes")
+	}
+	var parents = []struct {
+		span   sdktrace.ReadOnlySpan
+		parent sdktrace.ReadOnlySpan
+	}{
+		{fetchSpan, crawlSpan},
+		{persistSpan, crawlSpan},
+		{spans[1], persistSpan},
+		{spans[2], persistSpan},
+	}
+	for _, test := range parents {
+		if test.span.Parent().SpanID() != test.parent.SpanContext().SpanID() {
+			t.Errorf("Crawler Crawl: span `%s` is not a child of `%s`", test.span.Name(),
+				test.parent.Name())
+		}
+	}
+	if spanAttribute(spans[1], "http.response.status_code") != "200" {
+		t.Errorf("Crawler Crawl: got homebase span attributes %v", spans[1].Attributes())
+	}
+
+	// the homebase receives the trace context of the requests
+	traceId := crawlSpan.SpanContext().TraceID().String()
+	for i, traceParent := range traceParents {
+		if !strings.Contains(traceParent, traceId) ||
+			!strings.Contains(traceParent, spans[i+1].SpanContext().SpanID().String()) {
+			t.Errorf("Crawler Crawl: got traceparent `%s`, expected trace `%s`", traceParent,
+				traceId)
+		}
+	}
+	if len(traceParents) != 2 {
+		t.Errorf("Crawler Crawl: got %d requests, expected 2", len(traceParents))
+	}
+}
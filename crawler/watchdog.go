@@ -0,0 +1,298 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/RadioCheckerApp/crawlers/fetcher"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultMaxAge is used by the Watchdog for stations that do not configure `max_age`.
+const DefaultMaxAge = 2 * time.Hour
+
+// Alert statuses.
+const (
+	// AlertStale is sent once the latest TrackRecord of a station is older than its max age.
+	AlertStale = "stale"
+	// AlertRecovered is sent once a stale station has a recent TrackRecord again.
+	AlertRecovered = "recovered"
+	// AlertFailing is sent if a crawler run failed since its fetcher exceeded a threshold, see
+	// NotifyThresholdExceeded.
+	AlertFailing = "failing"
+)
+
+// Alert is sent by the Watchdog if a station becomes stale or recovers, and if a crawler run
+// exceeds its thresholds.
+type Alert struct {
+	StationId string `json:"stationId"`
+	Status    string `json:"status"`
+	// RunId and FetchStats describe the failed run of `failing` alerts.
+	RunId      string         `json:"runId,omitempty"`
+	FetchStats *fetcher.Stats `json:"fetchStats,omitempty"`
+	// LatestTimestamp is the airtime of the latest persisted TrackRecord, 0 if there is none.
+	LatestTimestamp int64 `json:"latestTimestamp"`
+	// AgeSeconds is the time between the latest TrackRecord and the check.
+	AgeSeconds    int64 `json:"ageSeconds"`
+	MaxAgeSeconds int64 `json:"maxAgeSeconds"`
+	// StaleSince is the time of the check that found the station to be stale.
+	StaleSince time.Time `json:"staleSince"`
+	Time       time.Time `json:"time"`
+	// Message is a human readable description of the alert.
+	Message string `json:"message"`
+}
+
+// Watchdog alerts if the latest TrackRecord persisted for a station is older than the station's
+// max age, e.g. because its source changed its format and every item is skipped.
+//
+// Alerts are deduplicated: a stale station is reported once (or every RepeatInterval) until it
+// recovers, which is reported as well. A Watchdog must not be copied after its first check.
+type Watchdog struct {
+	HomeBase  HomeBase
+	Stations  []StationConfig
+	Notifiers []Notifier
+	// RepeatInterval is the time after which a station that is still stale is reported again.
+	// Stale stations are reported only once if it is 0.
+	RepeatInterval time.Duration
+	// StatePath is the file storing the stale stations, so that alerts are not repeated after
+	// a restart, e.g. if the watchdog runs as cron job. The state is kept in memory if it is
+	// empty.
+	StatePath string
+
+	mu     sync.Mutex
+	states map[string]watchdogState
+	// now is used in tests.
+	now func() time.Time
+}
+
+// watchdogState is the state of a station that is stale or whose recovery has not been
+// reported yet.
+type watchdogState struct {
+	StaleSince time.Time `json:"staleSince"`
+	// NotifiedAt is the time of the last alert delivered by at least one notifier.
+	NotifiedAt time.Time `json:"notifiedAt,omitempty"`
+}
+
+// MaxAgeThreshold returns the station's `max_age`, or DefaultMaxAge if it is not set.
+func (station StationConfig) MaxAgeThreshold() time.Duration {
+	maxAge, err := time.ParseDuration(station.MaxAge)
+	if err != nil || maxAge <= 0 {
+		return DefaultMaxAge
+	}
+	return maxAge
+}
+
+// Check requests the latest TrackRecord of every station from the homebase and sends the
+// resulting alerts. It returns the alerts that were due, even if a notifier failed, and the
+// errors of the homebase and the notifiers. Stations whose latest TrackRecord cannot be
+// requested are skipped.
+func (watchdog *Watchdog) Check(ctx context.Context) ([]Alert, error) {
+	watchdog.mu.Lock()
+	defer watchdog.mu.Unlock()
+	if err := watchdog.loadState(); err != nil {
+		return nil, err
+	}
+
+	var alerts []Alert
+	var errs []error
+	for _, station := range watchdog.Stations {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+		var latestTimestamp int64
+		latest, err := watchdog.HomeBase.LatestTrackRecord(ctx, station.ID)
+		if err != nil && !errors.Is(err, ErrNoData) {
+			slog.Error("Unable to check station.", "station", station.ID, "err", err)
+			errs = append(errs, fmt.Errorf("station `%s`: %w", station.ID, err))
+			continue
+		} else if err == nil {
+			latestTimestamp = latest.Timestamp
+		}
+
+		alert, due := watchdog.evaluate(station, latestTimestamp)
+		watchdogStale.WithLabelValues(station.ID).Set(boolToFloat(alert.Status == AlertStale))
+		if !due {
+			continue
+		}
+		alerts = append(alerts, alert)
+		if alert.Status == AlertStale {
+			slog.Warn(alert.Message, "station", station.ID, "latest", latestTimestamp)
+		} else {
+			slog.Info(alert.Message, "station", station.ID, "latest", latestTimestamp)
+		}
+
+		delivered, err := notifyAll(ctx, watchdog.Notifiers, alert)
+		if err != nil {
+			slog.Error("Unable to send alert.", "station", station.ID, "err", err)
+			errs = append(errs, fmt.Errorf("station `%s`: %w", station.ID, err))
+		}
+		if delivered {
+			watchdog.delivered(alert)
+		}
+	}
+
+	if err := watchdog.saveState(); err != nil {
+		errs = append(errs, err)
+	}
+	return alerts, errors.Join(errs...)
+}
+
+// Run checks the stations every `interval` until `ctx` is done. The first check is run
+// immediately.
+func (watchdog *Watchdog) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// errors are logged by Check, the next check is attempted regardless
+		watchdog.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// evaluate updates the state of the station and returns its alert. The alert is due if it has
+// to be sent, i.e. the station became stale, recovered or is still stale and RepeatInterval has
+// passed since the last alert.
+func (watchdog *Watchdog) evaluate(station StationConfig, latestTimestamp int64) (Alert,
+	bool) {
+	now := watchdog.currentTime()
+	maxAge := station.MaxAgeThreshold()
+	alert := Alert{
+		StationId:       station.ID,
+		LatestTimestamp: latestTimestamp,
+		MaxAgeSeconds:   int64(maxAge.Seconds()),
+		Time:            now,
+	}
+	age := maxAge + 1
+	if latestTimestamp > 0 {
+		age = now.Sub(time.Unix(latestTimestamp, 0))
+		alert.AgeSeconds = int64(age.Seconds())
+	}
+
+	state, known := watchdog.states[station.ID]
+	if age <= maxAge {
+		if !known {
+			return alert, false
+		}
+		if state.NotifiedAt.IsZero() {
+			// the station has not been reported as stale, hence its recovery is not either
+			delete(watchdog.states, station.ID)
+			return alert, false
+		}
+		alert.Status = AlertRecovered
+		alert.StaleSince = state.StaleSince
+		alert.Message = recoveredMessage(alert, age)
+		return alert, true
+	}
+
+	if !known {
+		state = watchdogState{StaleSince: now}
+		watchdog.states[station.ID] = state
+	}
+	alert.Status = AlertStale
+	alert.StaleSince = state.StaleSince
+	alert.Message = staleMessage(alert, age, maxAge)
+	due := state.NotifiedAt.IsZero() ||
+		(watchdog.RepeatInterval > 0 && now.Sub(state.NotifiedAt) >= watchdog.RepeatInterval)
+	return alert, due
+}
+
+// delivered records that the alert was sent.
+func (watchdog *Watchdog) delivered(alert Alert) {
+	if alert.Status == AlertRecovered {
+		delete(watchdog.states, alert.StationId)
+		return
+	}
+	state := watchdog.states[alert.StationId]
+	state.NotifiedAt = alert.Time
+	watchdog.states[alert.StationId] = state
+}
+
+func staleMessage(alert Alert, age, maxAge time.Duration) string {
+	if alert.LatestTimestamp == 0 {
+		return fmt.Sprintf("Station `%s` is stale: no TrackRecords have been persisted.",
+			alert.StationId)
+	}
+	return fmt.Sprintf("Station `%s` is stale: the latest TrackRecord aired at %s, %s ago "+
+		"(max age %s).", alert.StationId, formatAirtime(alert.LatestTimestamp),
+		age.Truncate(time.Minute), maxAge)
+}
+
+func recoveredMessage(alert Alert, age time.Duration) string {
+	return fmt.Sprintf("Station `%s` recovered: the latest TrackRecord aired at %s, %s ago.",
+		alert.StationId, formatAirtime(alert.LatestTimestamp), age.Truncate(time.Minute))
+}
+
+func formatAirtime(timestamp int64) string {
+	return time.Unix(timestamp, 0).In(fetcher.Vienna).Format("2006-01-02 15:04 MST")
+}
+
+func (watchdog *Watchdog) currentTime() time.Time {
+	if watchdog.now != nil {
+		return watchdog.now()
+	}
+	return time.Now()
+}
+
+// loadState reads the state file on the first check.
+func (watchdog *Watchdog) loadState() error {
+	if watchdog.states != nil {
+		return nil
+	}
+	watchdog.states = make(map[string]watchdogState)
+	if watchdog.StatePath == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(watchdog.StatePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &watchdog.states); err != nil {
+		return fmt.Errorf("unable to decode watchdog state `%s`: %s", watchdog.StatePath, err)
+	}
+	return nil
+}
+
+// saveState atomically replaces the state file, if any.
+func (watchdog *Watchdog) saveState() error {
+	if watchdog.StatePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(watchdog.states, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(watchdog.StatePath),
+		filepath.Base(watchdog.StatePath)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), watchdog.StatePath)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,242 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"github.com/RadioCheckerApp/api/model"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// MockHomeBaseLatest returns the timestamps of `latest` as latest TrackRecords. Stations without
+// timestamp have no TrackRecords, the station `error` cannot be requested.
+type MockHomeBaseLatest struct {
+	latest map[string]int64
+}
+
+func (api MockHomeBaseLatest) LatestTrackRecord(ctx context.Context,
+	stationId string) (*model.TrackRecord, error) {
+	if stationId == "error" {
+		return nil, ErrServer
+	}
+	timestamp, ok := api.latest[stationId]
+	if !ok {
+		return nil, ErrNoData
+	}
+	return &model.TrackRecord{stationId, timestamp, "track", model.Track{"rhcp", "otherside"}},
+		nil
+}
+
+func (api MockHomeBaseLatest) PersistTrackRecord(ctx context.Context,
+	trackRecord *model.TrackRecord) error {
+	return nil
+}
+
+type MockNotifier struct {
+	alerts []Alert
+	fail   bool
+}
+
+func (notifier *MockNotifier) Notify(ctx context.Context, alert Alert) error {
+	notifier.alerts = append(notifier.alerts, alert)
+	if notifier.fail {
+		return errors.New("just a test")
+	}
+	return nil
+}
+
+var watchdogStart = time.Date(2018, 8, 26, 20, 0, 0, 0, loc)
+
+// newTestWatchdog returns a watchdog whose time is controlled by the returned function, which
+// sets the current time to `watchdogStart` plus the provided offset.
+func newTestWatchdog(homeBase HomeBase, notifiers ...Notifier) (*Watchdog, func(time.Duration)) {
+	now := watchdogStart
+	watchdog := &Watchdog{
+		HomeBase: homeBase,
+		Stations: []StationConfig{
+			{ID: "station-a", Fetcher: "kronehit", MaxAge: "1h"},
+			{ID: "station-b", Fetcher: "kronehit"},
+		},
+		Notifiers: notifiers,
+		now:       func() time.Time { return now },
+	}
+	return watchdog, func(offset time.Duration) { now = watchdogStart.Add(offset) }
+}
+
+func alertSummary(alerts []Alert) []string {
+	var summary []string
+	for _, alert := range alerts {
+		summary = append(summary, alert.StationId+" "+alert.Status)
+	}
+	return summary
+}
+
+func TestWatchdog_Check(t *testing.T) {
+	homeBase := MockHomeBaseLatest{map[string]int64{
+		"station-a": watchdogStart.Add(-30 * time.Minute).Unix(),
+		"station-b": watchdogStart.Add(-3 * time.Hour).Unix(),
+	}}
+	notifier := &MockNotifier{}
+	watchdog, setTime := newTestWatchdog(homeBase, notifier)
+
+	var tests = []struct {
+		offset   time.Duration
+		update   string
+		expected []string
+	}{
+		{0, "", []string{"station-b stale"}},
+		{10 * time.Minute, "", nil},
+		{40 * time.Minute, "", []string{"station-a stale"}},
+		{45 * time.Minute, "station-b", []string{"station-b recovered"}},
+		{50 * time.Minute, "station-a", []string{"station-a recovered"}},
+		{55 * time.Minute, "", nil},
+	}
+
+	for _, test := range tests {
+		setTime(test.offset)
+		if test.update != "" {
+			homeBase.latest[test.update] = watchdogStart.Add(test.offset).Unix()
+		}
+		alerts, err := watchdog.Check(context.Background())
+		if err != nil {
+			t.Errorf("Check (+%s): unexpected error: %s", test.offset, err)
+		}
+		if summary := alertSummary(alerts); !reflect.DeepEqual(summary, test.expected) {
+			t.Errorf("Check (+%s): got alerts %v, expected %v", test.offset, summary,
+				test.expected)
+		}
+	}
+
+	if len(notifier.alerts) != 4 {
+		t.Fatalf("Check: notifier got %d alerts, expected 4", len(notifier.alerts))
+	}
+	stale := notifier.alerts[0]
+	if stale.LatestTimestamp != watchdogStart.Add(-3*time.Hour).Unix() ||
+		stale.AgeSeconds != 3*60*60 ||
+		stale.MaxAgeSeconds != 2*60*60 || !stale.StaleSince.Equal(watchdogStart) ||
+		stale.Message != "Station `station-b` is stale: the latest TrackRecord aired at "+
+			"2018-08-26 17:00 CEST, 3h0m0s ago (max age 2h0m0s)." {
+		t.Errorf("Check: got stale alert %+v", stale)
+	}
+	recovered := notifier.alerts[2]
+	if !recovered.StaleSince.Equal(watchdogStart) ||
+		!strings.HasPrefix(recovered.Message, "Station `station-b` recovered") {
+		t.Errorf("Check: got recovery alert %+v", recovered)
+	}
+}
+
+func TestWatchdog_Check_Repeat(t *testing.T) {
+	homeBase := MockHomeBaseLatest{map[string]int64{"station-a": watchdogStart.Unix()}}
+	watchdog, setTime := newTestWatchdog(homeBase, &MockNotifier{})
+	watchdog.RepeatInterval = time.Hour
+
+	var tests = []struct {
+		offset   time.Duration
+		expected []string
+	}{
+		{0, []string{"station-b stale"}},
+		{59 * time.Minute, nil},
+		{time.Hour, []string{"station-b stale"}},
+		{90 * time.Minute, []string{"station-a stale"}},
+		{2 * time.Hour, []string{"station-b stale"}},
+	}
+
+	for _, test := range tests {
+		setTime(test.offset)
+		alerts, _ := watchdog.Check(context.Background())
+		if summary := alertSummary(alerts); !reflect.DeepEqual(summary, test.expected) {
+			t.Errorf("Check (+%s): got alerts %v, expected %v", test.offset, summary,
+				test.expected)
+		}
+	}
+	if alerts, _ := watchdog.Check(context.Background()); len(alerts) != 0 ||
+		watchdog.states["station-b"].StaleSince != watchdogStart {
+		t.Errorf("Check: got alerts %v and state %+v", alertSummary(alerts),
+			watchdog.states["station-b"])
+	}
+}
+
+func TestWatchdog_Check_NotifierFailure(t *testing.T) {
+	homeBase := MockHomeBaseLatest{map[string]int64{"station-a": watchdogStart.Unix()}}
+	failing := &MockNotifier{fail: true}
+	watchdog, setTime := newTestWatchdog(homeBase, failing)
+
+	// undelivered alerts are sent again on the next check
+	for i := 0; i < 2; i++ {
+		alerts, err := watchdog.Check(context.Background())
+		if err == nil || len(alerts) != 1 {
+			t.Errorf("Check #%d: got (%v, %v), expected an alert and an error", i+1,
+				alertSummary(alerts), err)
+		}
+	}
+
+	// a single working notifier suffices
+	working := &MockNotifier{}
+	watchdog.Notifiers = append(watchdog.Notifiers, working)
+	watchdog.Check(context.Background())
+	setTime(time.Minute)
+	if alerts, _ := watchdog.Check(context.Background()); len(alerts) != 0 ||
+		len(working.alerts) != 1 || len(failing.alerts) != 3 {
+		t.Errorf("Check: got alerts %v, %d working and %d failing notifications",
+			alertSummary(alerts), len(working.alerts), len(failing.alerts))
+	}
+
+	// the recovery of a station that has never been reported is not reported either
+	watchdog.Notifiers = []Notifier{failing}
+	delete(homeBase.latest, "station-a")
+	watchdog.Check(context.Background())
+	homeBase.latest["station-a"] = watchdogStart.Unix()
+	if alerts, _ := watchdog.Check(context.Background()); len(alerts) != 0 {
+		t.Errorf("Check: got alerts %v for the recovery of an unreported station",
+			alertSummary(alerts))
+	}
+}
+
+func TestWatchdog_Check_Errors(t *testing.T) {
+	notifier := &MockNotifier{}
+	watchdog, _ := newTestWatchdog(MockHomeBaseLatest{map[string]int64{}}, notifier)
+	watchdog.Stations = append([]StationConfig{{ID: "error", Fetcher: "kronehit"}},
+		watchdog.Stations...)
+
+	alerts, err := watchdog.Check(context.Background())
+	if !errors.Is(err, ErrServer) {
+		t.Errorf("Check: got error (%v), expected (%v)", err, ErrServer)
+	}
+	expected := []string{"station-a stale", "station-b stale"}
+	if summary := alertSummary(alerts); !reflect.DeepEqual(summary, expected) {
+		t.Errorf("Check: got alerts %v, expected %v", summary, expected)
+	}
+	if alerts[0].Message != "Station `station-a` is stale: no TrackRecords have been "+
+		"persisted." {
+		t.Errorf("Check: got message `%s`", alerts[0].Message)
+	}
+}
+
+func TestWatchdog_Check_StatePath(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "watchdog.json")
+	homeBase := MockHomeBaseLatest{map[string]int64{"station-a": watchdogStart.Unix()}}
+
+	watchdog, _ := newTestWatchdog(homeBase, &MockNotifier{})
+	watchdog.StatePath = statePath
+	if alerts, err := watchdog.Check(context.Background()); err != nil || len(alerts) != 1 {
+		t.Fatalf("Check: got (%v, %v), expected a single alert", alertSummary(alerts), err)
+	}
+
+	// a restarted watchdog does not repeat the alert, but reports the recovery
+	restarted, setTime := newTestWatchdog(homeBase, &MockNotifier{})
+	restarted.StatePath = statePath
+	setTime(time.Hour)
+	if alerts, err := restarted.Check(context.Background()); err != nil || len(alerts) != 0 {
+		t.Errorf("Check after restart: got (%v, %v), expected no alerts",
+			alertSummary(alerts), err)
+	}
+	homeBase.latest["station-b"] = watchdogStart.Add(time.Hour).Unix()
+	alerts, err := restarted.Check(context.Background())
+	if err != nil || !reflect.DeepEqual(alertSummary(alerts), []string{"station-b recovered"}) {
+		t.Errorf("Check after restart: got (%v, %v), expected recovery of station-b",
+			alertSummary(alerts), err)
+	}
+}
@@ -0,0 +1,144 @@
+// Command functions generates the Serverless functions of the stations configured in
+// stations.yml, so that adding a station only requires a new configuration entry.
+//
+// Usage:
+//
+//	functions [-config stations.yml] [-memory-size 1024] [-timeout 20] > functions.yml
+//
+// Every station with a schedule is crawled by a function `crawler-<id>` that runs the `station`
+// handler with STATION_ID set to the station's id. The `lambda` section of a station may override
+// the memory size and timeout of its function. The schedule of the station is converted to a
+// CloudWatch schedule expression, stations without a schedule are left out.
+//
+// CloudWatch evaluates cron expressions in UTC, while `crawlers daemon` uses the local time zone,
+// hence cron expressions may only restrict the minute: schedules restricting the hour, the day or
+// the month (including @daily) are rejected instead of being run at different times of day.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/RadioCheckerApp/crawlers/crawler"
+	"gopkg.in/yaml.v2"
+	"os"
+	"strings"
+	"time"
+)
+
+// function is a function of serverless.yml.
+type function struct {
+	Handler     string            `yaml:"handler"`
+	Description string            `yaml:"description"`
+	Environment map[string]string `yaml:"environment"`
+	MemorySize  int               `yaml:"memorySize"`
+	Timeout     int               `yaml:"timeout"`
+	Events      []event           `yaml:"events"`
+}
+
+type event struct {
+	Schedule schedule `yaml:"schedule"`
+}
+
+type schedule struct {
+	Rate    string `yaml:"rate"`
+	Enabled bool   `yaml:"enabled"`
+}
+
+func main() {
+	configPath := flag.String("config", "stations.yml", "path of the station configuration")
+	handler := flag.String("handler", "bin/crawlers-aws/station", "handler of the functions")
+	memorySize := flag.Int("memory-size", 1024, "memory of every function in MB")
+	timeout := flag.Duration("timeout", 20*time.Second, "timeout of every function")
+	flag.Parse()
+
+	config, err := crawler.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "functions: %s\n", err)
+		os.Exit(1)
+	}
+	functions, err := generateFunctions(config, *handler, *memorySize, *timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "functions: %s\n", err)
+		os.Exit(1)
+	}
+	out, err := yaml.Marshal(functions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "functions: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("# generated from %s, do not edit\n%s", *configPath, out)
+}
+
+// generateFunctions returns the functions of the scheduled stations in the order of the
+// configuration. `memorySize` and `timeout` apply to stations without Lambda overrides.
+func generateFunctions(config crawler.Config, handler string, memorySize int,
+	timeout time.Duration) (yaml.MapSlice, error) {
+	var functions yaml.MapSlice
+	for _, station := range config.Stations {
+		if station.Schedule == "" {
+			fmt.Fprintf(os.Stderr, "functions: station `%s` has no schedule and is left out\n",
+				station.ID)
+			continue
+		}
+		rate, err := lambdaSchedule(station.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("station `%s`: %s", station.ID, err)
+		}
+		stationMemorySize, stationTimeout := memorySize, timeout
+		if station.Lambda.MemorySize != 0 {
+			stationMemorySize = station.Lambda.MemorySize
+		}
+		if station.Lambda.Timeout != "" {
+			stationTimeout, err = time.ParseDuration(station.Lambda.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("station `%s`: invalid lambda timeout: %s", station.ID, err)
+			}
+		}
+		functions = append(functions, yaml.MapItem{Key: "crawler-" + station.ID, Value: function{
+			Handler:     handler,
+			Description: fmt.Sprintf("Crawler for radio station `%s`", station.ID),
+			Environment: map[string]string{"STATION_ID": station.ID},
+			MemorySize:  stationMemorySize,
+			Timeout:     int(stationTimeout / time.Second),
+			Events:      []event{{schedule{Rate: rate, Enabled: true}}},
+		}})
+	}
+	if len(functions) == 0 {
+		return nil, errors.New("no stations to schedule")
+	}
+	return functions, nil
+}
+
+// lambdaSchedule converts the schedule of a station (see crawler.ParseSchedule) to a CloudWatch
+// schedule expression. Cron expressions restricting more than the minute are not converted,
+// since CloudWatch evaluates them in UTC rather than in the local time zone of the crawlers.
+func lambdaSchedule(spec string) (string, error) {
+	if _, err := crawler.ParseSchedule(spec); err != nil {
+		return "", err
+	}
+	spec = strings.TrimSpace(spec)
+	switch {
+	case strings.HasPrefix(spec, "rate("):
+		return spec, nil
+	case strings.HasPrefix(spec, "@every "):
+		interval, _ := time.ParseDuration(strings.TrimSpace(spec[len("@every "):]))
+		if interval%time.Minute != 0 {
+			return "", fmt.Errorf("schedule `%s` is not a multiple of a minute", spec)
+		}
+		minutes := int(interval / time.Minute)
+		if minutes == 1 {
+			return "rate(1 minute)", nil
+		}
+		return fmt.Sprintf("rate(%d minutes)", minutes), nil
+	case spec == "@hourly":
+		return "cron(0 * * * ? *)", nil
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 || strings.Trim(strings.Join(fields[1:], ""), "*") != "" {
+		return "", fmt.Errorf("schedule `%s` depends on the time zone, which is UTC for "+
+			"CloudWatch but local for the crawlers (use a rate or a cron expression that only "+
+			"restricts the minute)", spec)
+	}
+	return fmt.Sprintf("cron(%s * * * ? *)", fields[0]), nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"github.com/RadioCheckerApp/crawlers/crawler"
+	"testing"
+	"time"
+)
+
+func TestLambdaSchedule(t *testing.T) {
+	var tests = []struct {
+		spec             string
+		expectedSchedule string
+		expectedErr      bool
+	}{
+		{"rate(1 hour)", "rate(1 hour)", false},
+		{" rate(15 minutes) ", "rate(15 minutes)", false},
+		{"@every 1m", "rate(1 minute)", false},
+		{"@every 1h30m", "rate(90 minutes)", false},
+		{"@every 90s", "", true},
+		{"@hourly", "cron(0 * * * ? *)", false},
+		{"*/15 * * * *", "cron(*/15 * * * ? *)", false},
+		{"@daily", "", true},
+		{"*/15 6-22 * * *", "", true},
+		{"0 * 1 * *", "", true},
+		{"0 * * * 1-5", "", true},
+		{"every hour", "", true},
+		{"", "", true},
+	}
+
+	for _, test := range tests {
+		schedule, err := lambdaSchedule(test.spec)
+		if schedule != test.expectedSchedule || (err != nil) != test.expectedErr {
+			t.Errorf("lambdaSchedule(%s): got (%s, %v), expected (%s, error: %v)", test.spec,
+				schedule, err, test.expectedSchedule, test.expectedErr)
+		}
+	}
+}
+
+func TestGenerateFunctions(t *testing.T) {
+	config := crawler.Config{Stations: []crawler.StationConfig{
+		{ID: "kronehit", Fetcher: "kronehit", Schedule: "rate(1 hour)"},
+		{ID: "manual", Fetcher: "kronehit"},
+		{ID: "hitradio-oe3", Fetcher: "hitradio-oe3", Schedule: "@every 30m",
+			Lambda: crawler.LambdaConfig{MemorySize: 512, Timeout: "10s"}},
+	}}
+
+	functions, err := generateFunctions(config, "bin/station", 1024, 20*time.Second)
+	if err != nil || len(functions) != 2 {
+		t.Fatalf("generateFunctions: got (%d functions, %v), expected 2 functions",
+			len(functions), err)
+	}
+	var tests = []struct {
+		expectedName       string
+		expectedStationId  string
+		expectedRate       string
+		expectedMemorySize int
+		expectedTimeout    int
+	}{
+		{"crawler-kronehit", "kronehit", "rate(1 hour)", 1024, 20},
+		{"crawler-hitradio-oe3", "hitradio-oe3", "rate(30 minutes)", 512, 10},
+	}
+	for i, test := range tests {
+		function := functions[i].Value.(function)
+		if functions[i].Key != test.expectedName ||
+			function.Environment["STATION_ID"] != test.expectedStationId ||
+			function.Events[0].Schedule.Rate != test.expectedRate ||
+			function.MemorySize != test.expectedMemorySize ||
+			function.Timeout != test.expectedTimeout || function.Handler != "bin/station" {
+			t.Errorf("generateFunctions: got %s %+v, expected %s for station %s with %s, "+
+				"%d MB and %ds", functions[i].Key, function, test.expectedName,
+				test.expectedStationId, test.expectedRate, test.expectedMemorySize,
+				test.expectedTimeout)
+		}
+	}
+
+	config.Stations = config.Stations[1:2]
+	if _, err := generateFunctions(config, "bin/station", 512, 10*time.Second); err == nil {
+		t.Errorf("generateFunctions: got no error without scheduled stations")
+	}
+	config.Stations[0].Schedule = "0 6 * * *"
+	if _, err := generateFunctions(config, "bin/station", 512, 10*time.Second); err == nil {
+		t.Errorf("generateFunctions: got no error for an unsupported schedule")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/RadioCheckerApp/crawlers/crawler"
+	_ "github.com/RadioCheckerApp/crawlers/crawler/drivers"
+	"github.com/RadioCheckerApp/crawlers/logging"
+	"github.com/RadioCheckerApp/crawlers/tracing"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// deadlineMargin is the time reserved at the end of an invocation to stop the crawler, log its
+// progress and push its metrics before Lambda terminates the function.
+const deadlineMargin = 1 * time.Second
+
+// tracerProvider exports the spans of the crawler if CRAWLER_TRACE_ENDPOINT is set, nil otherwise.
+var tracerProvider *sdktrace.TracerProvider
+
+func Handler(ctx context.Context, event events.CloudWatchEvent) (crawler.CrawlReport, error) {
+	configPath := os.Getenv("CRAWLER_CONFIG")
+	stationId := os.Getenv("STATION_ID")
+
+	logger := slog.Default().With("station", stationId)
+	if lambdaContext, ok := lambdacontext.FromContext(ctx); ok {
+		logger = logger.With("requestId", lambdaContext.AwsRequestID)
+	}
+	ctx = logging.NewContext(ctx, logger)
+	logger.Info("Crawler triggered.")
+	defer logger.Info("Crawler finished.")
+
+	config, err := crawler.LoadConfig(configPath)
+	if err != nil {
+		logger.Error("Unable to load config.", "path", configPath, "err", err)
+		return crawler.CrawlReport{}, err
+	}
+
+	station, ok := config.Station(stationId)
+	if !ok {
+		logger.Error("Station is not configured.", "path", configPath)
+		return crawler.CrawlReport{}, errors.New("station `" + stationId + "` is not configured")
+	}
+
+	// the API is configured like the crawlers command, its secrets are resolved while it is
+	// used, so rotated secrets are picked up without a redeployment
+	api, err := crawler.APIConfigFromEnv()
+	if err != nil {
+		logger.Error("Invalid RadioChecker API configuration.", "err", err)
+		return crawler.CrawlReport{}, err
+	}
+	homeBase, err := crawler.OpenHomeBase(config.Sink, api)
+	if err != nil {
+		logger.Error("Unable to open sink.", "err", err)
+		return crawler.CrawlReport{}, err
+	}
+	defer crawler.CloseHomeBase(ctx, homeBase)
+
+	// the crawler stops early, so that its metrics can still be pushed within the margin
+	crawlCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		crawlCtx, cancel = context.WithDeadline(ctx, deadline.Add(-deadlineMargin))
+		defer cancel()
+	}
+
+	stationCrawler, err := station.NewCrawler(crawlCtx, homeBase)
+	if err != nil {
+		logger.Error("Unable to create crawler.", "err", err)
+		return crawler.CrawlReport{}, err
+	}
+	// the outbox should be on persistent storage, e.g. EFS, since /tmp is lost with the instance
+	if outboxPath := os.Getenv("CRAWLER_OUTBOX"); outboxPath != "" {
+		stationCrawler = stationCrawler.WithOutbox(crawler.NewFileOutbox(outboxPath))
+	}
+
+	report := stationCrawler.CrawlContext(crawlCtx)
+	if notifiers, err := config.OpenNotifiers(ctx); err != nil {
+		logger.Error("Unable to configure notifiers.", "err", err)
+	} else if err := crawler.NotifyThresholdExceeded(ctx, notifiers, report); err != nil {
+		logger.Error("Unable to send alert.", "err", err)
+	}
+
+	// Lambda functions cannot be scraped, hence their metrics are pushed if a Pushgateway is set
+	if pushURL := os.Getenv("METRICS_PUSHGATEWAY_URL"); pushURL != "" {
+		if err := crawler.PushMetrics(ctx, pushURL, "crawlers-aws", stationId); err != nil {
+			logger.Error("Unable to push metrics.", "err", err)
+		}
+	}
+	// the function is frozen after the invocation, so the spans are exported right away
+	if tracerProvider != nil {
+		if err := tracerProvider.ForceFlush(ctx); err != nil {
+			logger.Error("Unable to export traces.", "err", err)
+		}
+	}
+	// other errors are only reported, but a fetcher that fails to parse its source will not
+	// recover until it is fixed, so the invocation is marked as failed
+	if errors.Is(report.Err, crawler.ErrThresholdExceeded) {
+		return report, report.Err
+	}
+	return report, nil
+}
+
+func main() {
+	// logs are written as JSON by default, which CloudWatch Logs Insights is able to query
+	format := os.Getenv("CRAWLER_LOG_FORMAT")
+	if format == "" {
+		format = logging.FormatJSON
+	}
+	logger, err := logging.New(os.Stdout, format, os.Getenv("CRAWLER_LOG_LEVEL"))
+	if err != nil {
+		slog.Error("Unable to configure logging, using the default logger.", "err", err)
+	} else {
+		slog.SetDefault(logger)
+	}
+	if endpoint := os.Getenv("CRAWLER_TRACE_ENDPOINT"); endpoint != "" {
+		tracerProvider, err = tracing.Setup(context.Background(), endpoint, "")
+		if err != nil {
+			slog.Error("Unable to configure tracing.", "err", err)
+		}
+	}
+	lambda.Start(Handler)
+}
@@ -0,0 +1,243 @@
+// Package fakehomebase implements an in-memory stand-in for the track endpoints of the
+// RadioChecker API. It is meant for integration tests (see net/http/httptest) and for running
+// the crawlers locally using the `fake-homebase` command.
+package fakehomebase
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/RadioCheckerApp/api/model"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server handles the following requests:
+//
+//	GET  /stations/{id}/tracks?filter=latest     latest TrackRecord of the station
+//	GET  /stations/{id}/tracks?from={ts}&to={ts} TrackRecords aired within [from, to]
+//	PUT  /stations/{id}/tracks/{ts}              persist a single Track
+//	POST /stations/{id}/tracks                   persist several TrackRecords
+//
+// GET requests have to provide the API key in the `X-API-KEY` header, all other requests the
+// authorization as bearer token. Responses use the API's `success`/`message`/`data` envelope.
+type Server struct {
+	// APIKey and Authorization are not checked if they are empty.
+	APIKey        string
+	Authorization string
+	// DisableBatch answers batch requests with `405 Method Not Allowed`.
+	DisableBatch bool
+	// FailureRate is the fraction of requests (0 to 1) that is answered with
+	// `503 Service Unavailable`.
+	FailureRate float64
+
+	mu       sync.Mutex
+	records  map[string]map[int64]model.TrackRecord
+	failures []int
+	requests int
+}
+
+func New(apiKey, authorization string) *Server {
+	return &Server{APIKey: apiKey, Authorization: authorization}
+}
+
+// FailNext answers the next `n` requests with `statusCode`.
+func (server *Server) FailNext(n int, statusCode int) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	for i := 0; i < n; i++ {
+		server.failures = append(server.failures, statusCode)
+	}
+}
+
+// Put stores a TrackRecord as if it had been persisted by a client.
+func (server *Server) Put(trackRecord model.TrackRecord) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.put(trackRecord)
+}
+
+// TrackRecords returns the stored TrackRecords of the station, ordered by their timestamp.
+func (server *Server) TrackRecords(stationId string) []*model.TrackRecord {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.trackRecords(stationId, 0, 1<<62)
+}
+
+// Requests returns the number of requests handled so far, including failed ones.
+func (server *Server) Requests() int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.requests
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.requests++
+
+	if len(server.failures) > 0 {
+		statusCode := server.failures[0]
+		server.failures = server.failures[1:]
+		writeError(w, statusCode, "injected failure")
+		return
+	}
+	if server.FailureRate > 0 && rand.Float64() < server.FailureRate {
+		writeError(w, http.StatusServiceUnavailable, "injected failure")
+		return
+	}
+
+	if !server.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// path: /stations/{id}/tracks[/{ts}]
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 3 || len(parts) > 4 || parts[0] != "stations" || parts[2] != "tracks" {
+		// unknown endpoints are answered by the router, without the API's envelope
+		http.NotFound(w, r)
+		return
+	}
+	stationId := parts[1]
+
+	switch {
+	case len(parts) == 3 && r.Method == http.MethodGet:
+		server.getTracks(w, r, stationId)
+	case len(parts) == 3 && r.Method == http.MethodPost && !server.DisableBatch:
+		server.postTracks(w, r, stationId)
+	case len(parts) == 4 && r.Method == http.MethodPut:
+		server.putTrack(w, r, stationId, parts[3])
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (server *Server) authorized(r *http.Request) bool {
+	if r.Method == http.MethodGet {
+		return server.APIKey == "" || r.Header.Get("X-API-KEY") == server.APIKey
+	}
+	return server.Authorization == "" ||
+		r.Header.Get("Authorization") == "Bearer "+server.Authorization
+}
+
+func (server *Server) getTracks(w http.ResponseWriter, r *http.Request, stationId string) {
+	query := r.URL.Query()
+	if query.Get("filter") == "latest" {
+		trackRecords := server.trackRecords(stationId, 0, 1<<62)
+		if len(trackRecords) == 0 {
+			writeError(w, http.StatusNotFound, "No tracks found for station "+stationId)
+			return
+		}
+		writeData(w, http.StatusOK, trackRecords[len(trackRecords)-1])
+		return
+	}
+
+	from, errFrom := strconv.ParseInt(query.Get("from"), 10, 64)
+	to, errTo := strconv.ParseInt(query.Get("to"), 10, 64)
+	if errFrom != nil || errTo != nil {
+		writeError(w, http.StatusBadRequest, "Invalid time range")
+		return
+	}
+	trackRecords := server.trackRecords(stationId, from, to)
+	if len(trackRecords) == 0 {
+		writeError(w, http.StatusNotFound, "No tracks found for station "+stationId)
+		return
+	}
+	writeData(w, http.StatusOK, trackRecords)
+}
+
+func (server *Server) putTrack(w http.ResponseWriter, r *http.Request, stationId,
+	timestamp string) {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid timestamp")
+		return
+	}
+	var track model.Track
+	if err := json.NewDecoder(r.Body).Decode(&track); err != nil || track.Title == "" {
+		writeError(w, http.StatusBadRequest, "Invalid track")
+		return
+	}
+
+	server.put(model.TrackRecord{StationId: stationId, Timestamp: ts, Type: "track",
+		Track: track})
+	writeData(w, http.StatusCreated, fmt.Sprintf("track created: /stations/%s/tracks/%d",
+		stationId, ts))
+}
+
+type batchResult struct {
+	Timestamp int64  `json:"timestamp"`
+	Success   bool   `json:"success"`
+	Message   string `json:"message,omitempty"`
+}
+
+func (server *Server) postTracks(w http.ResponseWriter, r *http.Request, stationId string) {
+	var trackRecords []model.TrackRecord
+	if err := json.NewDecoder(r.Body).Decode(&trackRecords); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid tracks")
+		return
+	}
+
+	results := make([]batchResult, len(trackRecords))
+	for i, trackRecord := range trackRecords {
+		results[i].Timestamp = trackRecord.Timestamp
+		switch {
+		case trackRecord.StationId != stationId:
+			results[i].Message = "track belongs to another station"
+		case trackRecord.Track.Title == "":
+			results[i].Message = "invalid track"
+		default:
+			if trackRecord.Type == "" {
+				trackRecord.Type = "track"
+			}
+			server.put(trackRecord)
+			results[i].Success = true
+		}
+	}
+	writeData(w, http.StatusOK, results)
+}
+
+func (server *Server) put(trackRecord model.TrackRecord) {
+	if server.records == nil {
+		server.records = make(map[string]map[int64]model.TrackRecord)
+	}
+	if server.records[trackRecord.StationId] == nil {
+		server.records[trackRecord.StationId] = make(map[int64]model.TrackRecord)
+	}
+	server.records[trackRecord.StationId][trackRecord.Timestamp] = trackRecord
+}
+
+func (server *Server) trackRecords(stationId string, from, to int64) []*model.TrackRecord {
+	var trackRecords []*model.TrackRecord
+	for timestamp, trackRecord := range server.records[stationId] {
+		if timestamp >= from && timestamp <= to {
+			trackRecord := trackRecord
+			trackRecords = append(trackRecords, &trackRecord)
+		}
+	}
+	sort.Slice(trackRecords, func(i, j int) bool {
+		return trackRecords[i].Timestamp < trackRecords[j].Timestamp
+	})
+	return trackRecords
+}
+
+func writeData(w http.ResponseWriter, statusCode int, data interface{}) {
+	writeJSON(w, statusCode, map[string]interface{}{"success": true, "data": data})
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, map[string]interface{}{"success": false, "message": message})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("Unable to write response.", "err", err)
+	}
+}
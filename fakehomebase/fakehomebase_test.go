@@ -0,0 +1,87 @@
+package fakehomebase
+
+import (
+	"github.com/RadioCheckerApp/api/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer_ServeHTTP(t *testing.T) {
+	server := New("key", "token")
+	server.Put(model.TrackRecord{"kronehit", 1535301120, "track", model.Track{"Simon Lewis",
+		"Hey Jessy"}})
+
+	var tests = []struct {
+		method             string
+		target             string
+		header             string
+		body               string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{"GET", "/stations/kronehit/tracks?filter=latest", "X-API-KEY: key", "", 200,
+			`"timestamp":1535301120`},
+		{"GET", "/stations/kronehit/tracks?filter=latest", "X-API-KEY: wrong", "", 401,
+			`"success":false`},
+		{"GET", "/stations/kronehit/tracks?filter=latest", "Authorization: Bearer token", "", 401,
+			`"message":"Unauthorized"`},
+		{"GET", "/stations/oe3/tracks?filter=latest", "X-API-KEY: key", "", 404,
+			`"success":false`},
+		{"GET", "/stations/kronehit/tracks?from=1535301000&to=1535301200", "X-API-KEY: key", "",
+			200, `"data":[{"stationId":"kronehit"`},
+		{"PUT", "/stations/kronehit/tracks/1535301300", "Authorization: Bearer token",
+			`{"artist": "Katy Perry", "title": "Last Friday Night"}`, 201,
+			`"data":"track created: /stations/kronehit/tracks/1535301300"`},
+		{"PUT", "/stations/kronehit/tracks/1535301300", "X-API-KEY: key",
+			`{"artist": "Katy Perry", "title": "Last Friday Night"}`, 401, `"success":false`},
+		{"PUT", "/stations/kronehit/tracks/now", "Authorization: Bearer token", `{}`, 400,
+			`"message":"Invalid timestamp"`},
+		{"POST", "/stations/kronehit/tracks", "Authorization: Bearer token",
+			`[{"stationId": "kronehit", "timestamp": 1535301540, "track": {"title": "River"}},
+			{"stationId": "oe3", "timestamp": 1535301541, "track": {"title": "River"}}]`, 200,
+			`{"timestamp":1535301541,"success":false`},
+		{"DELETE", "/stations/kronehit/tracks/1535301300", "Authorization: Bearer token", "",
+			405, `"success":false`},
+		{"GET", "/tracks", "X-API-KEY: key", "", 404, `404 page not found`},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+		header := strings.SplitN(test.header, ": ", 2)
+		r.Header.Set(header[0], header[1])
+		w := httptest.NewRecorder()
+
+		server.ServeHTTP(w, r)
+
+		if w.Code != test.expectedStatusCode || !strings.Contains(w.Body.String(),
+			test.expectedBody) {
+			t.Errorf("%s %s (%s): got (%d, %s), expected (%d, %s)", test.method, test.target,
+				test.header, w.Code, w.Body, test.expectedStatusCode, test.expectedBody)
+		}
+	}
+
+	if n := len(server.TrackRecords("kronehit")); n != 3 {
+		t.Errorf("TrackRecords: got %d TrackRecords, expected 3", n)
+	}
+}
+
+func TestServer_FailNext(t *testing.T) {
+	server := New("", "")
+	server.FailNext(2, http.StatusBadGateway)
+
+	var statusCodes []int
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/stations/a/tracks?filter=latest", nil))
+		statusCodes = append(statusCodes, w.Code)
+	}
+
+	if statusCodes[0] != 502 || statusCodes[1] != 502 || statusCodes[2] != 404 {
+		t.Errorf("FailNext: got status codes %v, expected [502 502 404]", statusCodes)
+	}
+	if server.Requests() != 3 {
+		t.Errorf("Requests: got %d, expected 3", server.Requests())
+	}
+}
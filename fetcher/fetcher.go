@@ -1,7 +1,68 @@
 package fetcher
 
-import "github.com/RadioCheckerApp/api/model"
+import (
+	"context"
+	"errors"
+	"github.com/RadioCheckerApp/api/model"
+	"go.opentelemetry.io/otel"
+	"log/slog"
+	"time"
+	_ "time/tzdata"
+)
+
+// tracer creates the spans of the requests sent by the fetchers, see package tracing.
+var tracer = otel.Tracer("github.com/RadioCheckerApp/crawlers/fetcher")
+
+// Vienna is the time zone of the stations and their sources. The time zone database is embedded
+// (see time/tzdata), so the fallback is never used in practice.
+var Vienna = func() *time.Location {
+	loc, err := time.LoadLocation("Europe/Vienna")
+	if err != nil {
+		slog.Error("Unable to load time zone, using CET instead.", "err", err)
+		return time.FixedZone("CET", 60*60)
+	}
+	return loc
+}()
+
+// ErrExhausted is returned by fetchers that have no older items to fetch, either because they
+// reached the oldest item their source provides or the time set with StopAt. Fetching again
+// would not make any progress. Use errors.Is to check for it.
+var ErrExhausted = errors.New("no older items available")
 
 type Fetcher interface {
 	Next() ([]*model.TrackRecord, error)
 }
+
+// ContextFetcher is implemented by fetchers whose requests can be cancelled. NextContext must
+// return as soon as possible once `ctx` is done.
+type ContextFetcher interface {
+	Fetcher
+	NextContext(ctx context.Context) ([]*model.TrackRecord, error)
+}
+
+// Seeker is implemented by fetchers that are able to start at an arbitrary point in the past,
+// which is required to backfill the history of a station.
+type Seeker interface {
+	// Seek positions the fetcher so that the following calls to Next return the TrackRecords
+	// aired before `t`, newest first.
+	Seek(t time.Time)
+}
+
+// Stopper is implemented by fetchers that are able to stop at a point in the past by themselves,
+// even if the items aired before it cannot be parsed.
+type Stopper interface {
+	// StopAt makes the fetcher return ErrExhausted once it reached the items aired before `t`.
+	StopAt(t time.Time)
+}
+
+// NextContext fetches the next batch of TrackRecords from `fetcher`. If the fetcher does not
+// implement ContextFetcher, `ctx` is only checked before Next is called.
+func NextContext(ctx context.Context, fetcher Fetcher) ([]*model.TrackRecord, error) {
+	if contextFetcher, ok := fetcher.(ContextFetcher); ok {
+		return contextFetcher.NextContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return fetcher.Next()
+}
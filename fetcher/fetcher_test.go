@@ -0,0 +1,30 @@
+package fetcher
+
+import (
+	"context"
+	"github.com/RadioCheckerApp/api/model"
+	"testing"
+)
+
+type MockFetcher struct {
+	calls int
+}
+
+func (fetcher *MockFetcher) Next() ([]*model.TrackRecord, error) {
+	fetcher.calls++
+	return nil, nil
+}
+
+func TestNextContext(t *testing.T) {
+	fetcher := &MockFetcher{}
+	if _, err := NextContext(context.Background(), fetcher); err != nil || fetcher.calls != 1 {
+		t.Errorf("NextContext: got (%v, %d calls), expected (nil, 1 call)", err, fetcher.calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NextContext(ctx, fetcher); err != context.Canceled || fetcher.calls != 1 {
+		t.Errorf("NextContext: got (%v, %d calls), expected (%v, 1 call)",
+			err, fetcher.calls, context.Canceled)
+	}
+}
@@ -1,13 +1,18 @@
 package fetcher
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/ChimeraCoder/anaconda"
 	"github.com/RadioCheckerApp/api/model"
-	"log"
+	"github.com/RadioCheckerApp/crawlers/logging"
+	"github.com/RadioCheckerApp/crawlers/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"net/url"
-	"strings"
+	"strconv"
+	"time"
 )
 
 const twitterUserID = "7901732"
@@ -15,6 +20,36 @@ const twitterTweetCount = 200
 const radioStationId = "hitradio-oe3"
 const trackType = "track"
 
+// DefaultAirtimeTolerance is the maximum divergence between the airtime stated in the text of a
+// tweet and the time the tweet was created, unless the station sets `airtime_tolerance`.
+const DefaultAirtimeTolerance = 30 * time.Minute
+
+// twitterEpoch is the start of Twitter's snowflake ID timestamps in milliseconds since the Unix
+// epoch.
+const twitterEpoch = 1288834974657
+
+func init() {
+	Register(radioStationId, func(options map[string]string) (Fetcher, error) {
+		fetcher, err := NewHitradioOE3Fetcher(
+			options["consumer_key"],
+			options["consumer_key_secret"],
+			options["oauth_access_token"],
+			options["oauth_access_token_secret"],
+		)
+		if err != nil {
+			return nil, err
+		}
+		if option := options["airtime_tolerance"]; option != "" {
+			tolerance, err := time.ParseDuration(option)
+			if err != nil || tolerance <= 0 {
+				return nil, fmt.Errorf("invalid airtime_tolerance `%s`", option)
+			}
+			fetcher.airtimeTolerance = tolerance
+		}
+		return fetcher, nil
+	})
+}
+
 type TwitterAPI interface {
 	GetUserTimeline(v url.Values) ([]anaconda.Tweet, error)
 }
@@ -22,6 +57,9 @@ type TwitterAPI interface {
 type HitradioOE3Fetcher struct {
 	twitterAPI       TwitterAPI
 	twitterAPIParams url.Values
+	// airtimeTolerance is the maximum divergence between the airtime in the text of a tweet and
+	// its creation time. DefaultAirtimeTolerance is used if it is 0.
+	airtimeTolerance time.Duration
 }
 
 func NewHitradioOE3Fetcher(consumerKey, consumerKeySecret, oauthAccessToken,
@@ -37,7 +75,7 @@ func NewHitradioOE3Fetcher(consumerKey, consumerKeySecret, oauthAccessToken,
 		return HitradioOE3Fetcher{}, errors.New("could not create Twitter API handler")
 	}
 
-	return HitradioOE3Fetcher{twitterAPI, buildInitialParams()}, nil
+	return HitradioOE3Fetcher{twitterAPI, buildInitialParams(), DefaultAirtimeTolerance}, nil
 }
 
 func buildInitialParams() url.Values {
@@ -50,13 +88,28 @@ func buildInitialParams() url.Values {
 }
 
 func (fetcher HitradioOE3Fetcher) Next() ([]*model.TrackRecord, error) {
-	tweets, err := fetcher.twitterAPI.GetUserTimeline(fetcher.twitterAPIParams)
+	return fetcher.NextContext(context.Background())
+}
+
+func (fetcher HitradioOE3Fetcher) NextContext(ctx context.Context) ([]*model.TrackRecord, error) {
+	tweets, err := fetcher.getUserTimeline(ctx)
+	recordRequest(radioStationId, err)
 	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("INFO:    Fetched %d tweets from Twitter account `%s`.", len(tweets), twitterUserID)
+	logger := logging.FromContext(ctx)
+	logger.Info("Fetched tweets.", "tweets", len(tweets), "account", twitterUserID)
+	if !containsOlderTweet(tweets, fetcher.twitterAPIParams.Get("max_id")) {
+		// the response of the last request is repeated at the end of the timeline, the end of
+		// the range set with StopAt or the limit of 3200 tweets of the Twitter API
+		logger.Info("Reached the oldest tweet available.",
+			"maxId", fetcher.twitterAPIParams.Get("max_id"),
+			"sinceId", fetcher.twitterAPIParams.Get("since_id"))
+		return nil, ErrExhausted
+	}
 
+	stats := Stats{Items: len(tweets)}
 	var trackRecords []*model.TrackRecord
 
 	for _, tweet := range tweets {
@@ -64,45 +117,119 @@ func (fetcher HitradioOE3Fetcher) Next() ([]*model.TrackRecord, error) {
 			// Requests to the Twitter API that contain the `max_id` param are inclusive,
 			// meaning that the tweet with the respective ID is (again) included in the response.
 			// To avoid duplicates, the first (matching) tweet of the response has to be skipped.
-			log.Printf("INFO:    Skipped tweet with ID `%s` (created_at: %s).",
-				tweet.IdStr, tweet.CreatedAt)
+			logger.Debug("Skipped tweet.", "id", tweet.IdStr, "createdAt", tweet.CreatedAt)
+			stats.Skipped++
 			continue
 		}
-		trackRecord, err := extractTrackRecordFromTweet(tweet)
+		// the next request continues after this tweet even if it cannot be parsed, otherwise
+		// a page of unparsable tweets would be requested over and over
+		fetcher.twitterAPIParams.Set("max_id", tweet.IdStr)
+		trackRecord, err := extractTrackRecordFromTweet(tweet, fetcher.airtimeTolerance)
 		if err != nil {
-			log.Printf("ERROR:   Unable to extract TrackRecord from tweet: `%s`. Message: `%s`.",
-				tweet.Text, err.Error())
+			logger.Error("Unable to extract TrackRecord from tweet.", "text", tweet.FullText,
+				"err", err)
+			stats.Failed++
 			continue
 		}
 		trackRecords = append(trackRecords, trackRecord)
-		fetcher.twitterAPIParams.Set("max_id", tweet.IdStr)
 	}
 
-	log.Printf("INFO:    Returned %d TrackRecords, extracted from %d tweets.",
-		len(trackRecords), len(tweets))
+	stats.Extracted = len(trackRecords)
+	ReportStats(ctx, radioStationId, stats)
+	logger.Info("Returned TrackRecords.", "trackRecords", len(trackRecords),
+		"tweets", stats.Items, "failed", stats.Failed, "skipRate", stats.SkipRate())
 	return trackRecords, nil
 }
 
-func extractTrackRecordFromTweet(tweet anaconda.Tweet) (*model.TrackRecord, error) {
-	// Tweet format: `<airtime>: "<title>" von <artist>`
-	// For convenience (and also error resistance),
-	// the time when the tweet was created is used as airtime in the track record. In most cases,
-	// this is a pretty accurate measurement and only diverges about a minute or less from the
-	// real time included in the Tweet text. However, this approach could lead to errors if the
-	// tweets are not created directly after the track has been aired.
-	// TODO: find a viable fallback for the case that the tweet's creation date and the actual
-	// time in the text diverge too much (edge cases, i.e. midnight leaps, should be considered).
-	airtime, err := tweet.CreatedAtTime()
-	if err != nil {
+// getUserTimeline requests the timeline of the Ö3 Twitter account. The Twitter API client does
+// not support contexts, hence the request is abandoned (but not aborted) once `ctx` is done.
+func (fetcher HitradioOE3Fetcher) getUserTimeline(ctx context.Context) (tweets []anaconda.Tweet,
+	err error) {
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
+	_, span := tracer.Start(ctx, "TwitterAPI.GetUserTimeline",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(tracing.FetcherKey.String(radioStationId)))
+	defer func() {
+		span.SetAttributes(attribute.Int("radiochecker.tweets", len(tweets)))
+		tracing.End(span, err)
+	}()
+
+	type result struct {
+		tweets []anaconda.Tweet
+		err    error
+	}
+	// The params are copied since the abandoned request might still read them after the
+	// fetcher has moved on.
+	params := url.Values{}
+	for key, values := range fetcher.twitterAPIParams {
+		params[key] = append([]string(nil), values...)
+	}
+	results := make(chan result, 1)
+	go func() {
+		tweets, err := fetcher.twitterAPI.GetUserTimeline(params)
+		results <- result{tweets, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-results:
+		return res.tweets, res.err
+	}
+}
+
+// Seek restricts the following requests to tweets created before `t`. Since tweet IDs embed
+// their creation time, no request is needed to find the `max_id` to start from. Note that the
+// Twitter API only returns the latest 3200 tweets of a timeline.
+func (fetcher HitradioOE3Fetcher) Seek(t time.Time) {
+	fetcher.twitterAPIParams.Set("max_id", tweetIDForTime(t))
+}
 
-	title, err := getTitleFromTweetText(tweet.FullText)
+// StopAt restricts the following requests to tweets created after `t`. Once all of them have
+// been fetched, NextContext returns ErrExhausted.
+func (fetcher HitradioOE3Fetcher) StopAt(t time.Time) {
+	fetcher.twitterAPIParams.Set("since_id", tweetIDForTime(t))
+}
+
+// containsOlderTweet reports whether `tweets` contain a tweet other than the one with `maxID`,
+// which is included in every response requested with `max_id`.
+func containsOlderTweet(tweets []anaconda.Tweet, maxID string) bool {
+	for _, tweet := range tweets {
+		if tweet.IdStr != maxID {
+			return true
+		}
+	}
+	return false
+}
+
+// tweetIDForTime returns the smallest possible ID of a tweet created at `t`.
+func tweetIDForTime(t time.Time) string {
+	millis := t.UnixNano()/int64(time.Millisecond) - twitterEpoch
+	if millis < 0 {
+		millis = 0
+	}
+	return strconv.FormatInt(millis<<22, 10)
+}
+
+// extractTrackRecordFromTweet extracts the TrackRecord announced by a tweet, see ParseTweet and
+// tweetAirtime. Tweets that cannot be parsed unambiguously are rejected.
+func extractTrackRecordFromTweet(tweet anaconda.Tweet, tolerance time.Duration) (
+	*model.TrackRecord, error) {
+	createdAt, err := tweet.CreatedAtTime()
 	if err != nil {
 		return nil, err
 	}
-
-	artist, err := getArtistFromTweetText(tweet.FullText)
+	parsed, err := ParseTweet(tweet.FullText)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Confidence < minTweetConfidence {
+		return nil, fmt.Errorf("ambiguous %s tweet (confidence %.2f)", parsed.Variant,
+			parsed.Confidence)
+	}
+	airtime, err := tweetAirtime(parsed, createdAt, tolerance)
 	if err != nil {
 		return nil, err
 	}
@@ -111,22 +238,45 @@ func extractTrackRecordFromTweet(tweet anaconda.Tweet) (*model.TrackRecord, erro
 		radioStationId,
 		airtime.Unix(),
 		trackType,
-		model.Track{Title: title, Artist: artist},
+		model.Track{Title: parsed.Title, Artist: parsed.Artist},
 	}, nil
 }
 
-func getTitleFromTweetText(text string) (string, error) {
-	splitted := strings.Split(text, "\"")
-	if len(splitted) < 3 {
-		return "", errors.New("unable to extract title from tweet")
+// tweetAirtime returns the airtime stated at the beginning of a tweet, e.g. `16:39`, on the day
+// the tweet was created in Vienna. Tracks aired shortly before midnight are tweeted
+// on the following day, hence the airtime is moved to the day before if it would be more than
+// twelve hours after the creation of the tweet (and vice versa).
+//
+// The creation time is only used if the tweet does not state an airtime. An airtime that
+// diverges more than `tolerance` from the creation time is rejected, since the track cannot be
+// attributed reliably.
+func tweetAirtime(tweet ParsedTweet, createdAt time.Time, tolerance time.Duration) (time.Time,
+	error) {
+	if tolerance <= 0 {
+		tolerance = DefaultAirtimeTolerance
+	}
+	if !tweet.HasAirtime {
+		return createdAt, nil
 	}
-	return splitted[1], nil
-}
 
-func getArtistFromTweetText(text string) (string, error) {
-	splitted := strings.Split(text, " von ")
-	if len(splitted) < 2 {
-		return "", errors.New("unable to extract artist from tweet")
+	created := createdAt.In(Vienna)
+	airtime := time.Date(created.Year(), created.Month(), created.Day(), tweet.Hour,
+		tweet.Minute, 0, 0, Vienna)
+	// AddDate keeps the wall clock time, even if the day before has a different UTC offset
+	if airtime.Sub(created) > 12*time.Hour {
+		airtime = airtime.AddDate(0, 0, -1)
+	} else if created.Sub(airtime) > 12*time.Hour {
+		airtime = airtime.AddDate(0, 0, 1)
+	}
+
+	divergence := created.Sub(airtime)
+	if divergence < 0 {
+		divergence = -divergence
+	}
+	if divergence > tolerance {
+		return time.Time{}, fmt.Errorf("airtime %s diverges %s from the creation of the tweet "+
+			"at %s (tolerance %s)", airtime.Format("2006-01-02 15:04"), divergence,
+			created.Format("2006-01-02 15:04:05"), tolerance)
 	}
-	return splitted[1], nil
+	return airtime, nil
 }
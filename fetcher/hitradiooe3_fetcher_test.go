@@ -1,12 +1,16 @@
 package fetcher
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/ChimeraCoder/anaconda"
 	"github.com/RadioCheckerApp/api/model"
 	"net/url"
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
 )
 
 type MockTwitterAPI struct{}
@@ -15,17 +19,17 @@ func (api MockTwitterAPI) GetUserTimeline(v url.Values) ([]anaconda.Tweet, error
 	tweets0 := []anaconda.Tweet{
 		{
 			FullText:  "16:39: \"River\" von Eminem feat. Ed Sheeran",
-			CreatedAt: "Mon Aug 26 09:39:00 -0700 2018",
+			CreatedAt: "Sun Aug 26 07:39:40 -0700 2018",
 			IdStr:     "1",
 		},
 		{
 			FullText:  "16:35: \"Last Friday Night\" von Katy Perry",
-			CreatedAt: "Mon Aug 26 09:35:00 -0700 2018",
+			CreatedAt: "Sun Aug 26 07:35:40 -0700 2018",
 			IdStr:     "2",
 		},
 		{
 			FullText:  "16:32: \"Hey Jessy\" von Simon Lewis",
-			CreatedAt: "Mon Aug 26 09:32:00 -0700 2018",
+			CreatedAt: "Sun Aug 26 07:32:40 -0700 2018",
 			IdStr:     "3",
 		},
 	}
@@ -33,17 +37,17 @@ func (api MockTwitterAPI) GetUserTimeline(v url.Values) ([]anaconda.Tweet, error
 	tweets1 := []anaconda.Tweet{
 		{
 			FullText:  "16:32: \"Hey Jessy\" von Simon Lewis",
-			CreatedAt: "Mon Aug 26 09:32:00 -0700 2018",
+			CreatedAt: "Sun Aug 26 07:32:40 -0700 2018",
 			IdStr:     "3",
 		},
 		{
 			FullText:  "16:25: \"Sign of the Times\" von Harry Styles",
-			CreatedAt: "Mon Aug 26 09:25:00 -0700 2018",
+			CreatedAt: "Sun Aug 26 07:25:40 -0700 2018",
 			IdStr:     "4",
 		},
 		{
 			FullText:  "16:22: \"Faded\" von Alan Walker",
-			CreatedAt: "Mon Aug 26 09:22:00 -0700 2018",
+			CreatedAt: "Sun Aug 26 07:22:40 -0700 2018",
 			IdStr:     "5",
 		},
 	}
@@ -83,9 +87,9 @@ func TestNewHitradioOE3Fetcher(t *testing.T) {
 var stationId = "hitradio-oe3"
 
 var expectedTrackRecords = []*model.TrackRecord{
-	{stationId, 1535301540, "track", model.Track{"Eminem feat. Ed Sheeran", "River"}},
-	{stationId, 1535301300, "track", model.Track{"Katy Perry", "Last Friday Night"}},
-	{stationId, 1535301120, "track", model.Track{"Simon Lewis", "Hey Jessy"}},
+	{stationId, 1535294340, "track", model.Track{"Eminem feat. Ed Sheeran", "River"}},
+	{stationId, 1535294100, "track", model.Track{"Katy Perry", "Last Friday Night"}},
+	{stationId, 1535293920, "track", model.Track{"Simon Lewis", "Hey Jessy"}},
 }
 
 type FetcherTest struct {
@@ -98,13 +102,13 @@ type FetcherTest struct {
 func TestHitradioOE3Fetcher_Next_Basic(t *testing.T) {
 	var tests = []FetcherTest{
 		{
-			HitradioOE3Fetcher{MockTwitterAPI{}, url.Values{}},
+			HitradioOE3Fetcher{MockTwitterAPI{}, url.Values{}, 0},
 			expectedTrackRecords,
 			"3",
 			false,
 		},
 		{
-			HitradioOE3Fetcher{MockTwitterAPI{}, url.Values{"error": []string{"ok"}}},
+			HitradioOE3Fetcher{MockTwitterAPI{}, url.Values{"error": []string{"ok"}}, 0},
 			nil,
 			"X",
 			true,
@@ -118,10 +122,10 @@ func TestHitradioOE3Fetcher_Next_Basic(t *testing.T) {
 
 func TestHitradioOE3Fetcher_Next_Loop(t *testing.T) {
 	test := FetcherTest{
-		HitradioOE3Fetcher{MockTwitterAPI{}, url.Values{}},
+		HitradioOE3Fetcher{MockTwitterAPI{}, url.Values{}, 0},
 		[]*model.TrackRecord{
-			{stationId, 1535300700, "track", model.Track{"Harry Styles", "Sign of the Times"}},
-			{stationId, 1535300520, "track", model.Track{"Alan Walker", "Faded"}},
+			{stationId, 1535293500, "track", model.Track{"Harry Styles", "Sign of the Times"}},
+			{stationId, 1535293320, "track", model.Track{"Alan Walker", "Faded"}},
 		},
 		"5",
 		false,
@@ -135,7 +139,7 @@ func runTest(test FetcherTest, t *testing.T) {
 	trackRecords, err := test.fetcher.Next()
 
 	if (err != nil) != test.expectedErr {
-		t.Errorf("(%q) Next(): got err (%v), expected err (%v)",
+		t.Errorf("(%v) Next(): got err (%v), expected err (%v)",
 			test.fetcher, err, test.expectedErr)
 	}
 
@@ -144,12 +148,202 @@ func runTest(test FetcherTest, t *testing.T) {
 	}
 
 	if !reflect.DeepEqual(trackRecords, test.expectedTrackRecords) {
-		t.Errorf("(%q) Next(): got\n(%q, %v), expected\n(%q, %v)",
+		t.Errorf("(%v) Next(): got\n(%v, %v), expected\n(%v, %v)",
 			test.fetcher, trackRecords, err, test.expectedTrackRecords, test.expectedErr)
 	}
 
 	if test.fetcher.twitterAPIParams.Get("max_id") != test.expectedMaxID {
-		t.Errorf("(%q) twitterAPIParams.Get(\"max_id\"): got (%s), expected (%s)",
+		t.Errorf("(%v) twitterAPIParams.Get(\"max_id\"): got (%s), expected (%s)",
 			test.fetcher, test.fetcher.twitterAPIParams.Get("max_id"), test.expectedMaxID)
 	}
 }
+
+type MockTwitterAPIBlocking struct {
+	release chan struct{}
+}
+
+func (api MockTwitterAPIBlocking) GetUserTimeline(v url.Values) ([]anaconda.Tweet, error) {
+	<-api.release
+	return nil, nil
+}
+
+func TestHitradioOE3Fetcher_NextContext_Deadline(t *testing.T) {
+	api := MockTwitterAPIBlocking{make(chan struct{})}
+	defer close(api.release)
+	fetcher := HitradioOE3Fetcher{api, url.Values{}, 0}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := fetcher.NextContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("NextContext: got err (%v), expected (%v)", err, context.DeadlineExceeded)
+	}
+}
+
+func TestHitradioOE3Fetcher_Seek(t *testing.T) {
+	fetcher := HitradioOE3Fetcher{MockTwitterAPI{}, url.Values{}, 0}
+	// tweet 1050118621198921728 was created at 1539202764211 ms since the Unix epoch
+	fetcher.Seek(time.Unix(0, 1539202764211*int64(time.Millisecond)))
+	if maxID := fetcher.twitterAPIParams.Get("max_id"); maxID != "1050118621197500416" {
+		t.Errorf("Seek: got max_id (%s), expected (1050118621197500416)", maxID)
+	}
+
+	fetcher.Seek(time.Unix(0, 0))
+	if maxID := fetcher.twitterAPIParams.Get("max_id"); maxID != "0" {
+		t.Errorf("Seek: got max_id (%s), expected (0)", maxID)
+	}
+}
+
+type MockTwitterAPITweets []anaconda.Tweet
+
+func (api MockTwitterAPITweets) GetUserTimeline(v url.Values) ([]anaconda.Tweet, error) {
+	return api, nil
+}
+
+func TestHitradioOE3Fetcher_NextContext_Stats(t *testing.T) {
+	api := MockTwitterAPITweets{
+		{FullText: "16:39: \"River\" von Eminem", CreatedAt: "Sun Aug 26 07:39:40 -0700 2018",
+			IdStr: "1"},
+		{FullText: "Jetzt im Ö3-Wecker: Robert Kratky",
+			CreatedAt: "Sun Aug 26 07:37:40 -0700 2018", IdStr: "2"},
+		{FullText: "16:35: \"Last Friday Night\" von Katy Perry",
+			CreatedAt: "Sun Aug 26 07:35:40 -0700 2018", IdStr: "3"},
+		// the airtime diverges more than the default tolerance from the creation at 16:33
+		{FullText: "12:00: \"Shallow\" von Lady Gaga",
+			CreatedAt: "Sun Aug 26 07:33:40 -0700 2018", IdStr: "4"},
+	}
+	fetcher := HitradioOE3Fetcher{api, url.Values{"max_id": []string{"1"}}, 0}
+	collector := &StatsCollector{}
+
+	trackRecords, err := fetcher.NextContext(WithStatsCollector(context.Background(), collector))
+	if err != nil || len(trackRecords) != 1 {
+		t.Fatalf("NextContext: got (%v, %v), expected a single TrackRecord", trackRecords, err)
+	}
+	expected := Stats{Items: 4, Extracted: 1, Failed: 2, Skipped: 1}
+	if stats := collector.Stats(); stats != expected {
+		t.Errorf("NextContext: got stats %+v, expected %+v", stats, expected)
+	}
+}
+
+func TestTweetAirtime(t *testing.T) {
+	var tests = []struct {
+		text            string
+		createdAt       string
+		tolerance       time.Duration
+		expectedAirtime string
+		expectedErr     bool
+	}{
+		{"16:39: \"River\" von Eminem", "2018-08-26 16:40:12", 0, "2018-08-26 16:39:00", false},
+		{"9:05: \"River\" von Eminem", "2018-08-26 09:05:59", 0, "2018-08-26 09:05:00", false},
+		{"\"River\" von Eminem", "2018-08-26 16:40:12", 0, "2018-08-26 16:40:12", false},
+		// tweeted after midnight, aired before
+		{"23:58: \"River\" von Eminem", "2018-08-27 00:03:00", 0, "2018-08-26 23:58:00", false},
+		// tweeted before midnight, e.g. due to clock skew
+		{"00:01: \"River\" von Eminem", "2018-08-26 23:59:30", 0, "2018-08-27 00:01:00", false},
+		// the day before ends with the switch to winter time
+		{"23:58: \"River\" von Eminem", "2018-10-29 00:03:00", 0, "2018-10-28 23:58:00", false},
+		// the airtime diverges more than the tolerance, hence the tweet is rejected
+		{"16:39: \"River\" von Eminem", "2018-08-26 17:20:00", 0, "", true},
+		{"16:39: \"River\" von Eminem", "2018-08-26 17:20:00", time.Hour, "2018-08-26 16:39:00",
+			false},
+	}
+
+	for _, test := range tests {
+		createdAt, _ := time.ParseInLocation(timeFormatStr, test.createdAt, location)
+		parsed, err := ParseTweet(test.text)
+		if err != nil {
+			t.Fatalf("ParseTweet(%q): got error (%v)", test.text, err)
+		}
+		airtime, err := tweetAirtime(parsed, createdAt.UTC(), test.tolerance)
+		if (err != nil) != test.expectedErr {
+			t.Errorf("tweetAirtime(%q, %s): got error (%v), expected error: %v", test.text,
+				test.createdAt, err, test.expectedErr)
+		}
+		if err != nil {
+			continue
+		}
+		if formatted := airtime.In(location).Format(timeFormatStr); formatted !=
+			test.expectedAirtime {
+			t.Errorf("tweetAirtime(%q, %s): got %s, expected %s", test.text, test.createdAt,
+				formatted, test.expectedAirtime)
+		}
+	}
+}
+
+// MockTwitterAPITimeline returns the tweets of a timeline, newest first, like the Twitter API:
+// `max_id` is inclusive, `since_id` exclusive and at most `count` tweets are returned.
+type MockTwitterAPITimeline struct {
+	tweets []anaconda.Tweet
+	calls  *int
+}
+
+func (api MockTwitterAPITimeline) GetUserTimeline(v url.Values) ([]anaconda.Tweet, error) {
+	*api.calls++
+	count, _ := strconv.Atoi(v.Get("count"))
+	var tweets []anaconda.Tweet
+	for _, tweet := range api.tweets {
+		id, _ := strconv.ParseInt(tweet.IdStr, 10, 64)
+		maxID, err := strconv.ParseInt(v.Get("max_id"), 10, 64)
+		if err == nil && id > maxID {
+			continue
+		}
+		if sinceID, err := strconv.ParseInt(v.Get("since_id"), 10, 64); err == nil &&
+			id <= sinceID {
+			continue
+		}
+		if len(tweets) < count {
+			tweets = append(tweets, tweet)
+		}
+	}
+	return tweets, nil
+}
+
+func TestHitradioOE3Fetcher_NextContext_Exhausted(t *testing.T) {
+	var tweets []anaconda.Tweet
+	created := time.Date(2018, 8, 26, 16, 50, 40, 0, location)
+	for i := 0; i < 5; i++ {
+		created = created.Add(-5 * time.Minute)
+		tweets = append(tweets, anaconda.Tweet{
+			FullText:  fmt.Sprintf("%s: \"River\" von Eminem", created.Format("15:04")),
+			CreatedAt: created.UTC().Format(time.RubyDate),
+			IdStr:     tweetIDForTime(created),
+		})
+	}
+	// the last tweet cannot be parsed, which must not keep the fetcher from stopping
+	tweets[4].FullText = "Jetzt im Ö3-Wecker: Robert Kratky"
+
+	var tests = []struct {
+		stopAt          time.Time
+		expectedRecords int
+		expectedCalls   int
+	}{
+		// pages of 2 tweets, each repeating the last tweet of the page before
+		{time.Time{}, 4, 5},
+		{created.Add(7 * time.Minute), 3, 3},
+	}
+
+	for _, test := range tests {
+		calls := 0
+		fetcher := HitradioOE3Fetcher{MockTwitterAPITimeline{tweets, &calls},
+			url.Values{"count": []string{"2"}}, 0}
+		if !test.stopAt.IsZero() {
+			fetcher.StopAt(test.stopAt)
+		}
+
+		var trackRecords []*model.TrackRecord
+		var err error
+		for calls < 10 {
+			var page []*model.TrackRecord
+			if page, err = fetcher.NextContext(context.Background()); err != nil {
+				break
+			}
+			trackRecords = append(trackRecords, page...)
+		}
+		if err != ErrExhausted || len(trackRecords) != test.expectedRecords ||
+			calls != test.expectedCalls {
+			t.Errorf("StopAt(%s) NextContext: got %d TrackRecords and error (%v) after %d "+
+				"calls, expected %d TrackRecords and (%v) after %d calls", test.stopAt,
+				len(trackRecords), err, calls, test.expectedRecords, ErrExhausted,
+				test.expectedCalls)
+		}
+	}
+}
@@ -1,11 +1,17 @@
 package fetcher
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/RadioCheckerApp/api/model"
-	"log"
+	"github.com/RadioCheckerApp/crawlers/logging"
+	"github.com/RadioCheckerApp/crawlers/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"sort"
 	"strconv"
@@ -20,6 +26,17 @@ const kronehitChannel = 1
 const kronehitRequestLimit = 10
 const kronehitTimeCorrection = 7 * time.Minute
 
+// ErrRequestLimitExceeded is returned by KronehitFetcher.Next once the fetcher has sent the
+// maximum number of requests allowed per crawl.
+var ErrRequestLimitExceeded = errors.New("request limit exceeded")
+
+func init() {
+	Register(kronehitId, func(options map[string]string) (Fetcher, error) {
+		fetcher := NewKronehitFetcher()
+		return &fetcher, nil
+	})
+}
+
 type KronehitItem struct {
 	PlayTime       string
 	ArtistName     string
@@ -35,10 +52,8 @@ func (item *KronehitItem) Hour() int {
 
 func (item *KronehitItem) toTrackRecord(playDate *time.Time) (*model.TrackRecord, error) {
 	dateTimeStr := fmt.Sprintf("%s %s", playDate.Format("2006-01-02"), item.PlayTime)
-	dateTime, err := time.ParseInLocation("2006-01-02 15:04:05", dateTimeStr, getLocation())
+	dateTime, err := time.ParseInLocation("2006-01-02 15:04:05", dateTimeStr, Vienna)
 	if err != nil {
-		log.Printf("ERROR:   Unable to parse `%s` to time. Message: `%s`.",
-			dateTimeStr, err.Error())
 		return nil, err
 	}
 
@@ -54,10 +69,13 @@ type KronehitItems struct {
 	Items []KronehitItem
 }
 
-func (items *KronehitItems) toTrackRecords(fetchTime *time.Time,
-	skip func(record *model.TrackRecord) bool) []*model.TrackRecord {
+// toTrackRecords extracts the TrackRecords of the items that are not skipped and counts the
+// items that could not be parsed.
+func (items *KronehitItems) toTrackRecords(logger *slog.Logger, fetchTime *time.Time,
+	skip func(record *model.TrackRecord) bool) ([]*model.TrackRecord, Stats) {
 	spanningOverMidnight := items.spanOverMidnight()
 	fetchedOverMidnight := items.fetchedOverMidnight(fetchTime)
+	stats := Stats{Items: len(items.Items)}
 	var trackRecords []*model.TrackRecord
 	for _, item := range items.Items {
 		playDate := *fetchTime
@@ -66,19 +84,20 @@ func (items *KronehitItems) toTrackRecords(fetchTime *time.Time,
 		}
 		trackRecord, err := item.toTrackRecord(&playDate)
 		if err != nil {
-			log.Printf("ERROR:   Unable to extract TrackRecord from item: `%q`. Message: `%s`.",
-				item, err.Error())
+			logger.Error("Unable to extract TrackRecord from item.", "item", item, "err", err)
+			stats.Failed++
 			continue
 		}
 		if skip(trackRecord) {
-			log.Printf("INFO:    Skipping item `%s - %s` (Airtime: %s). "+
-				"Newer than or equal to last fetched Track.",
-				item.ArtistName, item.TrackName, item.PlayTime)
+			logger.Debug("Skipping item. Newer than or equal to last fetched Track.",
+				"artist", item.ArtistName, "title", item.TrackName, "airtime", item.PlayTime)
+			stats.Skipped++
 			continue
 		}
 		trackRecords = append(trackRecords, trackRecord)
 	}
-	return trackRecords
+	stats.Extracted = len(trackRecords)
+	return trackRecords, stats
 }
 
 func (items *KronehitItems) spanOverMidnight() bool {
@@ -102,7 +121,7 @@ func sanitizedPlayDate(fetchTime *time.Time, itemHour int) time.Time {
 }
 
 type KronehitAPI interface {
-	GetItems(time.Time) (KronehitItems, error)
+	GetItems(context.Context, time.Time) (KronehitItems, error)
 }
 
 type KronehitAPIImplementation struct {
@@ -114,7 +133,10 @@ func NewKronehitAPIImplementation(timeout time.Duration) KronehitAPIImplementati
 	return KronehitAPIImplementation{client}
 }
 
-func (api KronehitAPIImplementation) GetItems(date time.Time) (KronehitItems, error) {
+// GetItems requests the items aired before `date`. The request and the decoding of the response
+// are traced as separate spans.
+func (api KronehitAPIImplementation) GetItems(ctx context.Context, date time.Time) (KronehitItems,
+	error) {
 	url := fmt.Sprintf(
 		kronehitAPI,
 		date.Format("2006-01-02"),
@@ -122,28 +144,54 @@ func (api KronehitAPIImplementation) GetItems(date time.Time) (KronehitItems, er
 		date.Hour(),
 		date.Minute(),
 	)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	body, err := api.get(ctx, url)
 	if err != nil {
-		log.Printf("ERROR:   Unable to create HTTP request. Message: `%s`.", err.Error())
 		return KronehitItems{}, err
 	}
-	req.Header.Add("User-Agent", randomizedUserAgent())
-	resp, err := api.client.Do(req)
+
+	_, span := tracer.Start(ctx, "KronehitItems.Decode", trace.WithAttributes(
+		tracing.FetcherKey.String(kronehitId)))
+	var items KronehitItems
+	err = json.Unmarshal(body, &items)
+	tracing.End(span, err)
 	if err != nil {
-		log.Printf("ERROR:   HTTP request to URL `%s` failed. Message: `%s`.", url, err.Error())
+		logging.FromContext(ctx).Error("Unmarshalling JSON body failed.", "err", err)
 		return KronehitItems{}, err
 	}
-	defer resp.Body.Close()
 
-	log.Printf("INFO:    HTTP call executed: `%s`.", url)
+	return items, nil
+}
+
+// get requests `url` and returns the body of the response.
+func (api KronehitAPIImplementation) get(ctx context.Context, url string) (body []byte,
+	err error) {
+	ctx, span := tracer.Start(ctx, "KronehitAPI.GetItems", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(tracing.FetcherKey.String(kronehitId),
+			semconv.HTTPRequestMethodKey.String(http.MethodGet), semconv.URLFull(url)))
+	defer func() { tracing.End(span, err) }()
 
-	var items KronehitItems
-	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
-		log.Printf("ERROR:   Unmarshalling JSON body failed. Message: `%s`.", err.Error())
-		return KronehitItems{}, err
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		logging.FromContext(ctx).Error("Unable to create HTTP request.", "err", err)
+		return nil, err
 	}
+	req.Header.Add("User-Agent", randomizedUserAgent())
+	resp, err := api.client.Do(req.WithContext(ctx))
+	if err != nil {
+		logging.FromContext(ctx).Error("HTTP request failed.", "url", url, "err", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
 
-	return items, nil
+	logging.FromContext(ctx).Info("HTTP call executed.", "url", url, "status", resp.StatusCode)
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		logging.FromContext(ctx).Error("Unable to read response body.", "url", url, "err", err)
+		return nil, err
+	}
+	return body, nil
 }
 
 type KronehitFetcher struct {
@@ -155,33 +203,42 @@ type KronehitFetcher struct {
 func NewKronehitFetcher() KronehitFetcher {
 	kronehitAPI := NewKronehitAPIImplementation(requestTimeout)
 	// ALWAYS crawl in the past to avoid inconsistent data
-	nextFetchTime := time.Now().Add(-kronehitTimeCorrection).In(getLocation())
-	log.Printf("INFO:    Set nextFetchTime to %s.", nextFetchTime.Format("2006-01-02 15:04:05"))
+	nextFetchTime := time.Now().Add(-kronehitTimeCorrection).In(Vienna)
+	slog.Info("Set nextFetchTime.", "fetcher", kronehitId,
+		"nextFetchTime", nextFetchTime.Format("2006-01-02 15:04:05"))
 	return KronehitFetcher{kronehitAPI, nextFetchTime, 0}
 }
 
 func (fetcher *KronehitFetcher) Next() ([]*model.TrackRecord, error) {
+	return fetcher.NextContext(context.Background())
+}
+
+func (fetcher *KronehitFetcher) NextContext(ctx context.Context) ([]*model.TrackRecord, error) {
+	logger := logging.FromContext(ctx)
 	if fetcher.fetchCounter >= kronehitRequestLimit {
-		log.Printf("ERROR:   Request limit exceeded.")
-		return nil, errors.New("request limit exceeded")
+		logger.Error("Request limit exceeded.", "limit", kronehitRequestLimit)
+		return nil, ErrRequestLimitExceeded
 	}
 
-	items, err := fetcher.kronehitAPI.GetItems(fetcher.nextFetchTime)
+	items, err := fetcher.kronehitAPI.GetItems(ctx, fetcher.nextFetchTime)
+	recordRequest(kronehitId, err)
 	if err != nil {
-		log.Printf("ERROR:   Unable to fetch items from kronehit. Message: `%s`.", err.Error())
+		logger.Error("Unable to fetch items from kronehit.", "err", err)
 		return nil, err
 	}
 
-	log.Printf("INFO:    Fetched %d items from Kronehit.", len(items.Items))
+	logger.Info("Fetched items from Kronehit.", "items", len(items.Items))
 
-	trackRecords := items.toTrackRecords(&fetcher.nextFetchTime, func(record *model.TrackRecord) bool {
-		// always skip records that are younger than the last fetch time
-		return record.Timestamp >= fetcher.nextFetchTime.Add(kronehitTimeCorrection).Unix()
-	})
+	trackRecords, stats := items.toTrackRecords(logger, &fetcher.nextFetchTime,
+		func(record *model.TrackRecord) bool {
+			// always skip records that are younger than the last fetch time
+			return record.Timestamp >= fetcher.nextFetchTime.Add(kronehitTimeCorrection).Unix()
+		})
+	ReportStats(ctx, kronehitId, stats)
 
 	if len(trackRecords) == 0 {
-		log.Printf("WARNING: Unable to extract any TrackRecords from %d items. SkipRate = %."+
-			"2f%%", len(items.Items), calculateSkipRate(len(trackRecords), len(items.Items)))
+		logger.Warn("Unable to extract any TrackRecords.", "items", stats.Items,
+			"failed", stats.Failed, "skipRate", stats.SkipRate())
 		return nil, errors.New("unable to extract any trackRecords")
 	}
 
@@ -191,14 +248,23 @@ func (fetcher *KronehitFetcher) Next() ([]*model.TrackRecord, error) {
 
 	lastFetchedTrackTimestamp := trackRecords[len(trackRecords)-1].Timestamp
 	fetcher.nextFetchTime = time.Unix(lastFetchedTrackTimestamp, 0).
-		In(getLocation()).Add(-kronehitTimeCorrection)
+		In(Vienna).Add(-kronehitTimeCorrection)
 	fetcher.fetchCounter++
 
-	log.Printf("INFO:    Returned %d TrackRecords, extracted from %d items. SkipRate = %.2f%%",
-		len(trackRecords), len(items.Items), calculateSkipRate(len(trackRecords), len(items.Items)))
+	logger.Info("Returned TrackRecords.", "trackRecords", len(trackRecords),
+		"items", stats.Items, "failed", stats.Failed, "skipRate", stats.SkipRate())
 	return trackRecords, nil
 }
 
+// Seek sets the fetch time to `t` and resets the request counter, so that the fetcher continues
+// with the tracks aired before `t`.
+func (fetcher *KronehitFetcher) Seek(t time.Time) {
+	fetcher.nextFetchTime = t.In(Vienna).Add(-kronehitTimeCorrection)
+	fetcher.fetchCounter = 0
+	slog.Info("Set nextFetchTime.", "fetcher", kronehitId,
+		"nextFetchTime", fetcher.nextFetchTime.Format("2006-01-02 15:04:05"))
+}
+
 func (fetcher *KronehitFetcher) isFirstFetch() bool {
 	return fetcher.fetchCounter == 0
 }
@@ -208,19 +274,3 @@ func (fetcher *KronehitFetcher) isFirstFetch() bool {
 func randomizedUserAgent() string {
 	return "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/60.0.3112.113 Safari/537.36"
 }
-
-func getLocation() *time.Location {
-	loc, err := time.LoadLocation("Europe/Vienna")
-	if err != nil {
-		log.Fatal("getLocation: " + err.Error())
-	}
-	return loc
-}
-
-func calculateSkipRate(extractedTrackRecords, fetchedItems int) float32 {
-	if fetchedItems <= 0 {
-		return 0
-	}
-	skippedItems := fetchedItems - extractedTrackRecords
-	return float32(skippedItems) / float32(fetchedItems) * 100
-}
@@ -1,6 +1,7 @@
 package fetcher
 
 import (
+	"context"
 	"errors"
 	"github.com/RadioCheckerApp/api/model"
 	"reflect"
@@ -14,7 +15,7 @@ var timeCorrection = 7 * time.Minute
 
 type MockKronehitAPI struct{}
 
-func (api MockKronehitAPI) GetItems(date time.Time) (KronehitItems, error) {
+func (api MockKronehitAPI) GetItems(ctx context.Context, date time.Time) (KronehitItems, error) {
 	items0 := KronehitItems{
 		[]KronehitItem{
 			{"05:16:46", "DENNIS LLOYD", "NEVERMIND", ""},
@@ -89,7 +90,8 @@ type MockKronehitAPIMidnightLoop struct {
 	loopCounter int
 }
 
-func (api *MockKronehitAPIMidnightLoop) GetItems(date time.Time) (KronehitItems, error) {
+func (api *MockKronehitAPIMidnightLoop) GetItems(ctx context.Context,
+	date time.Time) (KronehitItems, error) {
 	defer func() { api.loopCounter++ }()
 
 	item0 := KronehitItems{
@@ -306,7 +308,7 @@ func TestKronehitFetcher_Next_Midnight_Loop(t *testing.T) {
 	}
 
 	if !reflect.DeepEqual(results, kronehitExpectedTrackRecordsNextMidnightLoop) {
-		t.Errorf("(%v) Next(): got\n(%q), expected\n(%q)",
+		t.Errorf("(%v) Next(): got\n(%v), expected\n(%v)",
 			fetcher, results, kronehitExpectedTrackRecordsNextMidnightLoop)
 	}
 }
@@ -336,7 +338,7 @@ func runKronehitTest(test KronehitFetcherTest, t *testing.T) {
 	}
 
 	if !reflect.DeepEqual(trackRecords, test.expectedTrackRecords) {
-		t.Errorf("(%v) Next(): got\n(%q, %v), expected\n(%q, %v)",
+		t.Errorf("(%v) Next(): got\n(%v, %v), expected\n(%v, %v)",
 			test.fetcher, trackRecords, err, test.expectedTrackRecords, test.expectedErr)
 	}
 
@@ -345,3 +347,17 @@ func runKronehitTest(test KronehitFetcherTest, t *testing.T) {
 			test.fetcher, test.fetcher.nextFetchTime, test.expectedNextFetchTime)
 	}
 }
+
+func TestKronehitFetcher_Seek(t *testing.T) {
+	fetcher := KronehitFetcher{MockKronehitAPI{}, time.Time{}, kronehitRequestLimit}
+	fetcher.Seek(nextFetchTime)
+	if _, err := fetcher.Next(); err != nil {
+		t.Errorf("Seek: fetcher did not reset its request counter, got err (%v)", err)
+	}
+
+	fetcher.Seek(nextFetchTime)
+	if !fetcher.nextFetchTime.Add(timeCorrection).Equal(nextFetchTime) {
+		t.Errorf("Seek: got nextFetchTime (%v), expected (%v)",
+			fetcher.nextFetchTime, nextFetchTime.Add(-timeCorrection))
+	}
+}
@@ -0,0 +1,57 @@
+package fetcher
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The metrics are registered with the default Prometheus registry.
+var (
+	fetcherRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "radiochecker",
+		Subsystem: "fetcher",
+		Name:      "requests_total",
+		Help:      "Requests to the station's source by fetcher type and result (success, error).",
+	}, []string{"fetcher", "result"})
+	fetcherItems = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "radiochecker",
+		Subsystem: "fetcher",
+		Name:      "items_total",
+		Help: "Fetched items by fetcher type and result (extracted, failed to parse, " +
+			"skipped).",
+	}, []string{"fetcher", "result"})
+	fetcherSkipRate = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "radiochecker",
+		Subsystem: "fetcher",
+		Name:      "skip_rate_percent",
+		Help:      "Percentage of the items of the last response without TrackRecord.",
+	}, []string{"fetcher"})
+	fetcherFailureRate = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "radiochecker",
+		Subsystem: "fetcher",
+		Name:      "parse_failure_rate_percent",
+		Help:      "Percentage of the items of the last response that could not be parsed.",
+	}, []string{"fetcher"})
+)
+
+// recordRequest updates the request metrics of the fetcher type.
+func recordRequest(fetcherType string, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	fetcherRequests.WithLabelValues(fetcherType, result).Inc()
+}
+
+// recordItems updates the item metrics of the fetcher type with the stats of a response.
+func recordItems(fetcherType string, stats Stats) {
+	fetcherItems.WithLabelValues(fetcherType, "extracted").Add(float64(stats.Extracted))
+	if stats.Failed > 0 {
+		fetcherItems.WithLabelValues(fetcherType, "failed").Add(float64(stats.Failed))
+	}
+	if skipped := stats.Items - stats.Extracted - stats.Failed; skipped > 0 {
+		fetcherItems.WithLabelValues(fetcherType, "skipped").Add(float64(skipped))
+	}
+	fetcherSkipRate.WithLabelValues(fetcherType).Set(stats.SkipRate())
+	fetcherFailureRate.WithLabelValues(fetcherType).Set(stats.FailureRate())
+}
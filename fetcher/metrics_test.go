@@ -0,0 +1,29 @@
+package fetcher
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"testing"
+)
+
+func TestRecordItems(t *testing.T) {
+	recordItems("metrics-test", Stats{Items: 4, Extracted: 3, Skipped: 1})
+	recordItems("metrics-test", Stats{Items: 3, Extracted: 2, Failed: 1})
+
+	if extracted := testutil.ToFloat64(fetcherItems.WithLabelValues("metrics-test",
+		"extracted")); extracted != 5 {
+		t.Errorf("recordItems: got %v extracted items, expected 5", extracted)
+	}
+	if skipped := testutil.ToFloat64(fetcherItems.WithLabelValues("metrics-test",
+		"skipped")); skipped != 1 {
+		t.Errorf("recordItems: got %v skipped items, expected 1", skipped)
+	}
+	if failed := testutil.ToFloat64(fetcherItems.WithLabelValues("metrics-test",
+		"failed")); failed != 1 {
+		t.Errorf("recordItems: got %v failed items, expected 1", failed)
+	}
+	failureRate := testutil.ToFloat64(fetcherFailureRate.WithLabelValues("metrics-test"))
+	if failureRate < 33.3 || failureRate > 33.4 {
+		t.Errorf("recordItems: got failure rate %v, expected the rate of the last response "+
+			"(33.3)", failureRate)
+	}
+}
@@ -0,0 +1,173 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Variants of the Ö3 tweet format `<airtime>: "<title>" von <artist>` recognized by ParseTweet.
+const (
+	// TweetVariantQuoted is the regular format, e.g. `16:39: "River" von Eminem`.
+	TweetVariantQuoted = "quoted"
+	// TweetVariantTypographic quotes the title with typographic quotes, e.g.
+	// `16:39: „River“ von Eminem`.
+	TweetVariantTypographic = "typographic"
+	// TweetVariantUnquoted does not quote the title, e.g. `16:39: River von Eminem`. Title and
+	// artist are separated at the first ` von `.
+	TweetVariantUnquoted = "unquoted"
+)
+
+// minTweetConfidence is the confidence below which the fetcher rejects a parsed tweet.
+const minTweetConfidence = 0.5
+
+var (
+	// tweetAirtimePattern matches the airtime and its separator at the beginning of a tweet,
+	// e.g. `16:39: ` or `16.39 Uhr - `.
+	tweetAirtimePattern = regexp.MustCompile(`^(\d{1,2})[:.](\d{2})(?:\s*Uhr)?\s*[:|\-–]?\s*`)
+	// tweetTrailerPattern matches the hashtags, mentions and URLs at the end of a tweet.
+	tweetTrailerPattern = regexp.MustCompile(`(?:\s+(?:#\S+|@\w+|https?://\S+))+\s*$`)
+	// tweetSeparatorPattern matches the ` von ` following the quoted title,
+	// unquotedSeparatorPattern every ` von ` of an unquoted tweet.
+	tweetSeparatorPattern    = regexp.MustCompile(`^\s+von\s+`)
+	unquotedSeparatorPattern = regexp.MustCompile(`\s+von\s+`)
+	// artistFeaturingPattern and titleFeaturingPattern match the featured artists, e.g.
+	// `Eminem feat. Ed Sheeran` or `River (feat. Ed Sheeran)`.
+	artistFeaturingPattern = regexp.MustCompile(`(?i)\s+(?:feat\.?|ft\.?|featuring)\s+(.+)$`)
+	titleFeaturingPattern  = regexp.MustCompile(
+		`(?i)[(\[](?:feat\.?|ft\.?|featuring)\s+([^)\]]+)[)\]]`)
+)
+
+// closingQuotes maps the quotes that may open a title to the quotes that regularly close it.
+var closingQuotes = map[rune]string{
+	'"':  `"`,
+	'\'': `'`,
+	'„':  `“”`,
+	'“':  `”“`,
+	'”':  `”`,
+	'‚':  `‘’`,
+	'‘':  `’‘`,
+	'«':  `»`,
+	'»':  `«`,
+}
+
+// ParsedTweet is the track announced by an Ö3 tweet, see ParseTweet.
+type ParsedTweet struct {
+	// HasAirtime is set if the tweet starts with the airtime `Hour:Minute`.
+	HasAirtime bool
+	Hour       int
+	Minute     int
+	Title      string
+	// Artist is the artist as credited by the tweet, including the featured artists.
+	Artist string
+	// Featuring names the featured artists credited in the artist or the title, if any.
+	Featuring string
+	// Variant is the format variant that matched the tweet.
+	Variant string
+	// Confidence rates how unambiguous the tweet could be parsed, from 0 to 1.
+	Confidence float64
+}
+
+// ParseTweet parses the text of a tweet of the format `<airtime>: "<title>" von <artist>` and its
+// variants. Hashtags, mentions and URLs at the end of the tweet are ignored. The title ends at
+// the last quote that is followed by ` von `, so titles may contain quotes and artists may
+// contain `von`, e.g. `"Sign "O" the Times" von Prince` or `"Boléro" von Herbert von Karajan`.
+//
+// The confidence is lowered for tweets without airtime, unquoted titles (especially if they
+// contain several ` von `), titles containing their own kind of quotes and mismatched quotes.
+func ParseTweet(text string) (ParsedTweet, error) {
+	parsed := ParsedTweet{Confidence: 1}
+	rest := strings.TrimSpace(tweetTrailerPattern.ReplaceAllString(strings.TrimSpace(text), ""))
+
+	if match := tweetAirtimePattern.FindStringSubmatch(rest); match != nil {
+		hour, _ := strconv.Atoi(match[1])
+		minute, _ := strconv.Atoi(match[2])
+		if hour > 23 || minute > 59 {
+			return ParsedTweet{}, fmt.Errorf("invalid airtime `%s`",
+				strings.TrimSpace(match[0]))
+		}
+		parsed.HasAirtime, parsed.Hour, parsed.Minute = true, hour, minute
+		rest = rest[len(match[0]):]
+	} else {
+		parsed.Confidence -= 0.2
+	}
+
+	if !parsed.splitQuoted(rest) && !parsed.splitUnquoted(rest) {
+		return ParsedTweet{}, errors.New("unable to find title and artist in tweet")
+	}
+	parsed.Title = strings.TrimSpace(parsed.Title)
+	parsed.Artist = strings.TrimSpace(strings.TrimRight(parsed.Artist, " :|-–"))
+	if parsed.Title == "" || parsed.Artist == "" {
+		return ParsedTweet{}, errors.New("tweet contains an empty title or artist")
+	}
+
+	if match := artistFeaturingPattern.FindStringSubmatch(parsed.Artist); match != nil {
+		parsed.Featuring = match[1]
+	} else if match := titleFeaturingPattern.FindStringSubmatch(parsed.Title); match != nil {
+		parsed.Featuring = strings.TrimSpace(match[1])
+	}
+	parsed.Confidence = math.Max(0, math.Round(parsed.Confidence*100)/100)
+	return parsed, nil
+}
+
+// splitQuoted splits `"<title>" von <artist>` at the last closing quote that is followed by
+// ` von ` and reports whether the text matched.
+func (parsed *ParsedTweet) splitQuoted(text string) bool {
+	opening, size := utf8.DecodeRuneInString(text)
+	closing, ok := closingQuotes[opening]
+	if !ok {
+		return false
+	}
+
+	end := -1
+	var closedBy rune
+	for i, r := range text[size:] {
+		if isQuote(r) && tweetSeparatorPattern.MatchString(text[size+i+utf8.RuneLen(r):]) {
+			end, closedBy = size+i, r
+		}
+	}
+	if end < 0 {
+		return false
+	}
+
+	parsed.Title = text[size:end]
+	afterQuote := text[end+utf8.RuneLen(closedBy):]
+	parsed.Artist = afterQuote[len(tweetSeparatorPattern.FindString(afterQuote)):]
+	parsed.Variant = TweetVariantQuoted
+	if opening != '"' && opening != '\'' {
+		parsed.Variant = TweetVariantTypographic
+	}
+	if !strings.ContainsRune(closing, closedBy) {
+		parsed.Confidence -= 0.1
+	}
+	if strings.ContainsRune(parsed.Title, opening) || strings.ContainsAny(parsed.Title, closing) {
+		parsed.Confidence -= 0.1
+	}
+	return true
+}
+
+// splitUnquoted splits `<title> von <artist>` at the first ` von ` and reports whether the text
+// matched.
+func (parsed *ParsedTweet) splitUnquoted(text string) bool {
+	separators := unquotedSeparatorPattern.FindAllStringIndex(text, -1)
+	if len(separators) == 0 {
+		return false
+	}
+	parsed.Title = text[:separators[0][0]]
+	parsed.Artist = text[separators[0][1]:]
+	parsed.Variant = TweetVariantUnquoted
+	parsed.Confidence -= 0.4
+	if len(separators) > 1 {
+		parsed.Confidence -= 0.3
+	}
+	return true
+}
+
+func isQuote(r rune) bool {
+	_, ok := closingQuotes[r]
+	return ok
+}
@@ -0,0 +1,64 @@
+package fetcher
+
+import (
+	"testing"
+)
+
+func TestParseTweet(t *testing.T) {
+	var tests = []struct {
+		text        string
+		expected    ParsedTweet
+		expectedErr bool
+	}{
+		{"16:39: \"River\" von Eminem",
+			ParsedTweet{true, 16, 39, "River", "Eminem", "", TweetVariantQuoted, 1}, false},
+		{"9:05: \"River\" von Eminem feat. Ed Sheeran",
+			ParsedTweet{true, 9, 5, "River", "Eminem feat. Ed Sheeran", "Ed Sheeran",
+				TweetVariantQuoted, 1}, false},
+		{"16:39: \"River (feat. Ed Sheeran)\" von Eminem",
+			ParsedTweet{true, 16, 39, "River (feat. Ed Sheeran)", "Eminem", "Ed Sheeran",
+				TweetVariantQuoted, 1}, false},
+		{"16:39: „Ein Kompliment“ von Sportfreunde Stiller",
+			ParsedTweet{true, 16, 39, "Ein Kompliment", "Sportfreunde Stiller", "",
+				TweetVariantTypographic, 1}, false},
+		{"16:39: “Don’t Stop Me Now” von Queen",
+			ParsedTweet{true, 16, 39, "Don’t Stop Me Now", "Queen", "",
+				TweetVariantTypographic, 1}, false},
+		{"16:39: „River\" von Eminem",
+			ParsedTweet{true, 16, 39, "River", "Eminem", "", TweetVariantTypographic, 0.9}, false},
+		{"16:39: \"Sign \"O\" the Times\" von Prince",
+			ParsedTweet{true, 16, 39, "Sign \"O\" the Times", "Prince", "", TweetVariantQuoted,
+				0.9}, false},
+		{"16:39: \"Boléro\" von Herbert von Karajan",
+			ParsedTweet{true, 16, 39, "Boléro", "Herbert von Karajan", "", TweetVariantQuoted, 1},
+			false},
+		{"16:39: \"Der Kommissar\" von Falco #ö3 #nowplaying https://t.co/abc123",
+			ParsedTweet{true, 16, 39, "Der Kommissar", "Falco", "", TweetVariantQuoted, 1}, false},
+		{"16.39 Uhr - \"Der Kommissar\" von Falco",
+			ParsedTweet{true, 16, 39, "Der Kommissar", "Falco", "", TweetVariantQuoted, 1}, false},
+		{"\"Der Kommissar\" von Falco",
+			ParsedTweet{false, 0, 0, "Der Kommissar", "Falco", "", TweetVariantQuoted, 0.8},
+			false},
+		{"16:39: Der Kommissar von Falco",
+			ParsedTweet{true, 16, 39, "Der Kommissar", "Falco", "", TweetVariantUnquoted, 0.6},
+			false},
+		{"16:39: Boléro von Herbert von Karajan",
+			ParsedTweet{true, 16, 39, "Boléro", "Herbert von Karajan", "", TweetVariantUnquoted,
+				0.3}, false},
+		{"Jetzt im Ö3-Wecker: Robert Kratky", ParsedTweet{}, true},
+		{"16:39: \"\" von Falco", ParsedTweet{}, true},
+		{"16:39: \"Der Kommissar\" von #falco", ParsedTweet{}, true},
+		{"25:39: \"Der Kommissar\" von Falco", ParsedTweet{}, true},
+	}
+
+	for _, test := range tests {
+		parsed, err := ParseTweet(test.text)
+		if (err != nil) != test.expectedErr {
+			t.Errorf("ParseTweet(%q): got error (%v), expected error: %v", test.text, err,
+				test.expectedErr)
+		}
+		if err == nil && parsed != test.expected {
+			t.Errorf("ParseTweet(%q): got\n%+v, expected\n%+v", test.text, parsed, test.expected)
+		}
+	}
+}
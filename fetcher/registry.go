@@ -0,0 +1,64 @@
+package fetcher
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Factory creates a new Fetcher from the options of a station configuration entry.
+type Factory func(options map[string]string) (Fetcher, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes a fetcher type available under the provided name. It is intended to be called
+// from the init function of the file implementing the fetcher and panics if the name is already
+// taken or the factory is nil.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if factory == nil {
+		panic("fetcher: Register factory is nil")
+	}
+	if _, dup := factories[name]; dup {
+		panic("fetcher: Register called twice for fetcher type " + name)
+	}
+	factories[name] = factory
+}
+
+// New creates a Fetcher of the registered type `name`.
+func New(name string, options map[string]string) (Fetcher, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, errors.New("unknown fetcher type `" + name + "`")
+	}
+	if options == nil {
+		options = map[string]string{}
+	}
+	return factory(options)
+}
+
+// IsRegistered reports whether a fetcher type with the provided name has been registered.
+func IsRegistered(name string) bool {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	_, ok := factories[name]
+	return ok
+}
+
+// Types returns the sorted names of all registered fetcher types.
+func Types() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	var names []string
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
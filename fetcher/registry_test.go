@@ -0,0 +1,65 @@
+package fetcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var tests = []struct {
+		name        string
+		options     map[string]string
+		expectedErr bool
+	}{
+		{"kronehit", nil, false},
+		{"hitradio-oe3", map[string]string{
+			"consumer_key":              "abcdefg",
+			"consumer_key_secret":       "abcdefg",
+			"oauth_access_token":        "abcdefg",
+			"oauth_access_token_secret": "abcdefg",
+		}, false},
+		{"hitradio-oe3", map[string]string{
+			"consumer_key":              "abcdefg",
+			"consumer_key_secret":       "abcdefg",
+			"oauth_access_token":        "abcdefg",
+			"oauth_access_token_secret": "abcdefg",
+			"airtime_tolerance":         "45m",
+		}, false},
+		{"hitradio-oe3", map[string]string{
+			"consumer_key":              "abcdefg",
+			"consumer_key_secret":       "abcdefg",
+			"oauth_access_token":        "abcdefg",
+			"oauth_access_token_secret": "abcdefg",
+			"airtime_tolerance":         "45",
+		}, true},
+		{"hitradio-oe3", map[string]string{}, true},
+		{"unknown", nil, true},
+	}
+
+	for _, test := range tests {
+		fetcher, err := New(test.name, test.options)
+		if (err != nil) != test.expectedErr {
+			t.Errorf("New(%q, %v): got error: (%v), expected error: (%v)",
+				test.name, test.options, err, test.expectedErr)
+		}
+		if err == nil && fetcher == nil {
+			t.Errorf("New(%q, %v): got nil Fetcher", test.name, test.options)
+		}
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Register: expected panic when registering `kronehit` twice")
+		}
+	}()
+	Register("kronehit", func(options map[string]string) (Fetcher, error) { return nil, nil })
+}
+
+func TestTypes(t *testing.T) {
+	expected := []string{"hitradio-oe3", "kronehit"}
+	if types := Types(); !reflect.DeepEqual(types, expected) {
+		t.Errorf("Types(): got %v, expected %v", types, expected)
+	}
+}
@@ -0,0 +1,84 @@
+package fetcher
+
+import (
+	"context"
+	"sync"
+)
+
+// Stats counts the items a fetcher received from its source and what became of them. A rising
+// number of failed items usually means that the source changed its format.
+type Stats struct {
+	// Items is the number of items received, e.g. tweets or entries of the playlist.
+	Items int `json:"items"`
+	// Extracted counts the items that were returned as TrackRecords.
+	Extracted int `json:"extracted"`
+	// Failed counts the items that could not be parsed.
+	Failed int `json:"failed"`
+	// Skipped counts the parsed items that were left out on purpose, e.g. duplicates or tracks
+	// that are still on air.
+	Skipped int `json:"skipped"`
+}
+
+// FailureRate returns the percentage of the items that could not be parsed.
+func (stats Stats) FailureRate() float64 {
+	return percentage(stats.Failed, stats.Items)
+}
+
+// SkipRate returns the percentage of the items that were not returned as TrackRecords, either
+// because they failed to parse or because they were skipped.
+func (stats Stats) SkipRate() float64 {
+	return percentage(stats.Items-stats.Extracted, stats.Items)
+}
+
+// Add adds the counts of `other` to the stats.
+func (stats *Stats) Add(other Stats) {
+	stats.Items += other.Items
+	stats.Extracted += other.Extracted
+	stats.Failed += other.Failed
+	stats.Skipped += other.Skipped
+}
+
+func percentage(part, total int) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+// StatsCollector sums up the stats of the responses fetched with a context returned by
+// WithStatsCollector. It is safe for concurrent use.
+type StatsCollector struct {
+	mu    sync.Mutex
+	stats Stats
+}
+
+// Stats returns the sum of the stats collected so far.
+func (collector *StatsCollector) Stats() Stats {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	return collector.stats
+}
+
+func (collector *StatsCollector) add(stats Stats) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.stats.Add(stats)
+}
+
+type collectorKey struct{}
+
+// WithStatsCollector returns a copy of `ctx` that makes the fetchers add the stats of every
+// response they handle to `collector`.
+func WithStatsCollector(ctx context.Context, collector *StatsCollector) context.Context {
+	return context.WithValue(ctx, collectorKey{}, collector)
+}
+
+// ReportStats updates the item metrics of the fetcher type and adds the stats of a response to
+// the collector of `ctx`, if any. Fetchers call it once per response, so that the crawler is
+// able to fail runs that exceed their thresholds.
+func ReportStats(ctx context.Context, fetcherType string, stats Stats) {
+	recordItems(fetcherType, stats)
+	if collector, ok := ctx.Value(collectorKey{}).(*StatsCollector); ok {
+		collector.add(stats)
+	}
+}
@@ -0,0 +1,43 @@
+package fetcher
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStats_Rates(t *testing.T) {
+	var tests = []struct {
+		stats               Stats
+		expectedFailureRate float64
+		expectedSkipRate    float64
+	}{
+		{Stats{}, 0, 0},
+		{Stats{Items: 4, Extracted: 4}, 0, 0},
+		{Stats{Items: 4, Extracted: 2, Failed: 1, Skipped: 1}, 25, 50},
+		{Stats{Items: 2, Failed: 2}, 100, 100},
+	}
+
+	for _, test := range tests {
+		if rate := test.stats.FailureRate(); rate != test.expectedFailureRate {
+			t.Errorf("%+v FailureRate: got %v, expected %v", test.stats, rate,
+				test.expectedFailureRate)
+		}
+		if rate := test.stats.SkipRate(); rate != test.expectedSkipRate {
+			t.Errorf("%+v SkipRate: got %v, expected %v", test.stats, rate,
+				test.expectedSkipRate)
+		}
+	}
+}
+
+func TestWithStatsCollector(t *testing.T) {
+	collector := &StatsCollector{}
+	ctx := WithStatsCollector(context.Background(), collector)
+	ReportStats(ctx, "stats-test", Stats{Items: 3, Extracted: 2, Failed: 1})
+	ReportStats(ctx, "stats-test", Stats{Items: 2, Extracted: 1, Skipped: 1})
+	ReportStats(context.Background(), "stats-test", Stats{Items: 5, Failed: 5})
+
+	expected := Stats{Items: 5, Extracted: 3, Failed: 1, Skipped: 1}
+	if stats := collector.Stats(); stats != expected {
+		t.Errorf("StatsCollector: got %+v, expected %+v", stats, expected)
+	}
+}
@@ -0,0 +1,80 @@
+// Package logging configures the structured loggers of the crawlers, the fetchers and the
+// homebase connector, which are based on log/slog.
+//
+// Loggers are passed down using contexts: the crawler adds the station and run id to its
+// logger and stores it in the context of all requests, so that the fetcher and the homebase
+// connector log these fields as well.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Log formats supported by New.
+const (
+	// FormatText writes logfmt, e.g. `time=... level=INFO msg="Crawler started" station=kronehit`.
+	FormatText = "text"
+	// FormatJSON writes a JSON object per line.
+	FormatJSON = "json"
+)
+
+// New creates a logger writing to `w`. `format` is FormatText (the default if empty) or
+// FormatJSON, `level` is the minimum level that is logged (see ParseLevel).
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	minLevel, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	options := &slog.HandlerOptions{Level: minLevel}
+	switch strings.ToLower(format) {
+	case "", FormatText, "logfmt":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	}
+	return nil, fmt.Errorf("unknown log format `%s` (available: %s, %s)", format, FormatJSON,
+		FormatText)
+}
+
+// ParseLevel parses `debug`, `info` (the default if empty), `warn`/`warning` or `error`.
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level `%s` (available: debug, info, warn, error)", level)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of `ctx` that carries `logger`.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by `ctx`, or slog.Default() if there is none.
+func FromContext(ctx context.Context) *slog.Logger {
+	return FromContextOr(ctx, nil)
+}
+
+// FromContextOr returns the logger carried by `ctx`, or `fallback` if there is none. It returns
+// slog.Default() if both are missing.
+func FromContextOr(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+	if fallback != nil {
+		return fallback
+	}
+	return slog.Default()
+}
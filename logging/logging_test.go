@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	var tests = []struct {
+		format   string
+		level    string
+		expected string
+	}{
+		{"", "", "level=INFO msg=info station=kronehit\n"},
+		{"logfmt", "warn", "level=WARN msg=warn station=kronehit\n"},
+		{FormatText, "debug",
+			"level=DEBUG msg=debug station=kronehit\nlevel=INFO msg=info station=kronehit\n"},
+		{"JSON", "error", ""},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		logger, err := New(&buf, test.format, test.level)
+		if err != nil {
+			t.Errorf("New(%q, %q): unexpected error: %s", test.format, test.level, err)
+			continue
+		}
+		logger = slog.New(withoutTime{logger.Handler()}).With("station", "kronehit")
+		logger.Debug("debug")
+		logger.Info("info")
+		if test.level == "warn" {
+			logger.Warn("warn")
+		}
+		if result := buf.String(); result != test.expected {
+			t.Errorf("New(%q, %q): Expected: %q, Actual: %q", test.format, test.level,
+				test.expected, result)
+		}
+	}
+}
+
+func TestNew_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	logger.Info("Crawler started.", "station", "kronehit", "page", 2)
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unable to decode `%s`: %s", buf.String(), err)
+	}
+	if record["msg"] != "Crawler started." || record["station"] != "kronehit" ||
+		record["page"] != 2.0 || record["level"] != "INFO" {
+		t.Errorf("unexpected record: %v", record)
+	}
+}
+
+func TestNew_Error(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", ""); err == nil ||
+		!strings.Contains(err.Error(), "xml") {
+		t.Errorf("Expected an error for the format `xml`, Actual: %v", err)
+	}
+	if _, err := New(&bytes.Buffer{}, "", "verbose"); err == nil ||
+		!strings.Contains(err.Error(), "verbose") {
+		t.Errorf("Expected an error for the level `verbose`, Actual: %v", err)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	var tests = []struct {
+		level    string
+		expected slog.Level
+		err      bool
+	}{
+		{"", slog.LevelInfo, false},
+		{"debug", slog.LevelDebug, false},
+		{"INFO", slog.LevelInfo, false},
+		{"warn", slog.LevelWarn, false},
+		{"Warning", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"fatal", 0, true},
+	}
+
+	for _, test := range tests {
+		result, err := ParseLevel(test.level)
+		if (err != nil) != test.err {
+			t.Errorf("ParseLevel(%q): unexpected error: %v", test.level, err)
+		}
+		if result != test.expected {
+			t.Errorf("ParseLevel(%q): Expected: %s, Actual: %s", test.level, test.expected,
+				result)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	fallback := slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil))
+	ctx := NewContext(context.Background(), logger)
+
+	if FromContext(ctx) != logger {
+		t.Errorf("FromContext: expected the logger of the context")
+	}
+	if FromContext(context.Background()) != slog.Default() {
+		t.Errorf("FromContext: expected the default logger")
+	}
+	if FromContextOr(ctx, fallback) != logger {
+		t.Errorf("FromContextOr: expected the logger of the context")
+	}
+	if FromContextOr(context.Background(), fallback) != fallback {
+		t.Errorf("FromContextOr: expected the fallback logger")
+	}
+}
+
+// withoutTime removes the time of all records to make the output predictable.
+type withoutTime struct {
+	slog.Handler
+}
+
+func (h withoutTime) Handle(ctx context.Context, record slog.Record) error {
+	record.Time = time.Time{}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h withoutTime) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return withoutTime{h.Handler.WithAttrs(attrs)}
+}
@@ -0,0 +1,62 @@
+// Package tracing configures the OpenTelemetry tracing of the crawlers.
+//
+// The crawler, the fetchers and the homebase connector create their spans using the global
+// tracer provider, which discards them unless Setup is called. Spans of a crawler run form a
+// single trace: `Crawl` is the root span, every page fetched by the fetcher is a `Fetcher.Next`
+// span and every request to the station's source or the homebase is a child of these.
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultServiceName is the service name reported to the collector if none is given.
+const DefaultServiceName = "radiochecker-crawlers"
+
+// Attributes of the spans of a crawler run.
+const (
+	StationKey = attribute.Key("radiochecker.station")
+	RunKey     = attribute.Key("radiochecker.run_id")
+	PageKey    = attribute.Key("radiochecker.page")
+	FetcherKey = attribute.Key("radiochecker.fetcher")
+)
+
+// Setup exports all spans to the OTLP/HTTP collector at `endpoint`, e.g.
+// `http://localhost:4318` for a local collector, and installs the W3C trace context propagator
+// so that the homebase is able to continue the traces. The returned provider must be shut down
+// (or flushed, e.g. at the end of a Lambda invocation) to export the remaining spans.
+func Setup(ctx context.Context, endpoint, serviceName string) (*sdktrace.TracerProvider,
+	error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider, nil
+}
+
+// End records `err`, if any, on the span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEnd(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	_, span := tracer.Start(context.Background(), "success")
+	End(span, nil)
+	_, span = tracer.Start(context.Background(), "failure")
+	End(span, errors.New("just a test"))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("End: got %d ended spans, expected 2", len(spans))
+	}
+	if spans[0].Status().Code != codes.Unset || len(spans[0].Events()) != 0 {
+		t.Errorf("End: got status %v for span without error", spans[0].Status())
+	}
+	if spans[1].Status().Code != codes.Error || spans[1].Status().Description != "just a test" ||
+		len(spans[1].Events()) != 1 || spans[1].Events()[0].Name != "exception" {
+		t.Errorf("End: got status %v and events %v for span with error", spans[1].Status(),
+			spans[1].Events())
+	}
+}
+
+func TestSetup(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	provider, err := Setup(context.Background(), server.URL, "")
+	if err != nil {
+		t.Fatalf("Setup: unexpected error: %s", err)
+	}
+	_, span := provider.Tracer("test").Start(context.Background(), "Crawl")
+	span.End()
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: unexpected error: %s", err)
+	}
+
+	if len(paths) != 1 || paths[0] != "POST /v1/traces" {
+		t.Errorf("Setup: got requests %v, expected [POST /v1/traces]", paths)
+	}
+}